@@ -0,0 +1,2000 @@
+// Package api provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.1 DO NOT EDIT.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oapi-codegen/runtime"
+	strictnethttp "github.com/oapi-codegen/runtime/strictmiddleware/nethttp"
+)
+
+const (
+	Api_keyScopes    = "api_key.Scopes"
+	BearerAuthScopes = "bearerAuth.Scopes"
+)
+
+// Defines values for OrderStatus.
+const (
+	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusCompleted OrderStatus = "completed"
+	OrderStatusPlaced    OrderStatus = "placed"
+	OrderStatusPreparing OrderStatus = "preparing"
+	OrderStatusReady     OrderStatus = "ready"
+)
+
+// Defines values for OrderStatusUpdateStatus.
+const (
+	OrderStatusUpdateStatusCancelled OrderStatusUpdateStatus = "cancelled"
+	OrderStatusUpdateStatusCompleted OrderStatusUpdateStatus = "completed"
+	OrderStatusUpdateStatusPreparing OrderStatusUpdateStatus = "preparing"
+	OrderStatusUpdateStatusReady     OrderStatusUpdateStatus = "ready"
+)
+
+// ApiResponse defines model for ApiResponse.
+type ApiResponse struct {
+	Code    *int32  `json:"code,omitempty"`
+	Message *string `json:"message,omitempty"`
+	Type    *string `json:"type,omitempty"`
+}
+
+// Money Amount of money, exact to the cent
+type Money struct {
+	// Amount Decimal amount
+	Amount string `json:"amount"`
+
+	// Currency ISO 4217 currency code
+	Currency string `json:"currency"`
+}
+
+// Order defines model for Order.
+type Order struct {
+	CouponCode *string    `json:"couponCode,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+
+	// CustomerId Customer who placed the order, absent for orders placed with an API key
+	CustomerId *string `json:"customerId,omitempty"`
+
+	// Discounts Discount given by the coupon code
+	Discounts float64     `json:"discounts"`
+	Id        string      `json:"id"`
+	Items     []OrderItem `json:"items"`
+
+	// Products Each ordered product once
+	Products []Product `json:"products"`
+
+	// Total Order total after the discount
+	Total float64 `json:"total"`
+}
+
+// OrderItem defines model for OrderItem.
+type OrderItem struct {
+	// ProductId ID of the product
+	ProductId string `json:"productId"`
+
+	// Quantity Item count
+	Quantity int `json:"quantity"`
+}
+
+// OrderReq Place a new order
+type OrderReq struct {
+	// CouponCode Optional promo code applied to the order
+	CouponCode *string     `json:"couponCode,omitempty"`
+	Items      []OrderItem `json:"items"`
+}
+
+// OrderStatus Where the order is in its lifecycle
+type OrderStatus string
+
+// OrderStatusUpdate New status or ETA of an order, at least one of them
+type OrderStatusUpdate struct {
+	// Eta When the order should be ready, only while it is placed or preparing
+	Eta    *time.Time               `json:"eta,omitempty"`
+	Status *OrderStatusUpdateStatus `json:"status,omitempty"`
+}
+
+// OrderStatusUpdateStatus defines model for OrderStatusUpdate.Status.
+type OrderStatusUpdateStatus string
+
+// OrderV2 Order as served by v2, with amounts as `Money`
+type OrderV2 struct {
+	CouponCode *string    `json:"couponCode,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+
+	// CustomerId Customer who placed the order, absent for orders placed with an API key
+	CustomerId *string `json:"customerId,omitempty"`
+
+	// Discount Amount of money, exact to the cent
+	Discount Money `json:"discount"`
+
+	// Eta When the order should be ready, while it is placed or preparing
+	Eta   *time.Time  `json:"eta,omitempty"`
+	Id    string      `json:"id"`
+	Items []OrderItem `json:"items"`
+
+	// Products Each ordered product once
+	Products []ProductV2 `json:"products"`
+
+	// Status Where the order is in its lifecycle
+	Status OrderStatus `json:"status"`
+
+	// Subtotal Amount of money, exact to the cent
+	Subtotal Money `json:"subtotal"`
+
+	// Taxes Taxes charged on the subtotal after discount and added to the total, one line per configured tax
+	Taxes []TaxLine `json:"taxes"`
+
+	// Total Amount of money, exact to the cent
+	Total Money `json:"total"`
+}
+
+// Problem RFC 7807 problem details
+type Problem struct {
+	Detail *string `json:"detail,omitempty"`
+	Errors *[]struct {
+		Field   *string `json:"field,omitempty"`
+		Message *string `json:"message,omitempty"`
+	} `json:"errors,omitempty"`
+	Instance  *string `json:"instance,omitempty"`
+	RequestId *string `json:"requestId,omitempty"`
+	Status    *int    `json:"status,omitempty"`
+	Title     *string `json:"title,omitempty"`
+	Type      *string `json:"type,omitempty"`
+}
+
+// Product defines model for Product.
+type Product struct {
+	Category string `json:"category"`
+	Id       string `json:"id"`
+
+	// Image URLs of the product picture for each screen size
+	Image ProductImage `json:"image"`
+	Name  string       `json:"name"`
+
+	// Price Selling price
+	Price float64 `json:"price"`
+}
+
+// ProductImage URLs of the product picture for each screen size
+type ProductImage struct {
+	Desktop   string `json:"desktop"`
+	Mobile    string `json:"mobile"`
+	Tablet    string `json:"tablet"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// ProductV2 Product as served by v2, with its price as `Money`
+type ProductV2 struct {
+	Category string `json:"category"`
+	Id       string `json:"id"`
+
+	// Image URLs of the product picture for each screen size
+	Image ProductImage `json:"image"`
+	Name  string       `json:"name"`
+
+	// Price Amount of money, exact to the cent
+	Price Money `json:"price"`
+}
+
+// TaxLine Tax charged on an order
+type TaxLine struct {
+	// Amount Amount of money, exact to the cent
+	Amount Money  `json:"amount"`
+	Name   string `json:"name"`
+
+	// Rate Fraction of the subtotal after discount, e.g. 0.1 for 10%
+	Rate float64 `json:"rate"`
+}
+
+// IfModifiedSince defines model for IfModifiedSince.
+type IfModifiedSince = string
+
+// IfNoneMatch defines model for IfNoneMatch.
+type IfNoneMatch = string
+
+// ErrorApplicationJSON defines model for Error.
+type ErrorApplicationJSON = ApiResponse
+
+// ErrorApplicationProblemPlusJSON RFC 7807 problem details
+type ErrorApplicationProblemPlusJSON = Problem
+
+// ListOrdersParams defines parameters for ListOrders.
+type ListOrdersParams struct {
+	Limit  *int `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// ListProductsParams defines parameters for ListProducts.
+type ListProductsParams struct {
+	// IfNoneMatch ETag of a representation held by the client, e.g. `"3f2a9c…-v1"`, or `*`
+	IfNoneMatch *IfNoneMatch `json:"If-None-Match,omitempty"`
+
+	// IfModifiedSince HTTP date of a representation held by the client, ignored with `If-None-Match`
+	IfModifiedSince *IfModifiedSince `json:"If-Modified-Since,omitempty"`
+}
+
+// ListOrdersV2Params defines parameters for ListOrdersV2.
+type ListOrdersV2Params struct {
+	Limit  *int `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// ListProductsV2Params defines parameters for ListProductsV2.
+type ListProductsV2Params struct {
+	// IfNoneMatch ETag of a representation held by the client, e.g. `"3f2a9c…-v1"`, or `*`
+	IfNoneMatch *IfNoneMatch `json:"If-None-Match,omitempty"`
+
+	// IfModifiedSince HTTP date of a representation held by the client, ignored with `If-None-Match`
+	IfModifiedSince *IfModifiedSince `json:"If-Modified-Since,omitempty"`
+}
+
+// PlaceOrderJSONRequestBody defines body for PlaceOrder for application/json ContentType.
+type PlaceOrderJSONRequestBody = OrderReq
+
+// PlaceOrderV2JSONRequestBody defines body for PlaceOrderV2 for application/json ContentType.
+type PlaceOrderV2JSONRequestBody = OrderReq
+
+// UpdateOrderStatusV2JSONRequestBody defines body for UpdateOrderStatusV2 for application/json ContentType.
+type UpdateOrderStatusV2JSONRequestBody = OrderStatusUpdate
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// List orders
+	// (GET /order)
+	ListOrders(w http.ResponseWriter, r *http.Request, params ListOrdersParams)
+	// Place an order
+	// (POST /order)
+	PlaceOrder(w http.ResponseWriter, r *http.Request)
+	// Find order by ID
+	// (GET /order/{orderId})
+	GetOrder(w http.ResponseWriter, r *http.Request, orderId string)
+	// List products
+	// (GET /product)
+	ListProducts(w http.ResponseWriter, r *http.Request, params ListProductsParams)
+	// Find product by ID
+	// (GET /product/{productId})
+	GetProduct(w http.ResponseWriter, r *http.Request, productId int64)
+	// List orders (v2)
+	// (GET /v2/order)
+	ListOrdersV2(w http.ResponseWriter, r *http.Request, params ListOrdersV2Params)
+	// Place an order (v2)
+	// (POST /v2/order)
+	PlaceOrderV2(w http.ResponseWriter, r *http.Request)
+	// Find order by ID (v2)
+	// (GET /v2/order/{orderId})
+	GetOrderV2(w http.ResponseWriter, r *http.Request, orderId string)
+	// Update the status of an order (v2)
+	// (PUT /v2/order/{orderId}/status)
+	UpdateOrderStatusV2(w http.ResponseWriter, r *http.Request, orderId string)
+	// List products (v2)
+	// (GET /v2/product)
+	ListProductsV2(w http.ResponseWriter, r *http.Request, params ListProductsV2Params)
+	// Find product by ID (v2)
+	// (GET /v2/product/{productId})
+	GetProductV2(w http.ResponseWriter, r *http.Request, productId int64)
+}
+
+// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandlerFunc   func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+type MiddlewareFunc func(http.Handler) http.Handler
+
+// ListOrders operation middleware
+func (siw *ServerInterfaceWrapper) ListOrders(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"read_orders"})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListOrdersParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListOrders(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PlaceOrder operation middleware
+func (siw *ServerInterfaceWrapper) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{"create_order"})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"create_order"})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PlaceOrder(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetOrder operation middleware
+func (siw *ServerInterfaceWrapper) GetOrder(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "orderId" -------------
+	var orderId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "orderId", mux.Vars(r)["orderId"], &orderId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "orderId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"read_orders"})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetOrder(w, r, orderId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListProducts operation middleware
+func (siw *ServerInterfaceWrapper) ListProducts(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListProductsParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "If-None-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-None-Match")]; found {
+		var IfNoneMatch IfNoneMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-None-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-None-Match", valueList[0], &IfNoneMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-None-Match", Err: err})
+			return
+		}
+
+		params.IfNoneMatch = &IfNoneMatch
+
+	}
+
+	// ------------- Optional header parameter "If-Modified-Since" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Modified-Since")]; found {
+		var IfModifiedSince IfModifiedSince
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Modified-Since", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Modified-Since", valueList[0], &IfModifiedSince, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Modified-Since", Err: err})
+			return
+		}
+
+		params.IfModifiedSince = &IfModifiedSince
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListProducts(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetProduct operation middleware
+func (siw *ServerInterfaceWrapper) GetProduct(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "productId" -------------
+	var productId int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "productId", mux.Vars(r)["productId"], &productId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "productId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetProduct(w, r, productId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListOrdersV2 operation middleware
+func (siw *ServerInterfaceWrapper) ListOrdersV2(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"read_orders"})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListOrdersV2Params
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListOrdersV2(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PlaceOrderV2 operation middleware
+func (siw *ServerInterfaceWrapper) PlaceOrderV2(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{"create_order"})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"create_order"})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PlaceOrderV2(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetOrderV2 operation middleware
+func (siw *ServerInterfaceWrapper) GetOrderV2(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "orderId" -------------
+	var orderId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "orderId", mux.Vars(r)["orderId"], &orderId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "orderId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{"read_orders"})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetOrderV2(w, r, orderId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// UpdateOrderStatusV2 operation middleware
+func (siw *ServerInterfaceWrapper) UpdateOrderStatusV2(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "orderId" -------------
+	var orderId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "orderId", mux.Vars(r)["orderId"], &orderId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "orderId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, Api_keyScopes, []string{})
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.UpdateOrderStatusV2(w, r, orderId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListProductsV2 operation middleware
+func (siw *ServerInterfaceWrapper) ListProductsV2(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListProductsV2Params
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "If-None-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-None-Match")]; found {
+		var IfNoneMatch IfNoneMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-None-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-None-Match", valueList[0], &IfNoneMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-None-Match", Err: err})
+			return
+		}
+
+		params.IfNoneMatch = &IfNoneMatch
+
+	}
+
+	// ------------- Optional header parameter "If-Modified-Since" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Modified-Since")]; found {
+		var IfModifiedSince IfModifiedSince
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Modified-Since", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Modified-Since", valueList[0], &IfModifiedSince, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Modified-Since", Err: err})
+			return
+		}
+
+		params.IfModifiedSince = &IfModifiedSince
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListProductsV2(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetProductV2 operation middleware
+func (siw *ServerInterfaceWrapper) GetProductV2(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "productId" -------------
+	var productId int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "productId", mux.Vars(r)["productId"], &productId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "productId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetProductV2(w, r, productId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+type UnescapedCookieParamError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *UnescapedCookieParamError) Error() string {
+	return fmt.Sprintf("error unescaping cookie parameter '%s'", e.ParamName)
+}
+
+func (e *UnescapedCookieParamError) Unwrap() error {
+	return e.Err
+}
+
+type UnmarshalingParamError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *UnmarshalingParamError) Error() string {
+	return fmt.Sprintf("Error unmarshaling parameter %s as JSON: %s", e.ParamName, e.Err.Error())
+}
+
+func (e *UnmarshalingParamError) Unwrap() error {
+	return e.Err
+}
+
+type RequiredParamError struct {
+	ParamName string
+}
+
+func (e *RequiredParamError) Error() string {
+	return fmt.Sprintf("Query argument %s is required, but not found", e.ParamName)
+}
+
+type RequiredHeaderError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *RequiredHeaderError) Error() string {
+	return fmt.Sprintf("Header parameter %s is required, but not found", e.ParamName)
+}
+
+func (e *RequiredHeaderError) Unwrap() error {
+	return e.Err
+}
+
+type InvalidParamFormatError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *InvalidParamFormatError) Error() string {
+	return fmt.Sprintf("Invalid format for parameter %s: %s", e.ParamName, e.Err.Error())
+}
+
+func (e *InvalidParamFormatError) Unwrap() error {
+	return e.Err
+}
+
+type TooManyValuesForParamError struct {
+	ParamName string
+	Count     int
+}
+
+func (e *TooManyValuesForParamError) Error() string {
+	return fmt.Sprintf("Expected one value for %s, got %d", e.ParamName, e.Count)
+}
+
+// Handler creates http.Handler with routing matching OpenAPI spec.
+func Handler(si ServerInterface) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{})
+}
+
+type GorillaServerOptions struct {
+	BaseURL          string
+	BaseRouter       *mux.Router
+	Middlewares      []MiddlewareFunc
+	ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HandlerFromMux creates http.Handler with routing matching OpenAPI spec based on the provided mux.
+func HandlerFromMux(si ServerInterface, r *mux.Router) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{
+		BaseRouter: r,
+	})
+}
+
+func HandlerFromMuxWithBaseURL(si ServerInterface, r *mux.Router, baseURL string) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{
+		BaseURL:    baseURL,
+		BaseRouter: r,
+	})
+}
+
+// HandlerWithOptions creates http.Handler with additional options
+func HandlerWithOptions(si ServerInterface, options GorillaServerOptions) http.Handler {
+	r := options.BaseRouter
+
+	if r == nil {
+		r = mux.NewRouter()
+	}
+	if options.ErrorHandlerFunc == nil {
+		options.ErrorHandlerFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandlerFunc:   options.ErrorHandlerFunc,
+	}
+
+	r.HandleFunc(options.BaseURL+"/order", wrapper.ListOrders).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/order", wrapper.PlaceOrder).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/order/{orderId}", wrapper.GetOrder).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/product", wrapper.ListProducts).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/product/{productId}", wrapper.GetProduct).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/v2/order", wrapper.ListOrdersV2).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/v2/order", wrapper.PlaceOrderV2).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/v2/order/{orderId}", wrapper.GetOrderV2).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/v2/order/{orderId}/status", wrapper.UpdateOrderStatusV2).Methods("PUT")
+
+	r.HandleFunc(options.BaseURL+"/v2/product", wrapper.ListProductsV2).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/v2/product/{productId}", wrapper.GetProductV2).Methods("GET")
+
+	return r
+}
+
+type ErrorJSONResponse ApiResponse
+type ErrorApplicationProblemPlusJSONResponse Problem
+type ErrorApplicationxmlResponse struct {
+	Body io.Reader
+
+	ContentLength int64
+}
+
+type NotModifiedResponseHeaders struct {
+	CacheControl string
+	ETag         string
+	LastModified string
+}
+type NotModifiedResponse struct {
+	Headers NotModifiedResponseHeaders
+}
+
+type ListOrdersRequestObject struct {
+	Params ListOrdersParams
+}
+
+type ListOrdersResponseObject interface {
+	VisitListOrdersResponse(w http.ResponseWriter) error
+}
+
+type ListOrders200JSONResponse []Order
+
+func (response ListOrders200JSONResponse) VisitListOrdersResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ListOrders200ApplicationxNdjsonResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response ListOrders200ApplicationxNdjsonResponse) VisitListOrdersResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type ListOrders400Response struct {
+}
+
+func (response ListOrders400Response) VisitListOrdersResponse(w http.ResponseWriter) error {
+	w.WriteHeader(400)
+	return nil
+}
+
+type ListOrders401Response struct {
+}
+
+func (response ListOrders401Response) VisitListOrdersResponse(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type ListOrders403Response struct {
+}
+
+func (response ListOrders403Response) VisitListOrdersResponse(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type PlaceOrderRequestObject struct {
+	Body *PlaceOrderJSONRequestBody
+}
+
+type PlaceOrderResponseObject interface {
+	VisitPlaceOrderResponse(w http.ResponseWriter) error
+}
+
+type PlaceOrder200JSONResponse Order
+
+func (response PlaceOrder200JSONResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrder400JSONResponse struct{ ErrorJSONResponse }
+
+func (response PlaceOrder400JSONResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrder400ApplicationProblemPlusJSONResponse struct {
+	ErrorApplicationProblemPlusJSONResponse
+}
+
+func (response PlaceOrder400ApplicationProblemPlusJSONResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrder400ApplicationxmlResponse struct{ ErrorApplicationxmlResponse }
+
+func (response PlaceOrder400ApplicationxmlResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(400)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type PlaceOrder401Response struct {
+}
+
+func (response PlaceOrder401Response) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type PlaceOrder403Response struct {
+}
+
+func (response PlaceOrder403Response) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type PlaceOrder422JSONResponse ApiResponse
+
+func (response PlaceOrder422JSONResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrder422ApplicationProblemPlusJSONResponse Problem
+
+func (response PlaceOrder422ApplicationProblemPlusJSONResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrder422ApplicationxmlResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response PlaceOrder422ApplicationxmlResponse) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(422)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type PlaceOrder429Response struct {
+}
+
+func (response PlaceOrder429Response) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(429)
+	return nil
+}
+
+type PlaceOrder503Response struct {
+}
+
+func (response PlaceOrder503Response) VisitPlaceOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(503)
+	return nil
+}
+
+type GetOrderRequestObject struct {
+	OrderId string `json:"orderId"`
+}
+
+type GetOrderResponseObject interface {
+	VisitGetOrderResponse(w http.ResponseWriter) error
+}
+
+type GetOrder200JSONResponse Order
+
+func (response GetOrder200JSONResponse) VisitGetOrderResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type GetOrder401Response struct {
+}
+
+func (response GetOrder401Response) VisitGetOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type GetOrder403Response struct {
+}
+
+func (response GetOrder403Response) VisitGetOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type GetOrder404Response struct {
+}
+
+func (response GetOrder404Response) VisitGetOrderResponse(w http.ResponseWriter) error {
+	w.WriteHeader(404)
+	return nil
+}
+
+type ListProductsRequestObject struct {
+	Params ListProductsParams
+}
+
+type ListProductsResponseObject interface {
+	VisitListProductsResponse(w http.ResponseWriter) error
+}
+
+type ListProducts200ResponseHeaders struct {
+	CacheControl string
+	ETag         string
+	LastModified string
+}
+
+type ListProducts200JSONResponse struct {
+	Body    []Product
+	Headers ListProducts200ResponseHeaders
+}
+
+func (response ListProducts200JSONResponse) VisitListProductsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
+type ListProducts200ApplicationxNdjsonResponse struct {
+	Body          io.Reader
+	Headers       ListProducts200ResponseHeaders
+	ContentLength int64
+}
+
+func (response ListProducts200ApplicationxNdjsonResponse) VisitListProductsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type ListProducts304Response = NotModifiedResponse
+
+func (response ListProducts304Response) VisitListProductsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(304)
+	return nil
+}
+
+type GetProductRequestObject struct {
+	ProductId int64 `json:"productId"`
+}
+
+type GetProductResponseObject interface {
+	VisitGetProductResponse(w http.ResponseWriter) error
+}
+
+type GetProduct200JSONResponse Product
+
+func (response GetProduct200JSONResponse) VisitGetProductResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type GetProduct400Response struct {
+}
+
+func (response GetProduct400Response) VisitGetProductResponse(w http.ResponseWriter) error {
+	w.WriteHeader(400)
+	return nil
+}
+
+type GetProduct404Response struct {
+}
+
+func (response GetProduct404Response) VisitGetProductResponse(w http.ResponseWriter) error {
+	w.WriteHeader(404)
+	return nil
+}
+
+type ListOrdersV2RequestObject struct {
+	Params ListOrdersV2Params
+}
+
+type ListOrdersV2ResponseObject interface {
+	VisitListOrdersV2Response(w http.ResponseWriter) error
+}
+
+type ListOrdersV2200JSONResponse []OrderV2
+
+func (response ListOrdersV2200JSONResponse) VisitListOrdersV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ListOrdersV2200ApplicationxNdjsonResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response ListOrdersV2200ApplicationxNdjsonResponse) VisitListOrdersV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type ListOrdersV2400Response struct {
+}
+
+func (response ListOrdersV2400Response) VisitListOrdersV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(400)
+	return nil
+}
+
+type ListOrdersV2401Response struct {
+}
+
+func (response ListOrdersV2401Response) VisitListOrdersV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type ListOrdersV2403Response struct {
+}
+
+func (response ListOrdersV2403Response) VisitListOrdersV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type PlaceOrderV2RequestObject struct {
+	Body *PlaceOrderV2JSONRequestBody
+}
+
+type PlaceOrderV2ResponseObject interface {
+	VisitPlaceOrderV2Response(w http.ResponseWriter) error
+}
+
+type PlaceOrderV2200JSONResponse OrderV2
+
+func (response PlaceOrderV2200JSONResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrderV2400JSONResponse struct{ ErrorJSONResponse }
+
+func (response PlaceOrderV2400JSONResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrderV2400ApplicationProblemPlusJSONResponse struct {
+	ErrorApplicationProblemPlusJSONResponse
+}
+
+func (response PlaceOrderV2400ApplicationProblemPlusJSONResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrderV2400ApplicationxmlResponse struct{ ErrorApplicationxmlResponse }
+
+func (response PlaceOrderV2400ApplicationxmlResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(400)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type PlaceOrderV2401Response struct {
+}
+
+func (response PlaceOrderV2401Response) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type PlaceOrderV2403Response struct {
+}
+
+func (response PlaceOrderV2403Response) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type PlaceOrderV2422JSONResponse ApiResponse
+
+func (response PlaceOrderV2422JSONResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrderV2422ApplicationProblemPlusJSONResponse Problem
+
+func (response PlaceOrderV2422ApplicationProblemPlusJSONResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type PlaceOrderV2422ApplicationxmlResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response PlaceOrderV2422ApplicationxmlResponse) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(422)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type PlaceOrderV2429Response struct {
+}
+
+func (response PlaceOrderV2429Response) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(429)
+	return nil
+}
+
+type PlaceOrderV2503Response struct {
+}
+
+func (response PlaceOrderV2503Response) VisitPlaceOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(503)
+	return nil
+}
+
+type GetOrderV2RequestObject struct {
+	OrderId string `json:"orderId"`
+}
+
+type GetOrderV2ResponseObject interface {
+	VisitGetOrderV2Response(w http.ResponseWriter) error
+}
+
+type GetOrderV2200JSONResponse OrderV2
+
+func (response GetOrderV2200JSONResponse) VisitGetOrderV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type GetOrderV2401Response struct {
+}
+
+func (response GetOrderV2401Response) VisitGetOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type GetOrderV2403Response struct {
+}
+
+func (response GetOrderV2403Response) VisitGetOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type GetOrderV2404Response struct {
+}
+
+func (response GetOrderV2404Response) VisitGetOrderV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(404)
+	return nil
+}
+
+type UpdateOrderStatusV2RequestObject struct {
+	OrderId string `json:"orderId"`
+	Body    *UpdateOrderStatusV2JSONRequestBody
+}
+
+type UpdateOrderStatusV2ResponseObject interface {
+	VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error
+}
+
+type UpdateOrderStatusV2200JSONResponse OrderV2
+
+func (response UpdateOrderStatusV2200JSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2400JSONResponse struct{ ErrorJSONResponse }
+
+func (response UpdateOrderStatusV2400JSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2400ApplicationProblemPlusJSONResponse struct {
+	ErrorApplicationProblemPlusJSONResponse
+}
+
+func (response UpdateOrderStatusV2400ApplicationProblemPlusJSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2400ApplicationxmlResponse struct{ ErrorApplicationxmlResponse }
+
+func (response UpdateOrderStatusV2400ApplicationxmlResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(400)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type UpdateOrderStatusV2401Response struct {
+}
+
+func (response UpdateOrderStatusV2401Response) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(401)
+	return nil
+}
+
+type UpdateOrderStatusV2403Response struct {
+}
+
+func (response UpdateOrderStatusV2403Response) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(403)
+	return nil
+}
+
+type UpdateOrderStatusV2404Response struct {
+}
+
+func (response UpdateOrderStatusV2404Response) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(404)
+	return nil
+}
+
+type UpdateOrderStatusV2409JSONResponse ApiResponse
+
+func (response UpdateOrderStatusV2409JSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(409)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2409ApplicationProblemPlusJSONResponse Problem
+
+func (response UpdateOrderStatusV2409ApplicationProblemPlusJSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(409)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2409ApplicationxmlResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response UpdateOrderStatusV2409ApplicationxmlResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(409)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type UpdateOrderStatusV2422JSONResponse ApiResponse
+
+func (response UpdateOrderStatusV2422JSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2422ApplicationProblemPlusJSONResponse Problem
+
+func (response UpdateOrderStatusV2422ApplicationProblemPlusJSONResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(422)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type UpdateOrderStatusV2422ApplicationxmlResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response UpdateOrderStatusV2422ApplicationxmlResponse) VisitUpdateOrderStatusV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(422)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type ListProductsV2RequestObject struct {
+	Params ListProductsV2Params
+}
+
+type ListProductsV2ResponseObject interface {
+	VisitListProductsV2Response(w http.ResponseWriter) error
+}
+
+type ListProductsV2200ResponseHeaders struct {
+	CacheControl string
+	ETag         string
+	LastModified string
+}
+
+type ListProductsV2200JSONResponse struct {
+	Body    []ProductV2
+	Headers ListProductsV2200ResponseHeaders
+}
+
+func (response ListProductsV2200JSONResponse) VisitListProductsV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
+type ListProductsV2200ApplicationxNdjsonResponse struct {
+	Body          io.Reader
+	Headers       ListProductsV2200ResponseHeaders
+	ContentLength int64
+}
+
+func (response ListProductsV2200ApplicationxNdjsonResponse) VisitListProductsV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type ListProductsV2304Response = NotModifiedResponse
+
+func (response ListProductsV2304Response) VisitListProductsV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Cache-Control", fmt.Sprint(response.Headers.CacheControl))
+	w.Header().Set("ETag", fmt.Sprint(response.Headers.ETag))
+	w.Header().Set("Last-Modified", fmt.Sprint(response.Headers.LastModified))
+	w.WriteHeader(304)
+	return nil
+}
+
+type GetProductV2RequestObject struct {
+	ProductId int64 `json:"productId"`
+}
+
+type GetProductV2ResponseObject interface {
+	VisitGetProductV2Response(w http.ResponseWriter) error
+}
+
+type GetProductV2200JSONResponse ProductV2
+
+func (response GetProductV2200JSONResponse) VisitGetProductV2Response(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type GetProductV2400Response struct {
+}
+
+func (response GetProductV2400Response) VisitGetProductV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(400)
+	return nil
+}
+
+type GetProductV2404Response struct {
+}
+
+func (response GetProductV2404Response) VisitGetProductV2Response(w http.ResponseWriter) error {
+	w.WriteHeader(404)
+	return nil
+}
+
+// StrictServerInterface represents all server handlers.
+type StrictServerInterface interface {
+	// List orders
+	// (GET /order)
+	ListOrders(ctx context.Context, request ListOrdersRequestObject) (ListOrdersResponseObject, error)
+	// Place an order
+	// (POST /order)
+	PlaceOrder(ctx context.Context, request PlaceOrderRequestObject) (PlaceOrderResponseObject, error)
+	// Find order by ID
+	// (GET /order/{orderId})
+	GetOrder(ctx context.Context, request GetOrderRequestObject) (GetOrderResponseObject, error)
+	// List products
+	// (GET /product)
+	ListProducts(ctx context.Context, request ListProductsRequestObject) (ListProductsResponseObject, error)
+	// Find product by ID
+	// (GET /product/{productId})
+	GetProduct(ctx context.Context, request GetProductRequestObject) (GetProductResponseObject, error)
+	// List orders (v2)
+	// (GET /v2/order)
+	ListOrdersV2(ctx context.Context, request ListOrdersV2RequestObject) (ListOrdersV2ResponseObject, error)
+	// Place an order (v2)
+	// (POST /v2/order)
+	PlaceOrderV2(ctx context.Context, request PlaceOrderV2RequestObject) (PlaceOrderV2ResponseObject, error)
+	// Find order by ID (v2)
+	// (GET /v2/order/{orderId})
+	GetOrderV2(ctx context.Context, request GetOrderV2RequestObject) (GetOrderV2ResponseObject, error)
+	// Update the status of an order (v2)
+	// (PUT /v2/order/{orderId}/status)
+	UpdateOrderStatusV2(ctx context.Context, request UpdateOrderStatusV2RequestObject) (UpdateOrderStatusV2ResponseObject, error)
+	// List products (v2)
+	// (GET /v2/product)
+	ListProductsV2(ctx context.Context, request ListProductsV2RequestObject) (ListProductsV2ResponseObject, error)
+	// Find product by ID (v2)
+	// (GET /v2/product/{productId})
+	GetProductV2(ctx context.Context, request GetProductV2RequestObject) (GetProductV2ResponseObject, error)
+}
+
+type StrictHandlerFunc = strictnethttp.StrictHTTPHandlerFunc
+type StrictMiddlewareFunc = strictnethttp.StrictHTTPMiddlewareFunc
+
+type StrictHTTPServerOptions struct {
+	RequestErrorHandlerFunc  func(w http.ResponseWriter, r *http.Request, err error)
+	ResponseErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func NewStrictHandler(ssi StrictServerInterface, middlewares []StrictMiddlewareFunc) ServerInterface {
+	return &strictHandler{ssi: ssi, middlewares: middlewares, options: StrictHTTPServerOptions{
+		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		},
+		ResponseErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		},
+	}}
+}
+
+func NewStrictHandlerWithOptions(ssi StrictServerInterface, middlewares []StrictMiddlewareFunc, options StrictHTTPServerOptions) ServerInterface {
+	return &strictHandler{ssi: ssi, middlewares: middlewares, options: options}
+}
+
+type strictHandler struct {
+	ssi         StrictServerInterface
+	middlewares []StrictMiddlewareFunc
+	options     StrictHTTPServerOptions
+}
+
+// ListOrders operation middleware
+func (sh *strictHandler) ListOrders(w http.ResponseWriter, r *http.Request, params ListOrdersParams) {
+	var request ListOrdersRequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.ListOrders(ctx, request.(ListOrdersRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ListOrders")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(ListOrdersResponseObject); ok {
+		if err := validResponse.VisitListOrdersResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// PlaceOrder operation middleware
+func (sh *strictHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var request PlaceOrderRequestObject
+
+	var body PlaceOrderJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sh.options.RequestErrorHandlerFunc(w, r, fmt.Errorf("can't decode JSON body: %w", err))
+		return
+	}
+	request.Body = &body
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.PlaceOrder(ctx, request.(PlaceOrderRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "PlaceOrder")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(PlaceOrderResponseObject); ok {
+		if err := validResponse.VisitPlaceOrderResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// GetOrder operation middleware
+func (sh *strictHandler) GetOrder(w http.ResponseWriter, r *http.Request, orderId string) {
+	var request GetOrderRequestObject
+
+	request.OrderId = orderId
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.GetOrder(ctx, request.(GetOrderRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "GetOrder")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(GetOrderResponseObject); ok {
+		if err := validResponse.VisitGetOrderResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// ListProducts operation middleware
+func (sh *strictHandler) ListProducts(w http.ResponseWriter, r *http.Request, params ListProductsParams) {
+	var request ListProductsRequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.ListProducts(ctx, request.(ListProductsRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ListProducts")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(ListProductsResponseObject); ok {
+		if err := validResponse.VisitListProductsResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// GetProduct operation middleware
+func (sh *strictHandler) GetProduct(w http.ResponseWriter, r *http.Request, productId int64) {
+	var request GetProductRequestObject
+
+	request.ProductId = productId
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.GetProduct(ctx, request.(GetProductRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "GetProduct")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(GetProductResponseObject); ok {
+		if err := validResponse.VisitGetProductResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// ListOrdersV2 operation middleware
+func (sh *strictHandler) ListOrdersV2(w http.ResponseWriter, r *http.Request, params ListOrdersV2Params) {
+	var request ListOrdersV2RequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.ListOrdersV2(ctx, request.(ListOrdersV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ListOrdersV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(ListOrdersV2ResponseObject); ok {
+		if err := validResponse.VisitListOrdersV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// PlaceOrderV2 operation middleware
+func (sh *strictHandler) PlaceOrderV2(w http.ResponseWriter, r *http.Request) {
+	var request PlaceOrderV2RequestObject
+
+	var body PlaceOrderV2JSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sh.options.RequestErrorHandlerFunc(w, r, fmt.Errorf("can't decode JSON body: %w", err))
+		return
+	}
+	request.Body = &body
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.PlaceOrderV2(ctx, request.(PlaceOrderV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "PlaceOrderV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(PlaceOrderV2ResponseObject); ok {
+		if err := validResponse.VisitPlaceOrderV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// GetOrderV2 operation middleware
+func (sh *strictHandler) GetOrderV2(w http.ResponseWriter, r *http.Request, orderId string) {
+	var request GetOrderV2RequestObject
+
+	request.OrderId = orderId
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.GetOrderV2(ctx, request.(GetOrderV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "GetOrderV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(GetOrderV2ResponseObject); ok {
+		if err := validResponse.VisitGetOrderV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// UpdateOrderStatusV2 operation middleware
+func (sh *strictHandler) UpdateOrderStatusV2(w http.ResponseWriter, r *http.Request, orderId string) {
+	var request UpdateOrderStatusV2RequestObject
+
+	request.OrderId = orderId
+
+	var body UpdateOrderStatusV2JSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sh.options.RequestErrorHandlerFunc(w, r, fmt.Errorf("can't decode JSON body: %w", err))
+		return
+	}
+	request.Body = &body
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.UpdateOrderStatusV2(ctx, request.(UpdateOrderStatusV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "UpdateOrderStatusV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(UpdateOrderStatusV2ResponseObject); ok {
+		if err := validResponse.VisitUpdateOrderStatusV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// ListProductsV2 operation middleware
+func (sh *strictHandler) ListProductsV2(w http.ResponseWriter, r *http.Request, params ListProductsV2Params) {
+	var request ListProductsV2RequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.ListProductsV2(ctx, request.(ListProductsV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ListProductsV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(ListProductsV2ResponseObject); ok {
+		if err := validResponse.VisitListProductsV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// GetProductV2 operation middleware
+func (sh *strictHandler) GetProductV2(w http.ResponseWriter, r *http.Request, productId int64) {
+	var request GetProductV2RequestObject
+
+	request.ProductId = productId
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.GetProductV2(ctx, request.(GetProductV2RequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "GetProductV2")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(GetProductV2ResponseObject); ok {
+		if err := validResponse.VisitGetProductV2Response(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
@@ -0,0 +1,6 @@
+// Request and response types and a strict server generated from
+// api/openapi.yaml. Run go generate after editing the spec; the controllers
+// stop compiling until they implement what changed.
+package api
+
+//go:generate go tool oapi-codegen -config oapi-codegen.yaml ../../../api/openapi.yaml
@@ -0,0 +1,53 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	k1, err := Generate()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	k2, _ := Generate()
+
+	if k1 == k2 {
+		t.Error("Expected generated keys to differ")
+	}
+	if !strings.HasPrefix(k1, keyPrefix) || len(k1) != len(keyPrefix)+48 {
+		t.Errorf("Unexpected key format %q", k1)
+	}
+	if Hash(k1) == Hash(k2) || Hash(k1) != Hash(k1) {
+		t.Error("Expected hash to be stable and distinct per key")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("create_order, read_orders,create_order")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(scopes) != 2 {
+		t.Errorf("Expected 2 scopes, got %v", scopes)
+	}
+
+	if _, err := ParseScopes("create_order,delete_everything"); err == nil {
+		t.Error("Expected error for unknown scope, got nil")
+	}
+	if _, err := ParseScopes(""); err == nil {
+		t.Error("Expected error for no scope, got nil")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	if !HasScope([]string{ScopeCreateOrder}, ScopeCreateOrder) {
+		t.Error("Expected granted scope to match")
+	}
+	if HasScope([]string{ScopeCreateOrder}, ScopeAdmin) {
+		t.Error("Expected admin scope to be missing")
+	}
+	if !HasScope([]string{ScopeAdmin}, ScopeReadOrders) {
+		t.Error("Expected admin to imply every scope")
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import "context"
+
+// Audited actions, named <entity>.<verb>
+const (
+	OrderCreate          = "order.create"
+	OrderUpdateStatus    = "order.update_status"
+	CouponCreate         = "coupon.create"
+	CouponImport         = "coupon.import"
+	CouponPopulate       = "coupon.populate"
+	CouponUpdateDiscount = "coupon.update_discount"
+	CouponUpdateRules    = "coupon.update_rules"
+	CouponDisable        = "coupon.disable"
+	CouponEnable         = "coupon.enable"
+	CustomerCreate       = "customer.create"
+	CustomerUpdate       = "customer.update"
+	ApiKeyCreate         = "api_key.create"
+	ApiKeyRevoke         = "api_key.revoke"
+	ApiKeyExpire         = "api_key.expire"
+)
+
+// Entities the actions apply to
+const (
+	EntityOrder    = "order"
+	EntityCoupon   = "coupon"
+	EntityCustomer = "customer"
+	EntityApiKey   = "api_key"
+)
+
+// Actor of changes made outside of a request, e.g. at startup
+const SystemActor = "system"
+
+type actorCtxKey struct{}
+type requestIdCtxKey struct{}
+
+// Attribute changes made with ctx to actor, e.g. "apikey:3"
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorCtxKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdCtxKey{}, id)
+}
+
+// Id of the request ctx belongs to, empty outside of requests
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdCtxKey{}).(string)
+	return id
+}
@@ -0,0 +1,144 @@
+package bearer
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+)
+
+// Public key from a JWKS document
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verificationKey struct {
+	alg string // empty when the JWK doesn't pin one
+	key crypto.PublicKey
+}
+
+// Read the JWKS from a file or URL, whichever is configured
+func loadJwks(cfg Config, client *http.Client) (map[string]verificationKey, error) {
+	var body io.ReadCloser
+	if cfg.JwksFile != "" {
+		f, err := os.Open(cfg.JwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open JWKS file: %w", err)
+		}
+		body = f
+	} else {
+		resp, err := client.Get(cfg.JwksUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := map[string]verificationKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			// skip keys we can't use instead of failing the whole set
+			fmt.Printf("Skipping JWKS key %q: %v\n", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = verificationKey{alg: k.Alg, key: pub}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing keys")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var check ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, check = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, check = elliptic.P384(), ecdh.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		// ecdh rejects points which are not on the curve
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, fmt.Errorf("EC point too large")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		if _, err := check.NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
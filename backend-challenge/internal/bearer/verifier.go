@@ -0,0 +1,338 @@
+package bearer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// "jwt" block of config.json. Set either JwksFile or JwksUrl.
+type Config struct {
+	JwksFile       string `json:"jwks_file"`
+	JwksUrl        string `json:"jwks_url"`
+	Issuer         string `json:"issuer"`
+	Audience       string `json:"audience"`
+	CustomerClaim  string `json:"customer_claim"`
+	ScopeClaim     string `json:"scope_claim"`
+	RoleClaim      string `json:"role_claim"`
+	LeewaySeconds  int    `json:"leeway_seconds"`
+	RefreshSeconds int    `json:"refresh_seconds"`
+}
+
+func (c Config) Enabled() bool {
+	return c.JwksFile != "" || c.JwksUrl != ""
+}
+
+// Caller identified by a verified token
+type Identity struct {
+	CustomerId string
+	Scopes     []string
+	Roles      []string
+	ExpiresAt  time.Time
+}
+
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+type algorithm struct {
+	hash crypto.Hash
+	ec   bool
+}
+
+// Only asymmetric algorithms, "none" and HMAC are never accepted
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, false},
+	"RS384": {crypto.SHA384, false},
+	"RS512": {crypto.SHA512, false},
+	"ES256": {crypto.SHA256, true},
+	"ES384": {crypto.SHA384, true},
+}
+
+// Verifies JWTs against the keys of a JWKS
+type Verifier struct {
+	cfg     Config
+	client  *http.Client
+	leeway  time.Duration
+	refresh time.Duration
+	now     func() time.Time
+
+	mu         sync.RWMutex
+	keys       map[string]verificationKey
+	fetched    time.Time
+	refreshing chan struct{} // closed once the JWKS being fetched is in, nil when none is
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if cfg.CustomerClaim == "" {
+		cfg.CustomerClaim = "sub"
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "role"
+	}
+	if cfg.RefreshSeconds <= 0 {
+		cfg.RefreshSeconds = 300
+	}
+
+	v := &Verifier{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		leeway:  time.Duration(cfg.LeewaySeconds) * time.Second,
+		refresh: time.Duration(cfg.RefreshSeconds) * time.Second,
+		now:     time.Now,
+	}
+
+	keys, err := loadJwks(cfg, v.client)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = v.now()
+	return v, nil
+}
+
+// Find the key for kid, reloading the JWKS at most once per refresh interval
+// so rotated keys are picked up. The JWKS is fetched without holding the
+// lock: tokens of known keys are verified with the keys at hand meanwhile,
+// only unknown keys wait for the fetch.
+func (v *Verifier) key(kid string) (verificationKey, bool) {
+	v.mu.RLock()
+	k, ok := v.lookup(kid)
+	stale := v.refreshDue(v.now())
+	v.mu.RUnlock()
+	if !stale {
+		return k, ok
+	}
+
+	done := v.reload()
+	if ok {
+		return k, ok
+	}
+	<-done
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.lookup(kid)
+}
+
+// Start fetching the JWKS unless a fetch is in flight or it isn't due any
+// more. Returns a channel closed once the keys are in.
+func (v *Verifier) reload() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.refreshing != nil {
+		return v.refreshing
+	}
+	done := make(chan struct{})
+	if !v.refreshDue(v.now()) {
+		close(done)
+		return done
+	}
+	v.refreshing = done
+	v.fetched = v.now()
+
+	go func() {
+		keys, err := loadJwks(v.cfg, v.client)
+		if err != nil {
+			// keep serving with the keys we have
+			fmt.Println("Failed refreshing JWKS. Error:", err)
+		}
+
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+		}
+		v.refreshing = nil
+		v.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+func (v *Verifier) refreshDue(now time.Time) bool {
+	return now.Sub(v.fetched) >= v.refresh
+}
+
+// Tokens without kid are accepted when the set has a single key
+func (v *Verifier) lookup(kid string) (verificationKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// Check the signature and claims of a compact JWT
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	key, ok := v.key(header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("%w: algorithm does not match key", ErrInvalidToken)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := verifySignature(alg, key.key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	return v.identity(claims)
+}
+
+func (v *Verifier) identity(claims map[string]any) (*Identity, error) {
+	now := v.now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(v.leeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.leeway).Before(nbf) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	}
+	if v.cfg.Audience != "" && !containsString(claims["aud"], v.cfg.Audience) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+
+	customerId, _ := claims[v.cfg.CustomerClaim].(string)
+	if customerId == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.CustomerClaim)
+	}
+
+	return &Identity{
+		CustomerId: customerId,
+		Scopes:     stringList(claims[v.cfg.ScopeClaim]),
+		Roles:      stringList(claims[v.cfg.RoleClaim]),
+		ExpiresAt:  exp,
+	}, nil
+}
+
+func verifySignature(alg algorithm, key crypto.PublicKey, signed, sig []byte) error {
+	h := alg.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	if alg.ec {
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm does not match key")
+		}
+		// JWS carries the raw r || s, not ASN.1
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("bad signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("algorithm does not match key")
+	}
+	if err := rsa.VerifyPKCS1v15(pub, alg.hash, digest, sig); err != nil {
+		return fmt.Errorf("bad signature")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// Claims like aud and scope can be a single string or a list
+func stringList(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return strings.Fields(t)
+	case []any:
+		var out []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(v any, want string) bool {
+	if s, ok := v.(string); ok {
+		return s == want
+	}
+	for _, s := range stringList(v) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package bearer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJwk(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "alg": "RS256", "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+}
+
+func ecJwk(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32)))}
+}
+
+func writeJwks(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	b, _ := json.Marshal(map[string]any{"keys": keys})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Build a compact JWT signed with key
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	v, err := NewVerifier(Config{
+		JwksFile: writeJwks(t, rsaJwk("rsa1", &rsaKey.PublicKey), ecJwk("ec1", &ecKey.PublicKey)),
+		Issuer:   "https://idp.example",
+		Audience: "kart",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub":   "customer-42",
+			"iss":   "https://idp.example",
+			"aud":   []string{"kart", "other"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "create_order read_orders",
+		}
+	}
+
+	identity, err := v.Verify(sign(t, "RS256", "rsa1", rsaKey, valid()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if identity.CustomerId != "customer-42" || len(identity.Scopes) != 2 {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	if _, err := v.Verify(sign(t, "ES256", "ec1", ecKey, valid())); err != nil {
+		t.Errorf("Expected EC token to verify, got %v", err)
+	}
+
+	expired := valid()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAud := valid()
+	wrongAud["aud"] = "someone-else"
+	wrongIss := valid()
+	wrongIss["iss"] = "https://evil.example"
+	noSub := valid()
+	delete(noSub, "sub")
+
+	tests := map[string]string{
+		"expired":        sign(t, "RS256", "rsa1", rsaKey, expired),
+		"wrong audience": sign(t, "RS256", "rsa1", rsaKey, wrongAud),
+		"wrong issuer":   sign(t, "RS256", "rsa1", rsaKey, wrongIss),
+		"no subject":     sign(t, "RS256", "rsa1", rsaKey, noSub),
+		"wrong key":      sign(t, "RS256", "rsa1", otherKey, valid()),
+		"unknown kid":    sign(t, "RS256", "nope", rsaKey, valid()),
+		"alg mismatch":   sign(t, "ES256", "rsa1", ecKey, valid()),
+		"malformed":      "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	// "none" must never be accepted
+	header := b64([]byte(`{"alg":"none","kid":"rsa1"}`))
+	payload, _ := json.Marshal(valid())
+	if _, err := v.Verify(header + "." + b64(payload) + "."); err == nil {
+		t.Error("Expected unsigned token to be rejected")
+	}
+}
+
+func TestVerifier_RefreshFromUrl(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	current := rsaJwk("old", &oldKey.PublicKey)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{current}})
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(Config{JwksUrl: srv.URL, RefreshSeconds: 60})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	claims := map[string]any{"sub": "customer-1", "exp": now.Add(time.Hour).Unix()}
+	if _, err := v.Verify(sign(t, "RS256", "old", oldKey, claims)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// IdP rotates its key, picked up once the refresh interval passed
+	current = rsaJwk("new", &newKey.PublicKey)
+	token := sign(t, "RS256", "new", newKey, claims)
+	if _, err := v.Verify(token); err == nil {
+		t.Error("Expected JWKS not to be refetched before the refresh interval")
+	}
+	now = now.Add(time.Minute)
+	if _, err := v.Verify(token); err != nil {
+		t.Errorf("Expected rotated key to verify, got %v", err)
+	}
+}
+
+// A slow JWKS endpoint doesn't hold up tokens of keys already known
+func TestVerifier_SlowRefresh(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := map[string]any{"keys": []map[string]string{rsaJwk("rsa1", &key.PublicKey)}}
+	release := make(chan struct{})
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if fetches > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	v, err := NewVerifier(Config{JwksUrl: srv.URL, RefreshSeconds: 60})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Now().Add(time.Minute)
+	v.now = func() time.Time { return now }
+
+	claims := map[string]any{"sub": "customer-1", "exp": now.Add(time.Hour).Unix()}
+	token := sign(t, "RS256", "rsa1", key, claims)
+	verified := make(chan error)
+	go func() {
+		for range 2 {
+			_, err := v.Verify(token)
+			verified <- err
+		}
+	}()
+	for range 2 {
+		select {
+		case err := <-verified:
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the token verified while the JWKS is fetched")
+		}
+	}
+}
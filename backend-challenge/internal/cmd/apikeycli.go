@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/apikey"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+)
+
+// API key seeded into an empty key store, for local development
+type ApiKeySeed struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+}
+
+const apiKeyUsage = `usage: go run . apikey <command> [flags]
+
+commands:
+  issue  -name NAME -role ROLE -scopes SCOPES [-expires DURATION]   issue a new key
+  rotate -name NAME [-grace DURATION]                               issue a new key, expiring the old ones after the grace period
+  revoke -id ID                                                     revoke a key immediately
+  list                                                              list keys
+
+roles:  ` + "customer, staff, kitchen, manager, admin" + `
+scopes: ` + "create_order, read_orders, admin"
+
+// Insert the seed keys when no key has been issued yet
+func seedApiKeys(ctx context.Context, store repo.ApiKeyRepository, seeds []ApiKeySeed) {
+	if count, err := store.CountApiKeys(ctx); err != nil || count > 0 {
+		return
+	}
+
+	for _, seed := range seeds {
+		role, err := rbac.ParseRole(seed.Role)
+		if err != nil {
+			panic(fmt.Errorf("invalid api_key_seed %q in config.json: %w", seed.Name, err))
+		}
+		scopes, err := apikey.ParseScopes(strings.Join(seed.Scopes, ","))
+		if err != nil {
+			panic(fmt.Errorf("invalid api_key_seed %q in config.json: %w", seed.Name, err))
+		}
+		key := model.ApiKey{Name: seed.Name, Prefix: apikey.DisplayPrefix(seed.Key), Role: string(role), Scopes: scopes}
+		if _, err := store.CreateApiKey(ctx, key, apikey.Hash(seed.Key)); err != nil {
+			panic(fmt.Errorf("failed seeding API key %q: %w", seed.Name, err))
+		}
+		fmt.Println("Seeded API key", seed.Name)
+	}
+}
+
+// Entry point of "apikey" subcommand, returns the exit code
+func runApiKeyCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		return 2
+	}
+
+	store := repo.InitialiseApiKeyRepository()
+	ctx := audit.WithActor(context.Background(), cliActor())
+	var err error
+	switch args[0] {
+	case "issue":
+		err = issueApiKey(ctx, store, args[1:])
+	case "rotate":
+		err = rotateApiKey(ctx, store, args[1:])
+	case "revoke":
+		err = revokeApiKey(ctx, store, args[1:])
+	case "list":
+		err = listApiKeys(ctx, store)
+	default:
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// Key changes made from the command line are audited as the OS user
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+func issueApiKey(ctx context.Context, store repo.ApiKeyRepository, args []string) error {
+	fs := flag.NewFlagSet("issue", flag.ContinueOnError)
+	name := fs.String("name", "", "name of the key owner")
+	roleName := fs.String("role", string(rbac.RoleCustomer), "role of the key owner")
+	scopeList := fs.String("scopes", "", "comma separated scopes")
+	expires := fs.Duration("expires", 0, "lifetime of the key, e.g. 720h (default never)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	role, err := rbac.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+	scopes, err := apikey.ParseScopes(*scopeList)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	if *expires > 0 {
+		t := time.Now().Add(*expires).UTC()
+		expiresAt = &t
+	}
+
+	return createApiKey(ctx, store, *name, role, scopes, expiresAt)
+}
+
+func rotateApiKey(ctx context.Context, store repo.ApiKeyRepository, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	name := fs.String("name", "", "name of the key owner")
+	grace := fs.Duration("grace", 24*time.Hour, "how long the old keys keep working")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// the new key gets the role, scopes and expiry of the newest live key of name
+	keys, err := store.ListApiKeys(ctx)
+	if err != nil {
+		return err
+	}
+	var current *model.ApiKey
+	for i := range keys {
+		if keys[i].Name == *name && keys[i].RevokedAt == nil {
+			current = &keys[i]
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("no live API key named %q", *name)
+	}
+
+	var expiresAt *time.Time
+	if current.ExpiresAt != nil {
+		t := time.Now().Add(current.ExpiresAt.Sub(current.CreatedAt)).UTC()
+		expiresAt = &t
+	}
+
+	expired, err := store.ExpireApiKeys(ctx, *name, time.Now().Add(*grace).UTC())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d old key(s) of %s expire in %v\n", expired, *name, *grace)
+
+	return createApiKey(ctx, store, *name, rbac.Role(current.Role), current.Scopes, expiresAt)
+}
+
+func createApiKey(ctx context.Context, store repo.ApiKeyRepository, name string, role rbac.Role, scopes []string, expiresAt *time.Time) error {
+	plain, err := apikey.Generate()
+	if err != nil {
+		return err
+	}
+
+	key, err := store.CreateApiKey(ctx, model.ApiKey{
+		Name:      name,
+		Prefix:    apikey.DisplayPrefix(plain),
+		Role:      string(role),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}, apikey.Hash(plain))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Issued %s API key %d for %s with scopes %s\n", role, key.Id, name, strings.Join(scopes, ","))
+	fmt.Println("Key (shown only once):", plain)
+	return nil
+}
+
+func revokeApiKey(ctx context.Context, store repo.ApiKeyRepository, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "id of the key, see list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := store.RevokeApiKey(ctx, *id); err != nil {
+		return err
+	}
+	fmt.Println("Revoked API key", *id)
+	return nil
+}
+
+func listApiKeys(ctx context.Context, store repo.ApiKeyRepository) error {
+	keys, err := store.ListApiKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tROLE\tSCOPES\tEXPIRES\tLAST USED\tREVOKED")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.Id, k.Name, k.Prefix, k.Role, strings.Join(k.Scopes, ","),
+			formatTime(k.ExpiresAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/bearer"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+	"github.com/priykumar/oolio-kart-challenge/internal/openapi"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+// The server run against a fresh database, checking every response against
+// the spec and recording which operations were exercised
+type contract struct {
+	t       *testing.T
+	router  *mux.Router
+	doc     *openapi3.T
+	key     *rsa.PrivateKey
+	covered map[string]bool
+}
+
+// A request of the suite and the status it must be answered with
+type call struct {
+	method string
+	target string
+	auth   string // API key, or "Bearer <token>"
+	body   string
+	status int
+}
+
+func newContract(t *testing.T) *contract {
+	cfg, err := loadConfig("../config/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// limits would only make the suite flaky, the guard never trips on it
+	cfg.RateLimits = middleware.RateLimitsConfig{}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "contract", "alg": "RS256",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	cfg.Jwt = bearer.Config{JwksFile: filepath.Join(t.TempDir(), "jwks.json"), CustomerClaim: "sub", ScopeClaim: "scope"}
+	if err := os.WriteFile(cfg.Jwt.JwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := repo.DatabasePath
+	repo.DatabasePath = filepath.Join(t.TempDir(), "kart.db")
+	t.Cleanup(func() {
+		repo.CloseDatabase()
+		repo.DatabasePath = path
+	})
+	db := repo.InitialiseDatabase()
+	keys := repo.InitialiseApiKeyRepository()
+	seedApiKeys(context.Background(), keys, cfg.ApiKeySeed)
+	health := service.NewHealthService(repo.InitialiseHealthRepository(), "test")
+	health.CouponsLoaded(nil)
+	policy, err := coupon.NewDiscountPolicy(cfg.discountPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newRouter(cfg, db, keys, health, policy, events.NewHub(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeSchemas(doc)
+	// the docs page is checked for its status and content type only
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.PlainBodyDecoder)
+
+	return &contract{t: t, router: r, doc: doc, key: key, covered: map[string]bool{}}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Bearer token of customer sub
+func (c *contract) token(sub string) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "contract", "typ": "JWT"})
+	payload, _ := json.Marshal(map[string]any{"sub": sub, "scope": "create_order read_orders", "exp": time.Now().Add(time.Hour).Unix()})
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return "Bearer " + signed + "." + b64(sig)
+}
+
+// Objects of the spec don't list every property they may have, so the suite
+// would not notice fields the server added. Schemas which don't say
+// otherwise are closed, turning such fields into failures.
+func closeSchemas(doc *openapi3.T) {
+	seen := map[*openapi3.Schema]bool{}
+	var visit func(ref *openapi3.SchemaRef)
+	visit = func(ref *openapi3.SchemaRef) {
+		if ref == nil || ref.Value == nil || seen[ref.Value] {
+			return
+		}
+		s := ref.Value
+		seen[s] = true
+		if len(s.Properties) > 0 && s.AdditionalProperties.Has == nil && s.AdditionalProperties.Schema == nil {
+			closed := false
+			s.AdditionalProperties.Has = &closed
+		}
+		for _, p := range s.Properties {
+			visit(p)
+		}
+		visit(s.Items)
+		visit(s.AdditionalProperties.Schema)
+		for _, list := range [][]*openapi3.SchemaRef{s.AllOf, s.AnyOf, s.OneOf} {
+			for _, sub := range list {
+				visit(sub)
+			}
+		}
+	}
+
+	for _, ref := range doc.Components.Schemas {
+		visit(ref)
+	}
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			for _, resp := range op.Responses.Map() {
+				for _, media := range resp.Value.Content {
+					visit(media.Schema)
+				}
+			}
+		}
+	}
+}
+
+// Send c and check the response against its status and the spec
+func (c *contract) do(tc call) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+	if tc.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+		if strings.HasPrefix(tc.target, "/coupon/import") {
+			req.Header.Set("Content-Type", "text/csv")
+		}
+	}
+	if strings.HasPrefix(tc.auth, "Bearer ") {
+		req.Header.Set("Authorization", tc.auth)
+	} else if tc.auth != "" {
+		req.Header.Set(middleware.API_KEY_HEADER, tc.auth)
+	}
+
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	if w.Code != tc.status {
+		c.t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.target, tc.status, w.Code, w.Body)
+	}
+
+	var match mux.RouteMatch
+	if !c.router.Match(req, &match) {
+		c.t.Errorf("%s %s: no route", tc.method, tc.target)
+		return w
+	}
+	path, _ := match.Route.GetPathTemplate()
+	path = middleware.SpecPath(path)
+	item := c.doc.Paths.Value(path)
+	if item == nil || item.GetOperation(tc.method) == nil {
+		c.t.Errorf("%s %s: operation missing from the spec", tc.method, path)
+		return w
+	}
+	c.covered[tc.method+" "+path] = true
+
+	// the handlers consumed the body, the spec only needs the parameters
+	req.Body = io.NopCloser(strings.NewReader(tc.body))
+	op := item.GetOperation(tc.method)
+	streamed := strings.HasPrefix(w.Header().Get("Content-Type"), "application/x-ndjson")
+	if streamed {
+		c.validateLines(tc, op, w)
+	}
+	if resp := op.Responses.Status(w.Code); w.Code >= 400 && resp != nil && len(resp.Value.Content) == 0 {
+		c.validateError(tc, w)
+	}
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: match.Vars,
+			Route:      &routers.Route{Spec: c.doc, Path: path, PathItem: item, Method: tc.method, Operation: op},
+		},
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+			ExcludeResponseBody:   streamed,
+		},
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), input); err != nil {
+		c.t.Errorf("%s %s: response %d doesn't match the spec: %v", tc.method, tc.target, w.Code, err)
+	}
+	return w
+}
+
+// Errors the spec lists without a body are still ApiResponses, as its
+// description says
+func (c *contract) validateError(tc call, w *httptest.ResponseRecorder) {
+	c.t.Helper()
+	var v any
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		c.t.Errorf("%s %s: error %d isn't JSON: %v", tc.method, tc.target, w.Code, err)
+	} else if err := c.doc.Components.Schemas["ApiResponse"].Value.VisitJSON(v); err != nil {
+		c.t.Errorf("%s %s: error %d isn't an ApiResponse: %v", tc.method, tc.target, w.Code, err)
+	}
+}
+
+// openapi3filter has no decoder for NDJSON, whose schema is that of a line
+func (c *contract) validateLines(tc call, op *openapi3.Operation, w *httptest.ResponseRecorder) {
+	c.t.Helper()
+	resp := op.Responses.Status(w.Code)
+	if resp == nil || resp.Value.Content.Get("application/x-ndjson") == nil {
+		c.t.Errorf("%s %s: NDJSON response %d missing from the spec", tc.method, tc.target, w.Code)
+		return
+	}
+	schema := resp.Value.Content.Get("application/x-ndjson").Schema.Value
+
+	for i, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		var v any
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			c.t.Errorf("%s %s: line %d isn't JSON: %v", tc.method, tc.target, i+1, err)
+		} else if err := schema.VisitJSON(v); err != nil {
+			c.t.Errorf("%s %s: line %d doesn't match the spec: %v", tc.method, tc.target, i+1, err)
+		}
+	}
+}
+
+// Decode the JSON body of w into v
+func (c *contract) decode(w *httptest.ResponseRecorder, v any) {
+	c.t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		c.t.Fatalf("Response isn't JSON: %v: %s", err, w.Body)
+	}
+}
+
+func TestContract(t *testing.T) {
+	c := newContract(t)
+
+	for _, tc := range []call{
+		{"GET", "/healthz", "", "", 200},
+		{"GET", "/readyz", "", "", 200},
+		{"GET", "/status", "", "", 200},
+		{"GET", "/metrics", "managertest", "", 200},
+		{"GET", "/metrics", "", "", 401},
+		{"GET", "/metrics", "kitchentest", "", 403},
+		{"GET", "/openapi.yaml", "", "", 200},
+		{"GET", "/openapi.json", "", "", 200},
+		{"GET", "/docs", "", "", 200},
+	} {
+		c.do(tc)
+	}
+
+	// products are made available at random when seeded
+	var products []struct{ Id string }
+	c.decode(c.do(call{"GET", "/product", "", "", 200}), &products)
+	if len(products) == 0 {
+		t.Fatal("No product available")
+	}
+	productId := products[0].Id
+
+	for _, tc := range []call{
+		{"GET", "/product/" + productId, "", "", 200},
+		{"GET", "/product/abc", "", "", 400},
+		{"GET", "/product/99999", "", "", 404},
+		{"GET", "/v1/product/" + productId, "", "", 200},
+		{"GET", "/v2/product", "", "", 200},
+		{"GET", "/v2/product/" + productId, "", "", 200},
+		{"GET", "/v2/product/abc", "", "", 400},
+		{"GET", "/v2/product/99999", "", "", 404},
+
+		{"POST", "/coupon", "admintest", `{"code":"CONTRACT1","discount":20}`, 201},
+		{"POST", "/coupon", "admintest", `{"code":"CONTRACT1"}`, 409},
+		{"POST", "/coupon", "admintest", `{"code":"NOT A CODE"}`, 422},
+		{"POST", "/coupon", "admintest", `{"code":"CONTRACT3","percent":20}`, 400},
+		{"POST", "/coupon", "apitest", `{"code":"CONTRACT3"}`, 403},
+		{"POST", "/coupon", "", `{"code":"CONTRACT3"}`, 401},
+		{"POST", "/coupon/import", "admintest", "code,discount\nCONTRACT2,15\n", 200},
+		{"GET", "/coupon?search=CONTRACT", "admintest", "", 200},
+		{"GET", "/coupon?limit=0", "admintest", "", 400},
+		{"GET", "/coupon/CONTRACT1", "apitest", "", 200},
+		{"PUT", "/coupon/CONTRACT1/discount", "admintest", `{"discount":25}`, 200},
+		{"PUT", "/coupon/CONTRACT1/discount", "admintest", `{"discount":150}`, 422},
+		{"PUT", "/coupon/CONTRACT1/discount", "admintest", `{"discount":"25"}`, 400},
+		{"PUT", "/coupon/UNKNOWN1/discount", "admintest", `{"discount":25}`, 404},
+		{"PUT", "/coupon/CONTRACT1/rules", "admintest", `{"minOrderValue":0,"maxUses":100}`, 200},
+		{"PUT", "/coupon/CONTRACT1/rules", "admintest", `{"maxUses":0}`, 422},
+		{"PUT", "/coupon/UNKNOWN1/rules", "admintest", `{"maxUses":1}`, 404},
+		{"POST", "/coupon/CONTRACT2/disable", "admintest", "", 204},
+		{"POST", "/coupon/CONTRACT2/enable", "admintest", "", 204},
+		{"POST", "/coupon/UNKNOWN1/disable", "admintest", "", 404},
+		{"POST", "/coupon/UNKNOWN1/enable", "admintest", "", 404},
+	} {
+		c.do(tc)
+	}
+
+	item := fmt.Sprintf(`{"productId":%q,"quantity":2}`, productId)
+	var order struct{ Id string }
+	c.decode(c.do(call{"POST", "/order", "apitest", `{"couponCode":"CONTRACT1","items":[` + item + `]}`, 200}), &order)
+
+	for _, tc := range []call{
+		{"POST", "/order", "apitest", `{"items":[]}`, 422},
+		{"POST", "/order", "apitest", `{"items":[`, 400},
+		{"POST", "/order", "", `{"items":[` + item + `]}`, 401},
+		{"POST", "/order", "kitchentest", `{"items":[` + item + `]}`, 403},
+		{"GET", "/order", "kitchentest", "", 200},
+		{"GET", "/order?limit=0", "kitchentest", "", 400},
+		{"GET", "/order", "", "", 401},
+		{"GET", "/order/" + order.Id, "kitchentest", "", 200},
+		{"GET", "/order/unknown", "kitchentest", "", 404},
+		{"GET", "/v1/order/" + order.Id, "kitchentest", "", 200},
+		{"POST", "/v2/order", "apitest", `{"couponCode":"CONTRACT1","items":[` + item + `]}`, 200},
+		{"POST", "/v2/order", "apitest", `{"items":[]}`, 422},
+		{"POST", "/v2/order", "", `{"items":[` + item + `]}`, 401},
+		{"GET", "/v2/order", "kitchentest", "", 200},
+		{"GET", "/v2/order?limit=0", "kitchentest", "", 400},
+		{"GET", "/v2/order/" + order.Id, "kitchentest", "", 200},
+		{"GET", "/v2/order/unknown", "kitchentest", "", 404},
+
+		// the order is completed first, so its event stream ends
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"status":"preparing","eta":"2030-01-01T12:00:00Z"}`, 200},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"eta":"2030-01-01T12:05:00Z"}`, 200},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"status":"completed"}`, 409},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"status":"placed"}`, 422},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{}`, 422},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"status":`, 400},
+		{"PUT", "/v2/order/" + order.Id + "/status", "apitest", `{"status":"ready"}`, 403},
+		{"PUT", "/v2/order/" + order.Id + "/status", "", `{"status":"ready"}`, 401},
+		{"PUT", "/v2/order/unknown/status", "kitchentest", `{"status":"ready"}`, 404},
+		{"PUT", "/v2/order/" + order.Id + "/status", "kitchentest", `{"status":"ready"}`, 200},
+		{"PUT", "/v2/order/" + order.Id + "/status", "managertest", `{"status":"completed"}`, 200},
+		{"GET", "/v2/order/" + order.Id + "/events", "kitchentest", "", 200},
+		{"GET", "/v2/order/" + order.Id + "/events", "apitest", "", 403},
+		{"GET", "/v2/order/" + order.Id + "/events", "", "", 401},
+		{"GET", "/v2/order/unknown/events", "kitchentest", "", 404},
+
+		{"GET", "/audit?entity=coupon", "admintest", "", 200},
+		{"GET", "/audit?limit=0", "admintest", "", 400},
+		{"GET", "/audit", "apitest", "", 403},
+		{"GET", "/audit/export?entity=order", "admintest", "", 200},
+	} {
+		c.do(tc)
+	}
+
+	alice, bob := c.token("alice"), c.token("bob")
+	for _, tc := range []call{
+		{"GET", "/customer/me", alice, "", 404},
+		{"POST", "/customer", alice, `{"name":"Alice","email":"alice@example.com"}`, 201},
+		{"POST", "/customer", alice, `{"name":"Alice","email":"alice@example.com"}`, 409},
+		{"POST", "/customer", bob, `{"name":"Bob","email":"not an address"}`, 422},
+		{"POST", "/customer", bob, `{"name":"Bob","email":"bob@example.com","age":3}`, 400},
+		{"POST", "/customer", "apitest", `{"name":"Bob","email":"bob@example.com"}`, 403},
+		{"GET", "/customer/me", alice, "", 200},
+		{"PUT", "/customer/me", alice, `{"name":"Alice B","email":"alice@example.com","phone":"+61 400 000 000"}`, 200},
+		{"PUT", "/customer/me", bob, `{"name":"Bob","email":"bob@example.com"}`, 404},
+		{"POST", "/order", alice, `{"items":[` + item + `]}`, 200},
+		{"GET", "/order", alice, "", 200},
+	} {
+		c.do(tc)
+	}
+
+	var missing []string
+	for path, item := range c.doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !c.covered[method+" "+path] {
+				missing = append(missing, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("Operations of the spec not exercised: %v", missing)
+	}
+}
+
+// Every route of the router is an operation of the spec, so the suite can't
+// be dodged by leaving a route out of both
+func TestContract_RoutesInSpec(t *testing.T) {
+	c := newContract(t)
+	c.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path = middleware.SpecPath(path)
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			item := c.doc.Paths.Value(path)
+			if item == nil || item.GetOperation(method) == nil {
+				t.Errorf("Route %s %s is missing from the spec", method, path)
+			}
+		}
+		return nil
+	})
+}
@@ -1,26 +1,108 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/bearer"
 	"github.com/priykumar/oolio-kart-challenge/internal/controller"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
 	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
 	"github.com/priykumar/oolio-kart-challenge/internal/repo"
 	"github.com/priykumar/oolio-kart-challenge/internal/service"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
 )
 
+// Set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 type Config struct {
-	CouponArtifacts []string `json:"coupon_artifacts"`
-	ValidTokenPath  string   `json:"valid_token_path"`
+	Server          ServerConfig                 `json:"server"`
+	CouponArtifacts []string                     `json:"coupon_artifacts"`
+	TokenDir        string                       `json:"token_dir"`
+	ValidTokenPath  string                       `json:"valid_token_path"`
+	DiscountPolicy  coupon.PolicyConfig          `json:"discount_policy"`
+	RateLimits      middleware.RateLimitsConfig  `json:"rate_limits"`
+	CouponGuard     middleware.CouponGuardConfig `json:"coupon_guard"`
+	ApiKeySeed      []ApiKeySeed                 `json:"api_key_seed"`
+	Jwt             bearer.Config                `json:"jwt"`
+	Tracing         tracing.Config               `json:"tracing"`
+	Validation      middleware.ValidationConfig  `json:"request_validation"`
+	Versions        middleware.VersionsConfig    `json:"api_versions"`
+	CORS            middleware.CORSConfig        `json:"cors"`
+	Catalog         CatalogConfig                `json:"catalog"`
+	Compression     middleware.CompressionConfig `json:"compression"`
+	OrderEvents     OrderEventsConfig            `json:"order_events"`
+	// Taxes charged on v2 orders
+	Taxes []controller.TaxRate `json:"taxes"`
 }
 
-func isTokenFileEmpty(filePath string) bool {
-	filePath = "../token/" + filePath
+type CatalogConfig struct {
+	// Cache-Control of product listings, no-cache when unset so clients
+	// revalidate with the ETag
+	CacheControl string `json:"cache_control"`
+	// How long the product listing is kept in memory, for products changed
+	// outside the server, which only writes them when seeding the database.
+	// 0 keeps it for as long as the server runs.
+	CacheTTLSeconds int `json:"cache_ttl_seconds"`
+}
+
+type OrderEventsConfig struct {
+	// Gap between heartbeats of an idle order event stream, 15 when unset
+	HeartbeatSeconds int `json:"heartbeat_seconds"`
+	// Longest an order event stream stays open before the client has to
+	// reconnect and authenticate again, 1800 when unset
+	MaxStreamSeconds int `json:"max_stream_seconds"`
+	// Latest events kept for clients resuming with Last-Event-ID, 1024 when
+	// unset
+	HistorySize int `json:"history_size"`
+}
+
+// The discount policy with its CSV mapping, configured relative to the token
+// directory, resolved
+func (c Config) discountPolicy() coupon.PolicyConfig {
+	policy := c.DiscountPolicy
+	if policy.CsvPath != "" {
+		policy.CsvPath = c.tokenPath(policy.CsvPath)
+	}
+	return policy
+}
+
+// Read and parse the config file
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	configFile, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to open config.json: %w", err)
+	}
+	defer configFile.Close()
+
+	if err := json.NewDecoder(configFile).Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to decode config.json: %w", err)
+	}
+	return cfg, nil
+}
+
+// Path of a file in the token directory, ../token when unset
+func (c Config) tokenPath(name string) string {
+	dir := c.TokenDir
+	if dir == "" {
+		dir = "../token"
+	}
+	return filepath.Join(dir, name)
+}
 
+func isTokenFileEmpty(filePath string) bool {
 	stat, err := os.Stat(filePath)
 	if err != nil || stat.Size() == 0 {
 		return true
@@ -30,36 +112,97 @@ func isTokenFileEmpty(filePath string) bool {
 }
 
 func main() {
-	db := repo.InitialiseDatabase()
-	psvc := service.NewProductService(db)
-	osvc := service.NewOrderService(db)
-	p := controller.NewProductController(psvc)
-	s := controller.NewOrderController(osvc)
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		os.Exit(runApiKeyCommand(os.Args[2:]))
+	}
 
-	// read and parse config file
-	configFile, err := os.Open("../config/config.json")
+	cfg, err := loadConfig("../config/config.json")
 	if err != nil {
-		panic(fmt.Errorf("failed to open config.json: %w", err))
+		panic(err)
 	}
-	defer configFile.Close()
 
-	var cfg Config
-	if err := json.NewDecoder(configFile).Decode(&cfg); err != nil {
-		panic(fmt.Errorf("failed to decode config.json: %w", err))
+	fmt.Println("Coupon artifacts:", cfg.CouponArtifacts)
+
+	shutdownTracing, err := tracing.Setup(cfg.Tracing, version)
+	if err != nil {
+		panic(fmt.Errorf("invalid tracing in config.json: %w", err))
 	}
 
-	fmt.Println("Coupon artifacts:", cfg.CouponArtifacts)
+	policy, err := coupon.NewDiscountPolicy(cfg.discountPolicy())
+	if err != nil {
+		panic(fmt.Errorf("invalid discount_policy in config.json: %w", err))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	db := repo.InitialiseDatabase()
+	hsvc := service.NewHealthService(repo.InitialiseHealthRepository(), version)
+	keys := repo.InitialiseApiKeyRepository()
+	// ctx carries no principal, so the keys seeded here and the coupons loaded
+	// below are audited as the system
+	seedApiKeys(ctx, keys, cfg.ApiKeySeed)
 
-	if isEmpty := isTokenFileEmpty(cfg.ValidTokenPath); isEmpty {
+	// order event streams end on shutdown rather than hold up the draining
+	hub := events.NewHub(cfg.OrderEvents.HistorySize)
+	context.AfterFunc(ctx, hub.Close)
+
+	r, err := newRouter(cfg, db, keys, hsvc, policy, hub)
+	if err != nil {
+		panic(err)
+	}
+
+	go loadCoupons(ctx, db, cfg, policy, hsvc)
+	code := run(ctx, cfg.Server.withDefaults(), r)
+	stop()
+
+	// spans of the drained requests are still to be exported
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		fmt.Println("Failed to flush traces. Error:", err)
+	}
+	cancel()
+	os.Exit(code)
+}
+
+// Derive the valid coupon codes from the artifacts when needed and store
+// them. This can take long, so it runs while the server already answers
+// probes, and readiness reports its outcome.
+func loadCoupons(ctx context.Context, db repo.KartRepository, cfg Config, policy coupon.DiscountPolicy, health service.HealthService) {
+	health.CouponsLoading()
+	validCodes := cfg.tokenPath(cfg.ValidTokenPath)
+	if isEmpty := isTokenFileEmpty(validCodes); isEmpty {
 		fmt.Println("Token files are empty, hence read token artifacts")
-		readArtifacts(cfg.CouponArtifacts)
+		start := time.Now()
+		readArtifacts(cfg.CouponArtifacts, validCodes)
+		metrics.CouponPipelineDuration.WithLabelValues("artifacts").Set(time.Since(start).Seconds())
 	}
-	db.PopulateCoupons(cfg.ValidTokenPath)
+	start := time.Now()
+	err := db.PopulateCoupons(ctx, validCodes, policy)
+	metrics.CouponPipelineDuration.WithLabelValues("populate").Set(time.Since(start).Seconds())
+	if err != nil {
+		fmt.Println("Failed loading coupons. Error:", err)
+	}
+	health.CouponsLoaded(err)
+}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/product", p.GetProductHandler).Methods("GET")
-	r.HandleFunc("/product/{productId}", p.GetProductByIdHandler).Methods("GET")
-	r.Handle("/order", middleware.ApiKeyMiddleware(http.HandlerFunc(s.PlaceOrderHandler))).Methods("POST")
+// Serve until ctx is done, i.e. SIGINT or SIGTERM, and close the database
+// afterwards. Returns the exit code, non-zero when the listener failed or
+// requests had to be cut off.
+func run(ctx context.Context, cfg ServerConfig, h http.Handler) int {
+	code := 0
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		fmt.Println("Failed to listen on", cfg.Addr, "Error:", err)
+		code = 1
+	} else {
+		fmt.Println("Listening on", ln.Addr())
+		if err := serve(ctx, newServer(cfg, h), ln, cfg.shutdownTimeout()); err != nil {
+			fmt.Println("Server error:", err)
+			code = 1
+		}
+	}
 
-	http.ListenAndServe(":8080", r)
+	if err := repo.CloseDatabase(); err != nil {
+		fmt.Println("Failed to close database. Error:", err)
+		code = 1
+	}
+	return code
 }
@@ -5,8 +5,11 @@ import (
 	"compress/gzip"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
 )
 
 // func init() {
@@ -33,10 +36,13 @@ func processFile(path string, counts map[string]int, mu *sync.Mutex, wg *sync.Wa
 	buf := make([]byte, 0, 4*1024*1024) // 4MB buffer
 	scanner.Buffer(buf, 4*1024*1024)
 
+	var lines, candidates float64
 	for scanner.Scan() {
+		lines++
 		code := strings.TrimSpace(scanner.Text())
 		l := len(code)
 		if l >= 8 && l <= 10 {
+			candidates++
 			mu.Lock()
 			counts[code]++
 			mu.Unlock()
@@ -45,9 +51,13 @@ func processFile(path string, counts map[string]int, mu *sync.Mutex, wg *sync.Wa
 	if err := scanner.Err(); err != nil {
 		panic(err)
 	}
+
+	file := filepath.Base(path)
+	metrics.ArtifactLines.WithLabelValues(file).Add(lines)
+	metrics.ArtifactCandidates.WithLabelValues(file).Add(candidates)
 }
 
-func readArtifacts(files []string) {
+func readArtifacts(files []string, outputPath string) {
 	fmt.Println("I'm here")
 
 	counts := make(map[string]int, 10_000_000) // preallocate
@@ -62,7 +72,6 @@ func readArtifacts(files []string) {
 	wg.Wait()
 
 	// Write valid codes (present in at least 2 files)
-	outputPath := "../token/valid_codes.txt"
 	out, err := os.Create(outputPath)
 	if err != nil {
 		panic(err)
@@ -70,10 +79,15 @@ func readArtifacts(files []string) {
 	defer out.Close()
 
 	writer := bufio.NewWriter(out)
+	valid := 0
 	for code, cnt := range counts {
 		if cnt >= 2 {
+			valid++
 			writer.WriteString(code + "\n")
 		}
 	}
 	writer.Flush()
+
+	metrics.ArtifactDistinctCodes.Set(float64(len(counts)))
+	metrics.ArtifactValidCodes.Set(float64(valid))
 }
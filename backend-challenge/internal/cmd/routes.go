@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/bearer"
+	"github.com/priykumar/oolio-kart-challenge/internal/controller"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+	"github.com/priykumar/oolio-kart-challenge/internal/openapi"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+// Routes of the server, each with its permission and rate limit. health is
+// shared with the coupon loading, which reports into it. Order changes are
+// published to hub.
+func newRouter(cfg Config, db repo.KartRepository, keys repo.ApiKeyRepository, health service.HealthService, policy coupon.DiscountPolicy, hub *events.Hub) (*mux.Router, error) {
+	cacheControl := cfg.Catalog.CacheControl
+	if cacheControl == "" {
+		cacheControl = "no-cache"
+	}
+	p := controller.NewProductController(service.NewProductService(db, time.Duration(cfg.Catalog.CacheTTLSeconds)*time.Second), cacheControl)
+	for _, tax := range cfg.Taxes {
+		if tax.Name == "" || tax.Rate < 0 {
+			return nil, fmt.Errorf("invalid tax %q in config.json: needs a name and a rate of at least 0", tax.Name)
+		}
+	}
+	heartbeat, maxStream := cfg.OrderEvents.HeartbeatSeconds, cfg.OrderEvents.MaxStreamSeconds
+	if heartbeat <= 0 {
+		heartbeat = 15
+	}
+	if maxStream <= 0 {
+		maxStream = 1800
+	}
+	s := controller.NewOrderController(service.NewOrderService(db, hub), time.Duration(heartbeat)*time.Second, time.Duration(maxStream)*time.Second, cfg.Taxes)
+	a := controller.NewApi(p, s)
+	h := controller.NewHealthController(health)
+	d := controller.NewDocsController()
+	c := controller.NewCouponController(service.NewCouponService(db, policy))
+	cu := controller.NewCustomerController(service.NewCustomerService(db))
+	au := controller.NewAuditController(service.NewAuditService(db))
+
+	var verifier middleware.BearerVerifier
+	if cfg.Jwt.Enabled() {
+		v, err := bearer.NewVerifier(cfg.Jwt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt in config.json: %w", err)
+		}
+		verifier = v
+	}
+	auth := middleware.NewAuth(keys, verifier)
+	can := auth.Authorize
+
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded openapi.yaml: %w", err)
+	}
+	validator := middleware.NewRequestValidator(spec, cfg.Validation)
+
+	limits := middleware.NewRateLimits(cfg.RateLimits, middleware.NewMemoryRateLimitStore())
+	limit := limits.For
+	// Routes needing perm, limited per client IP before authenticating so
+	// failing credentials are limited too, and per caller after
+	authorized := func(perm rbac.Permission, route string, h http.Handler) http.Handler {
+		return limits.ForClient(route)(can(perm)(limits.ForCaller(route)(h)))
+	}
+
+	cors, err := middleware.NewCORS(cfg.CORS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors in config.json: %w", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(middleware.RequestId)
+	r.Use(middleware.Tracing("/healthz", "/readyz", "/metrics"))
+	r.Use(middleware.Metrics)
+	r.Use(middleware.NewCompression(cfg.Compression).Middleware)
+	r.Use(cors.Middleware)
+	r.Use(validator.Middleware)
+	if cors.Enabled() {
+		r.Methods(http.MethodOptions).HandlerFunc(cors.PreflightHandler)
+	}
+	r.HandleFunc("/healthz", h.LiveHandler).Methods("GET")
+	r.HandleFunc("/readyz", h.ReadyHandler).Methods("GET")
+	r.Handle("/status", limit("status")(http.HandlerFunc(h.StatusHandler))).Methods("GET")
+	r.Handle("/openapi.yaml", limit("docs")(http.HandlerFunc(d.SpecYamlHandler))).Methods("GET")
+	r.Handle("/openapi.json", limit("docs")(http.HandlerFunc(d.SpecJsonHandler))).Methods("GET")
+	r.Handle("/docs", limit("docs")(http.HandlerFunc(d.DocsHandler))).Methods("GET")
+	// Products and orders are served by every version under its prefix. The
+	// unversioned paths serve the default version unless Accept asks for
+	// another.
+	couponGuard := middleware.NewCouponGuard(cfg.CouponGuard, health.LoadingCoupons)
+	versions := map[string]versionHandlers{
+		"v1": {a.GetProductHandler, a.GetProductByIdHandler, a.PlaceOrderHandler, a.ListOrdersHandler, a.GetOrderHandler, nil, nil},
+		"v2": {a.ListProductsV2Handler, a.GetProductV2Handler, a.PlaceOrderV2Handler, a.ListOrdersV2Handler, a.GetOrderV2Handler, a.UpdateOrderStatusV2Handler, s.WatchOrderHandler},
+	}
+	supported := []string{"v1", "v2"}
+	addVersion := func(r *mux.Router, version string, unversioned bool) {
+		h := versions[version]
+		mark := cfg.Versions.Middleware(version)
+		if unversioned {
+			negotiate := middleware.NegotiateVersion(supported...)
+			versioned := mark
+			mark = func(next http.Handler) http.Handler { return negotiate(versioned(next)) }
+		}
+		route := func(path, method string, handler http.Handler) {
+			rt := r.Handle(path, mark(handler)).Methods(method)
+			if unversioned && version != middleware.DefaultVersion {
+				rt.MatcherFunc(middleware.AcceptsVersion(version))
+			}
+		}
+		route("/product", "GET", limit("product.list")(h.listProducts))
+		route("/product/{productId}", "GET", limit("product.get")(h.getProduct))
+		route("/order", "POST", authorized(rbac.OrderCreate, "order.create", couponGuard.BodyMiddleware(h.placeOrder)))
+		route("/order", "GET", authorized(rbac.OrderRead, "order.list", h.listOrders))
+		route("/order/{orderId}", "GET", authorized(rbac.OrderRead, "order.get", h.getOrder))
+		// The order lifecycle is only served under the prefix of the versions
+		// which have it, as the spec doesn't list it without one
+		if h.updateOrderStatus != nil && !unversioned {
+			route("/order/{orderId}/status", "PUT", authorized(rbac.OrderUpdate, "order.status", h.updateOrderStatus))
+			route("/order/{orderId}/events", "GET", authorized(rbac.OrderRead, "order.events", h.watchOrder))
+		}
+	}
+	for _, version := range supported {
+		addVersion(r.PathPrefix("/"+version).Subrouter(), version, false)
+	}
+	for _, version := range supported {
+		if version != middleware.DefaultVersion {
+			addVersion(r, version, true)
+		}
+	}
+	// last, as it takes the requests no other version matched
+	addVersion(r, middleware.DefaultVersion, true)
+
+	r.Handle("/customer", authorized(rbac.CustomerSelf, "customer.register", http.HandlerFunc(cu.RegisterHandler))).Methods("POST")
+	r.Handle("/customer/me", authorized(rbac.CustomerSelf, "customer.get", http.HandlerFunc(cu.GetHandler))).Methods("GET")
+	r.Handle("/customer/me", authorized(rbac.CustomerSelf, "customer.update", http.HandlerFunc(cu.UpdateHandler))).Methods("PUT")
+
+	r.Handle("/coupon", authorized(rbac.CouponManage, "coupon.list", http.HandlerFunc(c.ListCouponsHandler))).Methods("GET")
+	r.Handle("/coupon", authorized(rbac.CouponManage, "coupon.create", http.HandlerFunc(c.CreateCouponHandler))).Methods("POST")
+	r.Handle("/coupon/import", authorized(rbac.CouponImport, "coupon.import", http.HandlerFunc(c.ImportCouponsHandler))).Methods("POST")
+	r.Handle("/coupon/{code}", authorized(rbac.CouponRead, "coupon.lookup", couponGuard.Middleware(http.HandlerFunc(c.GetCouponHandler)))).Methods("GET")
+	r.Handle("/coupon/{code}/disable", authorized(rbac.CouponManage, "coupon.disable", http.HandlerFunc(c.DisableCouponHandler))).Methods("POST")
+	r.Handle("/coupon/{code}/enable", authorized(rbac.CouponManage, "coupon.enable", http.HandlerFunc(c.EnableCouponHandler))).Methods("POST")
+	r.Handle("/coupon/{code}/discount", authorized(rbac.CouponManage, "coupon.discount", http.HandlerFunc(c.OverrideDiscountHandler))).Methods("PUT")
+	r.Handle("/coupon/{code}/rules", authorized(rbac.CouponManage, "coupon.rules", http.HandlerFunc(c.UpdateRulesHandler))).Methods("PUT")
+
+	r.Handle("/audit", authorized(rbac.AuditRead, "audit.list", http.HandlerFunc(au.ListEventsHandler))).Methods("GET")
+	r.Handle("/audit/export", authorized(rbac.AuditRead, "audit.export", http.HandlerFunc(au.ExportEventsHandler))).Methods("GET")
+
+	r.Handle("/metrics", authorized(rbac.MetricsRead, "metrics", metrics.Handler())).Methods("GET")
+
+	return r, nil
+}
+
+// Handlers of the product and order operations of one API version
+type versionHandlers struct {
+	listProducts, getProduct, placeOrder, listOrders, getOrder http.HandlerFunc
+	// order lifecycle, nil for versions without it
+	updateOrderStatus, watchOrder http.HandlerFunc
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+)
+
+func TestRoutes_VersionNegotiation(t *testing.T) {
+	c := newContract(t)
+
+	tests := []struct {
+		target  string
+		accept  string
+		status  int
+		version string
+	}{
+		{"/product", "", 200, "v1"},
+		{"/product", "application/json", 200, "v1"},
+		{"/product", "application/vnd.kart.v2+json", 200, "v2"},
+		{"/product", "application/vnd.kart.v9+json", 406, ""},
+		{"/v1/product", "", 200, "v1"},
+		{"/v2/product", "", 200, "v2"},
+		// the path wins over Accept
+		{"/v1/product", "application/vnd.kart.v2+json", 200, "v1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %q: expected status %d, got %d", tt.target, tt.accept, tt.status, w.Code)
+			continue
+		}
+		if got := w.Header().Get(middleware.API_VERSION_HEADER); got != tt.version {
+			t.Errorf("%s %q: expected version %q, got %q", tt.target, tt.accept, tt.version, got)
+		}
+		if tt.status != 200 {
+			continue
+		}
+
+		var products []map[string]any
+		json.Unmarshal(w.Body.Bytes(), &products)
+		if len(products) == 0 {
+			t.Fatalf("%s: no product available", tt.target)
+		}
+		_, isMoney := products[0]["price"].(map[string]any)
+		if isMoney != (tt.version == "v2") {
+			t.Errorf("%s %q: unexpected price %v for %s", tt.target, tt.accept, products[0]["price"], tt.version)
+		}
+		deprecated := w.Header().Get("Deprecation") != ""
+		if deprecated != (tt.version == "v1") {
+			t.Errorf("%s %q: Deprecation %q for %s", tt.target, tt.accept, w.Header().Get("Deprecation"), tt.version)
+		}
+	}
+}
+
+// Preflights carry no api_key, so they must be answered before auth and the
+// validator, on every route the front end calls
+func TestRoutes_CORSPreflight(t *testing.T) {
+	c := newContract(t)
+
+	for _, target := range []string{"/order", "/v2/order", "/product/1", "/order/123"} {
+		req := httptest.NewRequest("OPTIONS", target, nil)
+		req.Header.Set("Origin", "http://localhost:5173")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type, api_key, idempotency-key")
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, req)
+
+		if w.Code != 204 {
+			t.Errorf("%s: expected status 204, got %d: %s", target, w.Code, w.Body)
+			continue
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+			t.Errorf("%s: expected the origin allowed, got %q", target, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/product", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	if w.Code != 200 || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Expected a CORS response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRoutes_Representations(t *testing.T) {
+	c := newContract(t)
+	get := func(target, accept, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// a list streamed as NDJSON, one product per line, compressed
+	w := get("/v2/product", "application/x-ndjson", "gzip")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/x-ndjson" || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected compressed NDJSON, got %d %v", w.Code, w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gz)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	for i, line := range lines {
+		var product struct {
+			Id    string         `json:"id"`
+			Price map[string]any `json:"price"`
+		}
+		if err := json.Unmarshal([]byte(line), &product); err != nil || product.Id == "" || product.Price["currency"] != "USD" {
+			t.Errorf("Line %d isn't a v2 product: %s", i+1, line)
+		}
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || !strings.HasSuffix(etag, `-v2-ndjson"`) {
+		t.Errorf("Expected a weak NDJSON ETag, got %s", etag)
+	}
+
+	// the JSON list is a different representation
+	w = get("/v2/product", "application/json", "")
+	var products []any
+	json.Unmarshal(w.Body.Bytes(), &products)
+	if len(products) != len(lines) || w.Header().Get("Content-Encoding") != "" || w.Header().Get("ETag") == strings.TrimPrefix(etag, "W/") {
+		t.Errorf("Expected %d uncompressed products with their own ETag, got %d %v", len(lines), len(products), w.Header())
+	}
+
+	// errors as the ApiResponse of the spec, in XML
+	w = get("/product/999999", "application/xml", "gzip")
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/xml" || !strings.Contains(w.Body.String(), "<ApiResponse><code>404</code>") {
+		t.Errorf("Expected an XML ApiResponse, got %d %v %s", w.Code, w.Header(), w.Body)
+	}
+}
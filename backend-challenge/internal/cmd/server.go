@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+type ServerConfig struct {
+	Addr                     string `json:"addr"`
+	ReadHeaderTimeoutSeconds int    `json:"read_header_timeout_seconds"`
+	ReadTimeoutSeconds       int    `json:"read_timeout_seconds"`
+	WriteTimeoutSeconds      int    `json:"write_timeout_seconds"`
+	IdleTimeoutSeconds       int    `json:"idle_timeout_seconds"`
+	ShutdownTimeoutSeconds   int    `json:"shutdown_timeout_seconds"`
+}
+
+// Fill in unset fields, so a config without a server block behaves sanely
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.Addr == "" {
+		c.Addr = ":8080"
+	}
+	if c.ReadHeaderTimeoutSeconds <= 0 {
+		c.ReadHeaderTimeoutSeconds = 5
+	}
+	if c.ReadTimeoutSeconds <= 0 {
+		c.ReadTimeoutSeconds = 15
+	}
+	if c.WriteTimeoutSeconds <= 0 {
+		c.WriteTimeoutSeconds = 60
+	}
+	if c.IdleTimeoutSeconds <= 0 {
+		c.IdleTimeoutSeconds = 120
+	}
+	if c.ShutdownTimeoutSeconds <= 0 {
+		c.ShutdownTimeoutSeconds = 30
+	}
+	return c
+}
+
+func (c ServerConfig) shutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+func newServer(cfg ServerConfig, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           h,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutSeconds) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+// Serve on ln until ctx is done, then stop accepting connections and wait up
+// to shutdownTimeout for in-flight requests. Requests still running at the
+// deadline are cut off, which rolls back their transactions. Returns an error
+// when the listener fails or the deadline is hit.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown did not finish within %s: %w", shutdownTimeout, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+	fmt.Println("Server stopped")
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	return ln
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, newServer(ServerConfig{}.withDefaults(), h), ln, time.Second) }()
+
+	resp := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resp <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		resp <- string(body)
+	}()
+
+	<-started
+	cancel()
+	if got := <-resp; got != "done" {
+		t.Errorf("Expected in-flight request to finish, got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestServe_ShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, newServer(ServerConfig{}.withDefaults(), h), ln, 50*time.Millisecond) }()
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+	if err := <-served; err == nil {
+		t.Error("Expected error when requests outlive the shutdown deadline")
+	}
+}
+
+func TestServe_ListenerFailure(t *testing.T) {
+	ln := listen(t)
+	ln.Close()
+
+	err := serve(context.Background(), newServer(ServerConfig{}.withDefaults(), http.NotFoundHandler()), ln, time.Second)
+	if err == nil {
+		t.Error("Expected error from closed listener")
+	}
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/api"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/negotiate"
+)
+
+// The operations generated from the spec. Changing one of them in the spec
+// breaks this assertion until the controllers catch up.
+type apiServer struct {
+	*ProductController
+	*OrderController
+}
+
+var _ api.StrictServerInterface = apiServer{}
+
+// Generated handlers of the operations of both controllers, which bind the
+// parameters and encode the responses. They share one apiServer, so every
+// operation reaches the controller implementing it.
+type Api struct {
+	h *api.ServerInterfaceWrapper
+}
+
+func NewApi(p *ProductController, o *OrderController) *Api {
+	s := apiServer{ProductController: p, OrderController: o}
+	strict := api.NewStrictHandlerWithOptions(s, []api.StrictMiddlewareFunc{negotiateList}, api.StrictHTTPServerOptions{
+		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			generateResponse(w, r, myerror.NewValidationError(400, myerror.FieldError{Message: "Request body is not valid JSON"}))
+		},
+		ResponseErrorHandlerFunc: generateResponse,
+	})
+	return &Api{h: &api.ServerInterfaceWrapper{Handler: strictBodies{ServerInterface: strict, s: s}, ErrorHandlerFunc: paramError}}
+}
+
+// Parameters the generated handlers couldn't bind, e.g. a productId which
+// isn't a number
+func paramError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *api.InvalidParamFormatError
+	var required *api.RequiredParamError
+	switch {
+	case errors.As(err, &invalid):
+		generateResponse(w, r, myerror.NewValidationError(400, myerror.FieldError{Field: invalid.ParamName, Message: "Invalid " + invalid.ParamName + " supplied"}))
+	case errors.As(err, &required):
+		generateResponse(w, r, myerror.NewValidationError(400, myerror.FieldError{Field: required.ParamName, Message: "No " + required.ParamName + " provided"}))
+	default:
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: err.Error()})
+	}
+}
+
+type listFormatKey struct{}
+
+// The generated operations only see the context, which carries the media
+// type lists are to be sent as
+func negotiateList(f api.StrictHandlerFunc, operationID string) api.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		ctx = context.WithValue(ctx, listFormatKey{}, negotiate.MediaType(r, negotiate.JSON, negotiate.NDJSON))
+		return f(ctx, w, r, request)
+	}
+}
+
+// Whether the client prefers lists as NDJSON, one item per line
+func wantsLines(ctx context.Context) bool {
+	format, _ := ctx.Value(listFormatKey{}).(string)
+	return format == negotiate.NDJSON
+}
+
+// Encodes one item per line as the response is written, so a list is never
+// encoded as a whole
+type lineReader[T, U any] struct {
+	in  []T
+	f   func(T) U
+	buf bytes.Buffer
+}
+
+func lines[T, U any](in []T, f func(T) U) io.Reader {
+	return &lineReader[T, U]{in: in, f: f}
+}
+
+func (l *lineReader[T, U]) Read(p []byte) (int, error) {
+	for l.buf.Len() == 0 {
+		if len(l.in) == 0 {
+			return 0, io.EOF
+		}
+		if err := json.NewEncoder(&l.buf).Encode(l.f(l.in[0])); err != nil {
+			return 0, err
+		}
+		l.in = l.in[1:]
+	}
+	return l.buf.Read(p)
+}
+
+// The generated handlers decode bodies leniently. Operations with a body are
+// decoded the way decodeBody does instead and run on the apiServer directly.
+type strictBodies struct {
+	api.ServerInterface
+	s apiServer
+}
+
+func (b strictBodies) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var body api.OrderReq
+	if err := decodeBody(r, &body); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	resp, err := b.s.PlaceOrder(r.Context(), api.PlaceOrderRequestObject{Body: &body})
+	respond(w, r, resp, err, api.PlaceOrderResponseObject.VisitPlaceOrderResponse)
+}
+
+func (b strictBodies) PlaceOrderV2(w http.ResponseWriter, r *http.Request) {
+	var body api.OrderReq
+	if err := decodeBody(r, &body); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	resp, err := b.s.PlaceOrderV2(r.Context(), api.PlaceOrderV2RequestObject{Body: &body})
+	respond(w, r, resp, err, api.PlaceOrderV2ResponseObject.VisitPlaceOrderV2Response)
+}
+
+func (b strictBodies) UpdateOrderStatusV2(w http.ResponseWriter, r *http.Request, orderId string) {
+	var body api.OrderStatusUpdate
+	if err := decodeBody(r, &body); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	resp, err := b.s.UpdateOrderStatusV2(r.Context(), api.UpdateOrderStatusV2RequestObject{OrderId: orderId, Body: &body})
+	respond(w, r, resp, err, api.UpdateOrderStatusV2ResponseObject.VisitUpdateOrderStatusV2Response)
+}
+
+// Write the response of an operation, or its error
+func respond[T any](w http.ResponseWriter, r *http.Request, resp T, err error, visit func(T, http.ResponseWriter) error) {
+	if err == nil {
+		err = visit(resp, w)
+	}
+	if err != nil {
+		generateResponse(w, r, err)
+	}
+}
+
+func productResponse(p model.Product) api.Product {
+	return api.Product{
+		Id:       p.Id,
+		Name:     p.Name,
+		Price:    p.Price,
+		Category: p.Category,
+		Image: api.ProductImage{
+			Thumbnail: p.Image.Thumbnail,
+			Mobile:    p.Image.Mobile,
+			Tablet:    p.Image.Tablet,
+			Desktop:   p.Image.Desktop,
+		},
+	}
+}
+
+func orderResponse(o model.OrderResp) api.Order {
+	order := api.Order{
+		Id:        o.Id,
+		Total:     o.Total,
+		Discounts: o.Discount,
+		Items: mapSlice(o.OrderedProduct, func(item model.OrderedProduct) api.OrderItem {
+			return api.OrderItem{ProductId: item.ProductId, Quantity: item.Quantity}
+		}),
+		Products:  mapSlice(o.Products, productResponse),
+		CreatedAt: o.CreatedAt,
+	}
+	if o.CustomerId != "" {
+		order.CustomerId = &o.CustomerId
+	}
+	if o.CouponCode != "" {
+		order.CouponCode = &o.CouponCode
+	}
+	return order
+}
+
+// Prices are stored in US dollars
+const currency = "USD"
+
+func money(amount float64) api.Money {
+	return api.Money{Amount: strconv.FormatFloat(amount, 'f', 2, 64), Currency: currency}
+}
+
+func productV2Response(p model.Product) api.ProductV2 {
+	v1 := productResponse(p)
+	return api.ProductV2{Id: v1.Id, Name: v1.Name, Price: money(p.Price), Category: v1.Category, Image: v1.Image}
+}
+
+// A tax charged on orders, e.g. 0.1 for 10% of the subtotal after discount
+type TaxRate struct {
+	Name string  `json:"name"`
+	Rate float64 `json:"rate"`
+}
+
+// Amounts are added up in cents, so the parts of an order add up to its
+// total exactly
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func moneyCents(amount int64) api.Money {
+	return money(float64(amount) / 100)
+}
+
+// Each tax on taxable, in cents, and what they come to together
+func taxLines(taxable int64, taxes []TaxRate) ([]api.TaxLine, int64) {
+	var sum int64
+	lines := mapSlice(taxes, func(tax TaxRate) api.TaxLine {
+		amount := int64(math.Round(float64(taxable) * tax.Rate))
+		sum += amount
+		return api.TaxLine{Name: tax.Name, Rate: tax.Rate, Amount: moneyCents(amount)}
+	})
+	return lines, sum
+}
+
+// The subtotal is the price of every item times its quantity. Taxes are
+// charged on it after the discount and added to the total.
+func orderV2Response(o model.OrderResp, taxes []TaxRate) api.OrderV2 {
+	v1 := orderResponse(o)
+	prices := make(map[string]int64, len(o.Products))
+	for _, p := range o.Products {
+		prices[p.Id] = cents(p.Price)
+	}
+	var subtotal int64
+	for _, item := range o.OrderedProduct {
+		subtotal += prices[item.ProductId] * int64(item.Quantity)
+	}
+	discount := cents(o.Discount)
+	lines, tax := taxLines(subtotal-discount, taxes)
+
+	return api.OrderV2{
+		Id:         v1.Id,
+		Status:     api.OrderStatus(o.Status),
+		Eta:        o.Eta,
+		Items:      v1.Items,
+		Products:   mapSlice(o.Products, productV2Response),
+		Subtotal:   moneyCents(subtotal),
+		Discount:   moneyCents(discount),
+		Total:      moneyCents(subtotal - discount + tax),
+		Taxes:      lines,
+		CustomerId: v1.CustomerId,
+		CouponCode: v1.CouponCode,
+		CreatedAt:  v1.CreatedAt,
+	}
+}
+
+// Map every element of in, giving an empty rather than a nil slice, which
+// would be encoded as null
+func mapSlice[T, U any](in []T, f func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func orderStatusRequest(req api.OrderStatusUpdate) model.OrderStatusUpdate {
+	update := model.OrderStatusUpdate{Eta: req.Eta}
+	if req.Status != nil {
+		update.Status = string(*req.Status)
+	}
+	return update
+}
+
+func orderRequest(req api.OrderReq) model.OrderDetail {
+	oDetail := model.OrderDetail{OrderedProduct: make([]model.OrderedProduct, 0, len(req.Items))}
+	if req.CouponCode != nil {
+		oDetail.CouponCode = *req.CouponCode
+	}
+	for _, item := range req.Items {
+		oDetail.OrderedProduct = append(oDetail.OrderedProduct, model.OrderedProduct{ProductId: item.ProductId, Quantity: item.Quantity})
+	}
+	return oDetail
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/api"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Fields of v left at their zero value, by path
+func zeroFields(v reflect.Value, path string) []string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return []string{path}
+		}
+		return zeroFields(v.Elem(), path)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			break
+		}
+		var zero []string
+		for i := 0; i < v.NumField(); i++ {
+			zero = append(zero, zeroFields(v.Field(i), path+"."+v.Type().Field(i).Name)...)
+		}
+		return zero
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return []string{path}
+		}
+		return zeroFields(v.Index(0), path+"[0]")
+	}
+	if v.IsZero() {
+		return []string{path}
+	}
+	return nil
+}
+
+// A field added to the spec shows up in the generated types; the mapping
+// from the models must fill it
+func TestResponses_SetEveryField(t *testing.T) {
+	now := time.Now()
+	product := model.Product{Id: "1", Name: "Waffle", Price: 6.5, Category: "Waffle", Image: model.Image{Thumbnail: "t", Mobile: "m", Tablet: "t", Desktop: "d"}}
+	order := model.OrderResp{
+		Id:             "order-1",
+		Total:          13,
+		Discount:       1.3,
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 2}},
+		Products:       []model.Product{product},
+		CustomerId:     "customer-1",
+		CouponCode:     "HAPPYHRS",
+		CreatedAt:      &now,
+		Status:         model.OrderPreparing,
+		Eta:            &now,
+	}
+
+	for name, v := range map[string]any{
+		"Product":   productResponse(product),
+		"Order":     orderResponse(order),
+		"ProductV2": productV2Response(product),
+		"OrderV2":   orderV2Response(order, []TaxRate{{Name: "Sales tax", Rate: 0.1}}),
+	} {
+		if zero := zeroFields(reflect.ValueOf(v), name); len(zero) > 0 {
+			t.Errorf("Not set from the model: %v", zero)
+		}
+	}
+}
+
+// The subtotal comes from the items rather than the stored total, and taxes
+// are charged on it after the discount
+func TestOrderV2Response_Amounts(t *testing.T) {
+	order := model.OrderResp{
+		Total:          17.55,
+		Discount:       1.95,
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 3}, {ProductId: "2", Quantity: 1}},
+		Products:       []model.Product{{Id: "1", Price: 4.5}, {Id: "2", Price: 6}},
+	}
+	v2 := orderV2Response(order, []TaxRate{{Name: "State", Rate: 0.1}, {Name: "City", Rate: 0.05}})
+
+	amounts := []string{v2.Subtotal.Amount, v2.Discount.Amount, v2.Taxes[0].Amount.Amount, v2.Taxes[1].Amount.Amount, v2.Total.Amount}
+	want := []string{"19.50", "1.95", "1.76", "0.88", "20.19"}
+	if !reflect.DeepEqual(amounts, want) {
+		t.Errorf("Expected subtotal, discount, taxes and total %v, got %v", want, amounts)
+	}
+
+	if v2 := orderV2Response(order, nil); v2.Total.Amount != "17.55" || v2.Taxes == nil || len(v2.Taxes) != 0 {
+		t.Errorf("Expected the total without taxes and no tax lines, got %+v", v2)
+	}
+}
+
+func TestOrderRequest(t *testing.T) {
+	var req api.OrderReq
+	if err := json.Unmarshal([]byte(`{"couponCode":"HAPPYHRS","items":[{"productId":"1","quantity":2}]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	oDetail := orderRequest(req)
+	if oDetail.CouponCode != "HAPPYHRS" || len(oDetail.OrderedProduct) != 1 || oDetail.OrderedProduct[0] != (model.OrderedProduct{ProductId: "1", Quantity: 2}) {
+		t.Errorf("Unexpected order detail %+v", oDetail)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+type AuditController struct {
+	svc service.AuditService
+}
+
+func NewAuditController(svc service.AuditService) *AuditController {
+	return &AuditController{svc}
+}
+
+func parseAuditFilter(query url.Values) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		Actor:     strings.TrimSpace(query.Get("actor")),
+		Action:    strings.TrimSpace(query.Get("action")),
+		Entity:    strings.TrimSpace(query.Get("entity")),
+		EntityId:  strings.TrimSpace(query.Get("entityId")),
+		RequestId: strings.TrimSpace(query.Get("requestId")),
+		Limit:     50,
+	}
+
+	for name, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dst = &t
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 500 {
+			return filter, fmt.Errorf("limit must be between 1 and 500")
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("offset can't be negative")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// List audit events, newest first
+func (a *AuditController) ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	events, err := a.svc.ListEvents(r.Context(), filter)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(events)
+}
+
+// Export every matching audit event as JSON lines, oldest first. The events
+// are streamed, so the export isn't held in memory.
+func (a *AuditController) ExportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: err.Error()})
+		return
+	}
+
+	out := &countingWriter{w: w}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit_events.jsonl"`)
+	if err := a.svc.ExportEvents(r.Context(), filter, out); err != nil {
+		fmt.Println("Failed exporting audit events. Error:", err)
+		// once an event is out the status can't change, the export is cut short
+		if out.n == 0 {
+			w.Header().Del("Content-Disposition")
+			generateResponse(w, r, err)
+		}
+	}
+}
+
+type countingWriter struct {
+	w http.ResponseWriter
+	n int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += n
+	return n, err
+}
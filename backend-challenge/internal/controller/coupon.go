@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+type CouponController struct {
+	svc service.CouponService
+}
+
+func NewCouponController(svc service.CouponService) *CouponController {
+	return &CouponController{svc}
+}
+
+// Check whether a coupon code can currently be applied
+func (c *CouponController) GetCouponHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSpace(mux.Vars(r)["code"])
+	if code == "" {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No coupon code provided"})
+		return
+	}
+
+	status, err := c.svc.GetStatus(r.Context(), code)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	if !status.Valid && status.Rules == nil {
+		middleware.ReportInvalidCoupon(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(status)
+}
+
+// Override the discount of a single coupon code
+func (c *CouponController) OverrideDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSpace(mux.Vars(r)["code"])
+	if code == "" {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No coupon code provided"})
+		return
+	}
+
+	var req model.CouponDiscountReq
+	if err := decodeBody(r, &req); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	if req.Discount == nil {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "Discount not provided"})
+		return
+	}
+	if !coupon.IsValidDiscount(*req.Discount) {
+		generateResponse(w, r, myerror.KartError{Code: 422, Msg: "Discount must be at least 0.01 and at most 100"})
+		return
+	}
+
+	cpn, err := c.svc.OverrideDiscount(r.Context(), code, *req.Discount)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(cpn)
+}
+
+// Every invalid rule, joined
+func validateCouponRules(rules model.CouponRules) error {
+	var errs []error
+	if rules.ValidFrom != nil && rules.ValidTo != nil && !rules.ValidTo.After(*rules.ValidFrom) {
+		errs = append(errs, myerror.FieldError{Field: "validTo", Message: "validTo must be after validFrom"})
+	}
+	if rules.MaxUses != nil && *rules.MaxUses <= 0 {
+		errs = append(errs, myerror.FieldError{Field: "maxUses", Message: "maxUses must be greater than zero"})
+	}
+	if rules.MaxUsesPerCustomer != nil && *rules.MaxUsesPerCustomer <= 0 {
+		errs = append(errs, myerror.FieldError{Field: "maxUsesPerCustomer", Message: "maxUsesPerCustomer must be greater than zero"})
+	}
+	if rules.MinOrderValue < 0 {
+		errs = append(errs, myerror.FieldError{Field: "minOrderValue", Message: "minOrderValue can't be negative"})
+	}
+	return errors.Join(errs...)
+}
+
+// Replace the expiry, usage caps and minimum order value of a coupon code
+func (c *CouponController) UpdateRulesHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSpace(mux.Vars(r)["code"])
+	if code == "" {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No coupon code provided"})
+		return
+	}
+
+	var rules model.CouponRules
+	if err := decodeBody(r, &rules); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+	if err := validateCouponRules(rules); err != nil {
+		generateResponse(w, r, myerror.NewValidationError(422, err))
+		return
+	}
+
+	updated, err := c.svc.UpdateRules(r.Context(), code, rules)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Create a single coupon code
+func (c *CouponController) CreateCouponHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.CouponCreateReq
+	if err := decodeBody(r, &req); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	if !coupon.IsValidCode(req.Code) {
+		generateResponse(w, r, myerror.KartError{Code: 422, Msg: "Coupon code must be 1 to 32 letters, digits, '-' or '_'"})
+		return
+	}
+	if req.Discount != nil && !coupon.IsValidDiscount(*req.Discount) {
+		generateResponse(w, r, myerror.KartError{Code: 422, Msg: "Discount must be at least 0.01 and at most 100"})
+		return
+	}
+	if req.Rules != nil {
+		if err := validateCouponRules(*req.Rules); err != nil {
+			generateResponse(w, r, myerror.NewValidationError(422, err))
+			return
+		}
+	}
+
+	info, err := c.svc.CreateCoupon(r.Context(), req)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(info)
+}
+
+// Bulk import coupon codes from a CSV or newline separated body. The body is
+// read as a stream, so large files are never held in memory.
+func (c *CouponController) ImportCouponsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No request body found"})
+		return
+	}
+
+	result, err := c.svc.ImportCoupons(r.Context(), r.Body)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// List and search coupon codes along with their usage
+func (c *CouponController) ListCouponsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.CouponFilter{
+		Search: strings.TrimSpace(query.Get("search")),
+		Limit:  50,
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 500 {
+			generateResponse(w, r, myerror.KartError{Code: 400, Msg: "limit must be between 1 and 500"})
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			generateResponse(w, r, myerror.KartError{Code: 400, Msg: "offset can't be negative"})
+			return
+		}
+		filter.Offset = offset
+	}
+	if v := query.Get("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			generateResponse(w, r, myerror.KartError{Code: 400, Msg: "disabled must be true or false"})
+			return
+		}
+		filter.Disabled = &disabled
+	}
+
+	coupons, err := c.svc.ListCoupons(r.Context(), filter)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(coupons)
+}
+
+func (c *CouponController) DisableCouponHandler(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, true)
+}
+
+func (c *CouponController) EnableCouponHandler(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, false)
+}
+
+func (c *CouponController) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	code := strings.TrimSpace(mux.Vars(r)["code"])
+	if code == "" {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No coupon code provided"})
+		return
+	}
+
+	if err := c.svc.SetDisabled(r.Context(), code, disabled); err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Mock CouponService
+type mockCouponService struct {
+	err error
+}
+
+func (m *mockCouponService) CreateCoupon(ctx context.Context, req model.CouponCreateReq) (*model.CouponInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.CouponInfo{Code: req.Code}, nil
+}
+
+func (m *mockCouponService) ImportCoupons(context.Context, io.Reader) (*model.CouponImportResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.CouponImportResult{}, nil
+}
+
+func (m *mockCouponService) ListCoupons(context.Context, model.CouponFilter) ([]model.CouponInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []model.CouponInfo{}, nil
+}
+
+func (m *mockCouponService) SetDisabled(context.Context, string, bool) error {
+	return m.err
+}
+
+func (m *mockCouponService) GetStatus(ctx context.Context, code string) (*model.CouponStatus, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.CouponStatus{Code: code, Valid: true, DiscountType: "percentage", Discount: 18}, nil
+}
+
+func (m *mockCouponService) UpdateRules(ctx context.Context, code string, rules model.CouponRules) (*model.CouponRules, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &rules, nil
+}
+
+func (m *mockCouponService) OverrideDiscount(ctx context.Context, code string, discount float64) (*model.Coupon, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.Coupon{Code: code, Discount: discount}, nil
+}
+
+func TestOverrideDiscountHandler_Success(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+	req := httptest.NewRequest("PUT", "/coupon/HAPPYHRS/discount", bytes.NewBufferString(`{"discount": 18}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w := httptest.NewRecorder()
+
+	controller.OverrideDiscountHandler(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestOverrideDiscountHandler_Failure(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+
+	// Test missing discount
+	req := httptest.NewRequest("PUT", "/coupon/HAPPYHRS/discount", bytes.NewBufferString(`{}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w := httptest.NewRecorder()
+	controller.OverrideDiscountHandler(w, req)
+	if w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	// Test out of range discounts, including one which would be stored as 0
+	for _, body := range []string{`{"discount": 120}`, `{"discount": 0.001}`} {
+		req = httptest.NewRequest("PUT", "/coupon/HAPPYHRS/discount", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+		w = httptest.NewRecorder()
+		controller.OverrideDiscountHandler(w, req)
+		if w.Code != 422 {
+			t.Errorf("%s: expected status 422, got %d", body, w.Code)
+		}
+	}
+
+	// Test unknown coupon
+	controller = NewCouponController(&mockCouponService{err: myerror.KartError{Code: 404, Msg: "Coupon not found"}})
+	req = httptest.NewRequest("PUT", "/coupon/UNKNOWN1/discount", bytes.NewBufferString(`{"discount": 20}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "UNKNOWN1"})
+	w = httptest.NewRecorder()
+	controller.OverrideDiscountHandler(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestUpdateRulesHandler(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+
+	// Test success
+	req := httptest.NewRequest("PUT", "/coupon/HAPPYHRS/rules",
+		bytes.NewBufferString(`{"validFrom": "2025-01-01T00:00:00Z", "validTo": "2025-02-01T00:00:00Z", "maxUses": 100}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w := httptest.NewRecorder()
+	controller.UpdateRulesHandler(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	// Test window ending before it starts
+	req = httptest.NewRequest("PUT", "/coupon/HAPPYHRS/rules",
+		bytes.NewBufferString(`{"validFrom": "2025-02-01T00:00:00Z", "validTo": "2025-01-01T00:00:00Z"}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w = httptest.NewRecorder()
+	controller.UpdateRulesHandler(w, req)
+	if w.Code != 422 {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+}
+
+func TestGetCouponHandler(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+	req := httptest.NewRequest("GET", "/coupon/HAPPYHRS", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w := httptest.NewRecorder()
+
+	controller.GetCouponHandler(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	// Test service error
+	controller = NewCouponController(&mockCouponService{err: myerror.KartError{Code: 500, Msg: "Internal error"}})
+	w = httptest.NewRecorder()
+	controller.GetCouponHandler(w, req)
+	if w.Code != 500 {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestCreateCouponHandler(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+
+	// Test success
+	req := httptest.NewRequest("POST", "/coupon", bytes.NewBufferString(`{"code": "NEWCODE1", "discount": 20}`))
+	w := httptest.NewRecorder()
+	controller.CreateCouponHandler(w, req)
+	if w.Code != 201 {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+
+	// Test invalid code
+	req = httptest.NewRequest("POST", "/coupon", bytes.NewBufferString(`{"code": "NEW,CODE"}`))
+	w = httptest.NewRecorder()
+	controller.CreateCouponHandler(w, req)
+	if w.Code != 422 {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+
+	// Test existing code
+	controller = NewCouponController(&mockCouponService{err: myerror.KartError{Code: 409, Msg: "Coupon already exists"}})
+	req = httptest.NewRequest("POST", "/coupon", bytes.NewBufferString(`{"code": "NEWCODE1"}`))
+	w = httptest.NewRecorder()
+	controller.CreateCouponHandler(w, req)
+	if w.Code != 409 {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestListCouponsHandler(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+
+	req := httptest.NewRequest("GET", "/coupon?search=HAPPY&limit=10&disabled=false", nil)
+	w := httptest.NewRecorder()
+	controller.ListCouponsHandler(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	// Test invalid limit
+	req = httptest.NewRequest("GET", "/coupon?limit=0", nil)
+	w = httptest.NewRecorder()
+	controller.ListCouponsHandler(w, req)
+	if w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestDisableCouponHandler(t *testing.T) {
+	controller := NewCouponController(&mockCouponService{})
+	req := httptest.NewRequest("POST", "/coupon/HAPPYHRS/disable", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "HAPPYHRS"})
+	w := httptest.NewRecorder()
+
+	controller.DisableCouponHandler(w, req)
+
+	if w.Code != 204 {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+type CustomerController struct {
+	svc service.CustomerService
+}
+
+func NewCustomerController(svc service.CustomerService) *CustomerController {
+	return &CustomerController{svc}
+}
+
+// Every invalid field of the customer, joined
+func validateCustomer(req model.CustomerReq) error {
+	var errs []error
+	if req.Name == "" {
+		errs = append(errs, myerror.FieldError{Field: "name", Message: "name is required"})
+	} else if len(req.Name) > 100 {
+		errs = append(errs, myerror.FieldError{Field: "name", Message: "name can't be longer than 100 characters"})
+	}
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		errs = append(errs, myerror.FieldError{Field: "email", Message: "email is not a valid address"})
+	}
+	if len(req.Phone) > 32 {
+		errs = append(errs, myerror.FieldError{Field: "phone", Message: "phone can't be longer than 32 characters"})
+	}
+	return errors.Join(errs...)
+}
+
+// Customer accounts belong to bearer token subjects, API keys have no account
+func subject(r *http.Request) (string, error) {
+	p := middleware.PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKey != nil || p.CustomerId == "" {
+		return "", myerror.KartError{Code: 403, Msg: "Customer accounts require a bearer token"}
+	}
+	return p.CustomerId, nil
+}
+
+func decodeCustomer(r *http.Request) (model.CustomerReq, error) {
+	var req model.CustomerReq
+	if err := decodeBody(r, &req); err != nil {
+		return req, err
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Phone = strings.TrimSpace(req.Phone)
+	if err := validateCustomer(req); err != nil {
+		return req, myerror.NewValidationError(422, err)
+	}
+	return req, nil
+}
+
+// Register the customer holding the bearer token
+func (c *CustomerController) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := subject(r)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	req, err := decodeCustomer(r)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	customer, err := c.svc.Register(r.Context(), sub, req)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(customer)
+}
+
+// Get the account of the customer holding the bearer token
+func (c *CustomerController) GetHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := subject(r)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	customer, err := c.svc.Get(r.Context(), sub)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(customer)
+}
+
+// Update the account of the customer holding the bearer token
+func (c *CustomerController) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := subject(r)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	req, err := decodeCustomer(r)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	customer, err := c.svc.Update(r.Context(), sub, req)
+	if err != nil {
+		generateResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(customer)
+}
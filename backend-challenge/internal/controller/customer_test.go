@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Mock CustomerService
+type mockCustomerService struct {
+	subject string
+	req     model.CustomerReq
+	err     error
+}
+
+func (m *mockCustomerService) Register(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.subject, m.req = subject, req
+	return &model.Customer{Id: "cust-1", Name: req.Name, Email: req.Email}, nil
+}
+
+func (m *mockCustomerService) Get(ctx context.Context, subject string) (*model.Customer, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.Customer{Id: "cust-1"}, nil
+}
+
+func (m *mockCustomerService) Update(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	return m.Register(ctx, subject, req)
+}
+
+func TestValidateCustomer(t *testing.T) {
+	if err := validateCustomer(model.CustomerReq{Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	for _, req := range []model.CustomerReq{
+		{Email: "alice@example.com"},
+		{Name: "Alice", Email: "not-an-email"},
+		{Name: "Alice", Email: "Alice <alice@example.com>"},
+	} {
+		if err := validateCustomer(req); err == nil {
+			t.Errorf("Expected error for %+v, got nil", req)
+		}
+	}
+}
+
+func TestRegisterHandler(t *testing.T) {
+	mockSvc := &mockCustomerService{}
+	controller := NewCustomerController(mockSvc)
+	body, _ := json.Marshal(model.CustomerReq{Name: " Alice ", Email: "Alice@Example.com"})
+
+	// Bearer token customers register under their subject
+	req := httptest.NewRequest("POST", "/customer", bytes.NewBuffer(body))
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{CustomerId: "sub-1"}))
+	w := httptest.NewRecorder()
+	controller.RegisterHandler(w, req)
+	if w.Code != 201 {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+	if mockSvc.subject != "sub-1" || mockSvc.req.Name != "Alice" || mockSvc.req.Email != "alice@example.com" {
+		t.Errorf("Unexpected registration %q %+v", mockSvc.subject, mockSvc.req)
+	}
+
+	// API keys have no customer account
+	req = httptest.NewRequest("POST", "/customer", bytes.NewBuffer(body))
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{ApiKey: &model.ApiKey{Id: 1}}))
+	w = httptest.NewRecorder()
+	controller.RegisterHandler(w, req)
+	if w.Code != 403 {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+)
+
+// Decode the JSON body of r into v. Unknown fields, values of the wrong type
+// and trailing data are rejected with a 400 naming the field, since the
+// handlers may be reached without the spec validation in front of them.
+func decodeBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return myerror.KartError{Code: 400, Msg: "No request body found"}
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&json.RawMessage{}) != io.EOF {
+		err = fmt.Errorf("trailing data after the JSON body")
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	var unknown string
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, io.EOF):
+		return myerror.KartError{Code: 400, Msg: "No request body found"}
+	case errors.As(err, &tooLarge):
+		return err
+	case errors.As(err, &typeErr):
+		// encoding/json reports the path without array indices, e.g. items.quantity
+		return myerror.NewValidationError(400, myerror.FieldError{Field: typeErr.Field, Message: fmt.Sprintf("%s: unexpected %s", typeErr.Field, typeErr.Value)})
+	case scanUnknownField(err, &unknown):
+		return myerror.NewValidationError(400, myerror.FieldError{Field: unknown, Message: fmt.Sprintf("%s: property is unsupported", unknown)})
+	default:
+		return myerror.NewValidationError(400, myerror.FieldError{Message: "Request body is not valid JSON"})
+	}
+}
+
+// encoding/json has no error type for unknown fields, only this message
+func scanUnknownField(err error, field *string) bool {
+	_, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %q", field)
+	return scanErr == nil
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/openapi"
+)
+
+// Serves the embedded API spec and the page documenting it
+type DocsController struct{}
+
+func NewDocsController() *DocsController {
+	return &DocsController{}
+}
+
+func (d *DocsController) SpecYamlHandler(w http.ResponseWriter, r *http.Request) {
+	d.writeSpec(w, r, "application/yaml", openapi.YAML)
+}
+
+func (d *DocsController) SpecJsonHandler(w http.ResponseWriter, r *http.Request) {
+	d.writeSpec(w, r, "application/json", openapi.JSON)
+}
+
+func (d *DocsController) writeSpec(w http.ResponseWriter, r *http.Request, contentType string, render func(string) ([]byte, error)) {
+	spec, err := render(baseURL(r))
+	if err != nil {
+		generateResponse(w, r, fmt.Errorf("render openapi spec: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	// the servers block depends on the Host the spec was requested through
+	w.Header().Set("Vary", "Host")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// The docs page loads openapi.json from this server and has no other assets
+func (d *DocsController) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.DocsPage)
+}
+
+// URL the request reached this instance through. Forwarding headers are not
+// trusted, as for the client IP.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
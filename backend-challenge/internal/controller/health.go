@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
+)
+
+type HealthController struct {
+	svc service.HealthService
+}
+
+func NewHealthController(svc service.HealthService) *HealthController {
+	return &HealthController{svc}
+}
+
+func writeProbe(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	// probes must always see the current state
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Liveness: the process is up and serving
+func (h *HealthController) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, h.svc.Live())
+}
+
+// Readiness: 503 until the database is usable and coupons are loaded
+func (h *HealthController) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	health, ready := h.svc.Ready(r.Context())
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeProbe(w, code, health)
+}
+
+func (h *HealthController) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, h.svc.Status(r.Context()))
+}
@@ -1,61 +1,350 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/api"
 	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
 	"github.com/priykumar/oolio-kart-challenge/internal/service"
 )
 
 type OrderController struct {
 	svc service.OrderService
+	// Order event streams send a heartbeat when nothing else was sent for
+	// this long, and end after maxStream so clients authenticate again
+	heartbeat time.Duration
+	maxStream time.Duration
+	// Taxes charged on v2 orders
+	taxes []TaxRate
 }
 
-func NewOrderController(svc service.OrderService) *OrderController {
-	return &OrderController{svc}
+func NewOrderController(svc service.OrderService, heartbeat, maxStream time.Duration, taxes []TaxRate) *OrderController {
+	return &OrderController{svc: svc, heartbeat: heartbeat, maxStream: maxStream, taxes: taxes}
 }
 
+// Every invalid field of the order, joined
 func validateOrder(oDetail model.OrderDetail) error {
 	if len(oDetail.OrderedProduct) == 0 {
-		return fmt.Errorf("no product provided")
-	} else {
-		for _, od := range oDetail.OrderedProduct {
-			if strings.TrimSpace(od.ProductId) == "" {
-				return fmt.Errorf("product id not present in request")
-			} else if od.Quantity <= 0 {
-				return fmt.Errorf("quantity can't be negative or zero")
-			}
+		return myerror.FieldError{Field: "items", Message: "no product provided"}
+	}
+
+	var errs []error
+	for i, od := range oDetail.OrderedProduct {
+		if strings.TrimSpace(od.ProductId) == "" {
+			errs = append(errs, myerror.FieldError{Field: fmt.Sprintf("items[%d].productId", i), Message: "product id not present in request"})
+		}
+		if od.Quantity <= 0 {
+			errs = append(errs, myerror.FieldError{Field: fmt.Sprintf("items[%d].quantity", i), Message: "quantity can't be negative or zero"})
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	return nil
+func (a *Api) PlaceOrderHandler(w http.ResponseWriter, r *http.Request) {
+	a.h.PlaceOrder(w, r)
 }
 
-func (o *OrderController) PlaceOrderHandler(w http.ResponseWriter, r *http.Request) {
-	var oDetail model.OrderDetail
+// Get an order by orderId
+func (a *Api) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
+	a.h.GetOrder(w, r)
+}
 
-	if r.Body == nil {
-		generateResponse(w, myerror.KartError{Code: 400, Msg: "No request body found"})
-		return
+// List orders, newest first
+func (a *Api) ListOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	a.h.ListOrders(w, r)
+}
+
+func (a *Api) PlaceOrderV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.PlaceOrderV2(w, r)
+}
+
+func (a *Api) GetOrderV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.GetOrderV2(w, r)
+}
+
+func (a *Api) ListOrdersV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.ListOrdersV2(w, r)
+}
+
+// Move an order along its lifecycle or change its ETA
+func (a *Api) UpdateOrderStatusV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.UpdateOrderStatusV2(w, r)
+}
+
+func (o *OrderController) PlaceOrder(ctx context.Context, request api.PlaceOrderRequestObject) (api.PlaceOrderResponseObject, error) {
+	order, err := o.placeOrder(ctx, *request.Body)
+	if err != nil {
+		return nil, err
+	}
+	return api.PlaceOrder200JSONResponse(orderResponse(*order)), nil
+}
+
+func (o *OrderController) GetOrder(ctx context.Context, request api.GetOrderRequestObject) (api.GetOrderResponseObject, error) {
+	order, err := o.getOrder(ctx, request.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetOrder200JSONResponse(orderResponse(*order)), nil
+}
+
+func (o *OrderController) ListOrders(ctx context.Context, request api.ListOrdersRequestObject) (api.ListOrdersResponseObject, error) {
+	orders, err := o.listOrders(ctx, request.Params.Limit, request.Params.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if wantsLines(ctx) {
+		return api.ListOrders200ApplicationxNdjsonResponse{Body: lines(orders, orderResponse)}, nil
 	}
+	return api.ListOrders200JSONResponse(mapSlice(orders, orderResponse)), nil
+}
+
+// An order as v2 serves it, with its taxes broken out
+func (o *OrderController) orderV2(order model.OrderResp) api.OrderV2 {
+	return orderV2Response(order, o.taxes)
+}
+
+func (o *OrderController) PlaceOrderV2(ctx context.Context, request api.PlaceOrderV2RequestObject) (api.PlaceOrderV2ResponseObject, error) {
+	order, err := o.placeOrder(ctx, *request.Body)
+	if err != nil {
+		return nil, err
+	}
+	return api.PlaceOrderV2200JSONResponse(o.orderV2(*order)), nil
+}
 
-	json.NewDecoder(r.Body).Decode(&oDetail)
+func (o *OrderController) GetOrderV2(ctx context.Context, request api.GetOrderV2RequestObject) (api.GetOrderV2ResponseObject, error) {
+	order, err := o.getOrder(ctx, request.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetOrderV2200JSONResponse(o.orderV2(*order)), nil
+}
+
+func (o *OrderController) ListOrdersV2(ctx context.Context, request api.ListOrdersV2RequestObject) (api.ListOrdersV2ResponseObject, error) {
+	orders, err := o.listOrders(ctx, request.Params.Limit, request.Params.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if wantsLines(ctx) {
+		return api.ListOrdersV2200ApplicationxNdjsonResponse{Body: lines(orders, o.orderV2)}, nil
+	}
+	return api.ListOrdersV2200JSONResponse(mapSlice(orders, o.orderV2)), nil
+}
+
+func (o *OrderController) UpdateOrderStatusV2(ctx context.Context, request api.UpdateOrderStatusV2RequestObject) (api.UpdateOrderStatusV2ResponseObject, error) {
+	order, err := o.updateOrderStatus(ctx, request.OrderId, *request.Body)
+	if err != nil {
+		return nil, err
+	}
+	return api.UpdateOrderStatusV2200JSONResponse(o.orderV2(*order)), nil
+}
+
+func (o *OrderController) updateOrderStatus(ctx context.Context, orderId string, req api.OrderStatusUpdate) (*model.OrderResp, error) {
+	orderId = strings.TrimSpace(orderId)
+	if orderId == "" {
+		return nil, myerror.KartError{Code: 400, Msg: "No order Id provided"}
+	}
+	update := orderStatusRequest(req)
+	if update.Status == "" && update.Eta == nil {
+		return nil, myerror.NewValidationError(422, myerror.FieldError{Message: "status or eta must be provided"})
+	}
+	if update.Status == model.OrderPlaced {
+		return nil, myerror.NewValidationError(422, myerror.FieldError{Field: "status", Message: "orders can't go back to placed"})
+	}
+	return o.svc.UpdateOrderStatus(ctx, orderId, update)
+}
+
+func (o *OrderController) placeOrder(ctx context.Context, req api.OrderReq) (*model.OrderResp, error) {
+	oDetail := orderRequest(req)
 	if err := validateOrder(oDetail); err != nil {
-		generateResponse(w, myerror.KartError{Code: 400, Msg: err.Error()})
+		return nil, myerror.NewValidationError(422, err)
+	}
+
+	if p := middleware.PrincipalFromContext(ctx); p != nil {
+		oDetail.CallerId = p.Id()
+		if p.ApiKey == nil {
+			oDetail.Subject = p.CustomerId
+		}
+	}
+
+	order, err := o.svc.PlaceOrder(ctx, oDetail)
+	if err != nil {
+		if myerror.IsInvalidCoupon(err) {
+			middleware.ReportInvalidCoupon(ctx)
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// Staff read every order, customers only their own
+func orderAccess(ctx context.Context) model.OrderAccess {
+	var access model.OrderAccess
+	if p := middleware.PrincipalFromContext(ctx); p != nil {
+		access.All = rbac.Allows(p.Role, p.Scopes, rbac.OrderReadAll)
+		if p.ApiKey == nil {
+			access.Subject = p.CustomerId
+		}
+	}
+	return access
+}
+
+func (o *OrderController) getOrder(ctx context.Context, orderId string) (*model.OrderResp, error) {
+	orderId = strings.TrimSpace(orderId)
+	if orderId == "" {
+		return nil, myerror.KartError{Code: 400, Msg: "No order Id provided"}
+	}
+	return o.svc.GetOrder(ctx, orderId, orderAccess(ctx))
+}
+
+// Orders newest first, limit defaulting to 50
+func (o *OrderController) listOrders(ctx context.Context, limit, offset *int) ([]model.OrderResp, error) {
+	filter := model.OrderFilter{Limit: 50}
+	if limit != nil {
+		if *limit <= 0 || *limit > 500 {
+			return nil, myerror.KartError{Code: 400, Msg: "limit must be between 1 and 500"}
+		}
+		filter.Limit = *limit
+	}
+	if offset != nil {
+		if *offset < 0 {
+			return nil, myerror.KartError{Code: 400, Msg: "offset can't be negative"}
+		}
+		filter.Offset = *offset
+	}
+	return o.svc.ListOrders(ctx, orderAccess(ctx), filter)
+}
+
+// How long clients wait before reconnecting to an event stream
+const eventRetry = 3 * time.Second
+
+// Longest a client may take to accept an event, past which it is cut off
+const eventWriteTimeout = 10 * time.Second
+
+// Stream the changes of an order as server-sent events, starting with what
+// the client missed. Every connection is authorised on its own, and ends once
+// the order is completed or cancelled, after maxStream or when the credential
+// expires, whichever comes first. Clients then reconnect with Last-Event-ID.
+func (o *OrderController) WatchOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderId := strings.TrimSpace(mux.Vars(r)["orderId"])
+	if orderId == "" {
+		generateResponse(w, r, myerror.KartError{Code: 400, Msg: "No order Id provided"})
 		return
 	}
 
-	orders, err := o.svc.PlaceOrder(oDetail)
+	missed, sub, err := o.svc.WatchOrder(ctx, orderId, orderAccess(ctx), r.Header.Get("Last-Event-ID"))
 	if err != nil {
-		generateResponse(w, err.(myerror.KartError))
+		generateResponse(w, r, err)
+		return
+	}
+	// the client has seen the order end, 204 stops EventSource reconnecting
+	if sub == nil && len(missed) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if sub != nil {
+		defer sub.Close()
+	}
+
+	end := time.Now().Add(o.maxStream)
+	if p := middleware.PrincipalFromContext(ctx); p != nil && p.ExpiresAt != nil && p.ExpiresAt.Before(end) {
+		end = *p.ExpiresAt
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // nginx would buffer the events otherwise
+	w.WriteHeader(http.StatusOK)
+
+	metrics.OrderEventStreams.Inc()
+	defer metrics.OrderEventStreams.Dec()
+
+	stream := newEventStream(w)
+	if err := stream.write("retry: %d\n\n", eventRetry.Milliseconds()); err != nil {
+		return
+	}
+	for _, e := range missed {
+		if err := stream.send(e); err != nil {
+			return
+		}
+	}
+	if sub == nil {
 		return
 	}
 
-	w.WriteHeader(200)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	heartbeat := time.NewTicker(o.heartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(time.Until(end))
+	defer deadline.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			if err := stream.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			// dropped for falling behind, or shutting down
+			if !ok {
+				return
+			}
+			if err := stream.send(e); err != nil {
+				return
+			}
+			if slices.Contains(model.FinalOrderStatuses, e.Order.Status) {
+				return
+			}
+		}
+	}
+}
+
+// Writes server-sent events, each flushed right away
+type eventStream struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+// The read deadline of the server would cancel the stream once it passes,
+// it only matters for reading the request
+func newEventStream(w http.ResponseWriter) *eventStream {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	return &eventStream{w: w, rc: rc}
+}
+
+// The write deadline of the server is pushed back before each write, so it
+// only cuts off clients which stopped reading. Not every writer supports
+// deadlines, recorders in tests don't.
+func (s *eventStream) write(format string, args ...any) error {
+	s.rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *eventStream) send(e events.Event) error {
+	data, err := json.Marshal(e.Order)
+	if err != nil {
+		return err
+	}
+	return s.write("id: %s\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
 }
@@ -2,26 +2,92 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/middleware"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
 )
 
 // Mock OrderService
 type mockOrderService struct {
 	order *model.OrderResp
 	err   error
+	// what WatchOrder hands out
+	missed []events.Event
+	sub    *events.Subscription
+	update model.OrderStatusUpdate
 }
 
-func (m *mockOrderService) PlaceOrder(oDetail model.OrderDetail) (*model.OrderResp, error) {
+func (m *mockOrderService) PlaceOrder(ctx context.Context, oDetail model.OrderDetail) (*model.OrderResp, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return m.order, nil
 }
 
+func (m *mockOrderService) GetOrder(ctx context.Context, orderId string, access model.OrderAccess) (*model.OrderResp, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.order, nil
+}
+
+func (m *mockOrderService) ListOrders(ctx context.Context, access model.OrderAccess, filter model.OrderFilter) ([]model.OrderResp, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []model.OrderResp{*m.order}, nil
+}
+
+func (m *mockOrderService) UpdateOrderStatus(ctx context.Context, orderId string, update model.OrderStatusUpdate) (*model.OrderResp, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.update = update
+	return m.order, nil
+}
+
+func (m *mockOrderService) WatchOrder(ctx context.Context, orderId string, access model.OrderAccess, lastEventId string) ([]events.Event, *events.Subscription, error) {
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	return m.missed, m.sub, nil
+}
+
+// The generated handlers of orders, with products backed by a mock
+func orderApi(svc service.OrderService) *Api {
+	return NewApi(NewProductController(&mockProductService{}, "no-cache"), NewOrderController(svc, time.Minute, time.Hour, nil))
+}
+
+// Products and orders are served by the same handlers, whichever of them
+// an operation is routed through
+func TestApi_BothControllers(t *testing.T) {
+	a := NewApi(
+		NewProductController(&mockProductService{products: map[int64]*model.Product{1: {Id: "1", Name: "Waffle", Price: 6.5}}}, "no-cache"),
+		NewOrderController(&mockOrderService{order: &model.OrderResp{Id: "order-1"}}, time.Minute, time.Hour, nil),
+	)
+	for path, h := range map[string]func(http.ResponseWriter, *http.Request){"/product/1": a.GetProductByIdHandler, "/order/order-1": a.GetOrderHandler} {
+		req := httptest.NewRequest("GET", path, nil)
+		req = mux.SetURLVars(req, map[string]string{"productId": "1", "orderId": "order-1"})
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != 200 {
+			t.Errorf("%s: expected status 200, got %d: %s", path, w.Code, w.Body)
+		}
+	}
+}
+
 func TestValidateOrder_Success(t *testing.T) {
 	// Test valid order
 	validOrder := model.OrderDetail{
@@ -42,13 +108,49 @@ func TestValidateOrder_Failure(t *testing.T) {
 	}
 }
 
+func TestPlaceOrderHandler_ProblemFields(t *testing.T) {
+	controller := orderApi(&mockOrderService{})
+	body := `{"items":[{"productId":"1","quantity":1},{"productId":" ","quantity":0}]}`
+	req := httptest.NewRequest("POST", "/order", bytes.NewBufferString(body))
+	req.Header.Set("Accept", problem.ContentType)
+	w := httptest.NewRecorder()
+
+	controller.PlaceOrderHandler(w, req)
+
+	if w.Code != 422 {
+		t.Fatalf("Expected status 422, got %d", w.Code)
+	}
+	var resp model.Problem
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Errors) != 2 || resp.Errors[0].Field != "items[1].productId" || resp.Errors[1].Field != "items[1].quantity" {
+		t.Errorf("Expected errors for items[1], got %+v", resp.Errors)
+	}
+}
+
+func TestPlaceOrderHandler_StrictDecoding(t *testing.T) {
+	controller := orderApi(&mockOrderService{order: &model.OrderResp{}})
+	for _, body := range []string{
+		`{"items":[`,
+		`{"items":[{"productId":"1","quantity":1}],"discount":50}`,
+		`{"items":[{"productId":"1","quantity":"1"}]}`,
+		`{"items":[{"productId":"1","quantity":1}]} {}`,
+	} {
+		req := httptest.NewRequest("POST", "/order", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		controller.PlaceOrderHandler(w, req)
+		if w.Code != 400 {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
+
 // Test Success
 func TestPlaceOrderHandler_Success(t *testing.T) {
 	// Test success
 	mockSvc := &mockOrderService{
 		order: &model.OrderResp{Id: "order-123", Total: 200.0},
 	}
-	controller := NewOrderController(mockSvc)
+	controller := orderApi(mockSvc)
 	orderDetail := model.OrderDetail{
 		OrderedProduct: []model.OrderedProduct{
 			{ProductId: "1", Quantity: 2},
@@ -80,7 +182,7 @@ func TestPlaceOrderHandler_Failure(t *testing.T) {
 	mockSvc := &mockOrderService{
 		order: &model.OrderResp{},
 	}
-	controller := NewOrderController(mockSvc)
+	controller := orderApi(mockSvc)
 
 	// Test no request body
 	req := httptest.NewRequest("POST", "/order", nil)
@@ -92,3 +194,103 @@ func TestPlaceOrderHandler_Failure(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 }
+
+func TestUpdateOrderStatusHandler(t *testing.T) {
+	mockSvc := &mockOrderService{order: &model.OrderResp{Id: "order-1", Status: model.OrderPreparing}}
+	controller := orderApi(mockSvc)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"status":"preparing","eta":"2030-01-01T12:00:00Z"}`, 200},
+		{`{"eta":"2030-01-01T12:00:00Z"}`, 200},
+		{`{}`, 422},
+		{`{"status":"placed"}`, 422},
+		{`{"status":"ready","note":"x"}`, 400},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/v2/order/order-1/status", strings.NewReader(tt.body))
+		req = mux.SetURLVars(req, map[string]string{"orderId": "order-1"})
+		w := httptest.NewRecorder()
+		controller.UpdateOrderStatusV2Handler(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.status, w.Code, w.Body)
+		}
+	}
+
+	if mockSvc.update.Status != "" || mockSvc.update.Eta == nil {
+		t.Errorf("Expected an ETA-only update, got %+v", mockSvc.update)
+	}
+}
+
+func watch(controller *OrderController, p *middleware.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/order/order-1/events", nil)
+	req = mux.SetURLVars(req, map[string]string{"orderId": "order-1"})
+	if p != nil {
+		req = req.WithContext(middleware.WithPrincipal(req.Context(), p))
+	}
+	w := httptest.NewRecorder()
+	controller.WatchOrderHandler(w, req)
+	return w
+}
+
+func TestWatchOrderHandler(t *testing.T) {
+	hub := events.NewHub(0)
+	sub, _, _ := hub.Subscribe("order-1", "")
+	placed := sub.Snapshot(model.OrderEvent{OrderId: "order-1", Status: model.OrderPlaced})
+	// sent while the client is connected, the stream ends with the order
+	hub.Publish(events.Eta, model.OrderEvent{OrderId: "order-1", Status: model.OrderPlaced})
+	completed := hub.Publish(events.Status, model.OrderEvent{OrderId: "order-1", Status: model.OrderCompleted})
+
+	w := watch(NewOrderController(&mockOrderService{missed: []events.Event{placed}, sub: sub}, time.Minute, time.Hour, nil), nil)
+
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/event-stream" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("Expected an event stream, got %d %v", w.Code, w.Header())
+	}
+	frames := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(frames) != 4 || frames[0] != "retry: 3000" {
+		t.Fatalf("Expected retry and 3 events, got %q", w.Body)
+	}
+	for i, want := range []string{events.Status, events.Eta, events.Status} {
+		if !strings.Contains(frames[i+1], "\nevent: "+want+"\ndata: {") {
+			t.Errorf("Expected a %s event, got %q", want, frames[i+1])
+		}
+	}
+	if !strings.HasPrefix(frames[3], "id: "+completed.Id+"\n") || !strings.Contains(frames[3], `"status":"completed"`) {
+		t.Errorf("Expected the completed order last, got %q", frames[3])
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Expected the subscription closed")
+	}
+}
+
+// Without changes the stream sends heartbeats, until the credential expires
+func TestWatchOrderHandler_Heartbeat(t *testing.T) {
+	sub, _, _ := events.NewHub(0).Subscribe("order-1", "")
+	controller := NewOrderController(&mockOrderService{sub: sub}, 10*time.Millisecond, time.Hour, nil)
+	expires := time.Now().Add(100 * time.Millisecond)
+
+	start := time.Now()
+	w := watch(controller, &middleware.Principal{ExpiresAt: &expires})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the stream to end with the credential, took %s", elapsed)
+	}
+	if !strings.Contains(w.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("Expected heartbeats, got %q", w.Body)
+	}
+}
+
+func TestWatchOrderHandler_Ended(t *testing.T) {
+	// the client saw the order end
+	w := watch(NewOrderController(&mockOrderService{}, time.Minute, time.Hour, nil), nil)
+	if w.Code != 204 || w.Body.Len() != 0 {
+		t.Errorf("Expected 204, got %d %q", w.Code, w.Body)
+	}
+
+	w = watch(NewOrderController(&mockOrderService{err: myerror.KartError{Code: 404, Msg: "Order not found"}}, time.Minute, time.Hour, nil), nil)
+	if w.Code != 404 || w.Header().Get("Content-Type") == "text/event-stream" {
+		t.Errorf("Expected 404, got %d %v", w.Code, w.Header())
+	}
+}
@@ -1,80 +1,138 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/api"
 	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
 	"github.com/priykumar/oolio-kart-challenge/internal/service"
 )
 
 type ProductController struct {
 	svc service.ProductService
+	// Cache-Control of product listings
+	cacheControl string
 }
 
-func NewProductController(svc service.ProductService) *ProductController {
-	return &ProductController{svc}
+func NewProductController(svc service.ProductService, cacheControl string) *ProductController {
+	return &ProductController{svc: svc, cacheControl: cacheControl}
 }
 
 // Get all the available products
-func (p *ProductController) GetProductHandler(w http.ResponseWriter, r *http.Request) {
-	products, err := p.svc.GetAllAvailableProducts()
+func (a *Api) GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	a.h.ListProducts(w, r)
+}
+
+// Get product by productId
+func (a *Api) GetProductByIdHandler(w http.ResponseWriter, r *http.Request) {
+	a.h.GetProduct(w, r)
+}
+
+// List products v2, with prices as Money
+func (a *Api) ListProductsV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.ListProductsV2(w, r)
+}
+
+// Get product by productId v2, with its price as Money
+func (a *Api) GetProductV2Handler(w http.ResponseWriter, r *http.Request) {
+	a.h.GetProductV2(w, r)
+}
+
+func (p *ProductController) ListProducts(ctx context.Context, request api.ListProductsRequestObject) (api.ListProductsResponseObject, error) {
+	catalog, err := p.svc.GetCatalog(ctx)
 	if err != nil {
-		generateResponse(w, err.(myerror.KartError))
-		return
+		return nil, err
+	}
+	headers := p.catalogHeaders(ctx, catalog, "v1")
+	if notModified(catalog, headers.ETag, request.Params.IfNoneMatch, request.Params.IfModifiedSince) {
+		return api.ListProducts304Response{Headers: headers}, nil
 	}
+	if wantsLines(ctx) {
+		return api.ListProducts200ApplicationxNdjsonResponse{Body: lines(catalog.Products, productResponse), Headers: api.ListProducts200ResponseHeaders(headers)}, nil
+	}
+	return api.ListProducts200JSONResponse{Body: mapSlice(catalog.Products, productResponse), Headers: api.ListProducts200ResponseHeaders(headers)}, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	json.NewEncoder(w).Encode(products)
+func (p *ProductController) GetProduct(ctx context.Context, request api.GetProductRequestObject) (api.GetProductResponseObject, error) {
+	product, err := p.getProduct(ctx, request.ProductId)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetProduct200JSONResponse(productResponse(*product)), nil
 }
 
-// Get product by productId
-func (p *ProductController) GetProductByIdHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-
-	// Check if productId exists in path parameter
-	if _, exist := vars["productId"]; !exist {
-		fmt.Println("No product Id provided")
-		generateResponse(w, myerror.KartError{Code: 400, Msg: "No product Id provided"})
-		return
+func (p *ProductController) ListProductsV2(ctx context.Context, request api.ListProductsV2RequestObject) (api.ListProductsV2ResponseObject, error) {
+	catalog, err := p.svc.GetCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headers := p.catalogHeaders(ctx, catalog, "v2")
+	if notModified(catalog, headers.ETag, request.Params.IfNoneMatch, request.Params.IfModifiedSince) {
+		return api.ListProductsV2304Response{Headers: headers}, nil
 	}
+	if wantsLines(ctx) {
+		return api.ListProductsV2200ApplicationxNdjsonResponse{Body: lines(catalog.Products, productV2Response), Headers: api.ListProductsV2200ResponseHeaders(headers)}, nil
+	}
+	return api.ListProductsV2200JSONResponse{Body: mapSlice(catalog.Products, productV2Response), Headers: api.ListProductsV2200ResponseHeaders(headers)}, nil
+}
 
-	productId := vars["productId"]
+func (p *ProductController) GetProductV2(ctx context.Context, request api.GetProductV2RequestObject) (api.GetProductV2ResponseObject, error) {
+	product, err := p.getProduct(ctx, request.ProductId)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetProductV2200JSONResponse(productV2Response(*product)), nil
+}
 
-	// Validate product ID
-	pId, err := strconv.Atoi(productId)
-	if err != nil || pId < 0 {
-		fmt.Println("Invalid product Id provided")
-		generateResponse(w, myerror.KartError{Code: 400, Msg: "Invalid ID supplied"})
-		return
+func (p *ProductController) getProduct(ctx context.Context, productId int64) (*model.Product, error) {
+	if productId < 0 {
+		return nil, myerror.KartError{Code: 400, Msg: "Invalid ID supplied"}
 	}
+	return p.svc.GetProductById(ctx, productId)
+}
 
-	// Get product from service
-	products, err := p.svc.GetProductById(int64(pId))
-	if err != nil {
-		generateResponse(w, err.(myerror.KartError))
-		return
+// Validators of a product listing. Each version and media type is its own
+// representation, so it gets its own strong ETag.
+func (p *ProductController) catalogHeaders(ctx context.Context, catalog *model.Catalog, version string) api.NotModifiedResponseHeaders {
+	representation := version
+	if wantsLines(ctx) {
+		representation += "-ndjson"
+	}
+	headers := api.NotModifiedResponseHeaders{
+		CacheControl: p.cacheControl,
+		ETag:         `"` + catalog.ETag + "-" + representation + `"`,
+	}
+	if !catalog.LastModified.IsZero() {
+		headers.LastModified = catalog.LastModified.UTC().Format(http.TimeFormat)
 	}
+	return headers
+}
 
-	w.WriteHeader(200)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+// Whether the client holds the current listing, as RFC 9110 section 13.2.2
+// evaluates it for GET: If-Modified-Since only counts without If-None-Match.
+func notModified(catalog *model.Catalog, etag string, ifNoneMatch, ifModifiedSince *string) bool {
+	if ifNoneMatch != nil {
+		for _, tag := range strings.Split(*ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ifModifiedSince != nil && !catalog.LastModified.IsZero() {
+		since, err := http.ParseTime(*ifModifiedSince)
+		return err == nil && !catalog.LastModified.Truncate(time.Second).After(since)
+	}
+	return false
 }
 
-func generateResponse(w http.ResponseWriter, kErr myerror.KartError) {
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(kErr.Code)
-	json.NewEncoder(w).Encode(
-		model.Response{
-			Code:    int32(kErr.Code),
-			Type:    myerror.Code2Err[kErr.Code].Error(),
-			Message: kErr.Msg,
-		},
-	)
+// Answer r with err, as problem details or model.Response
+func generateResponse(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, err)
 }
@@ -1,21 +1,26 @@
 package controller
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/service"
 )
 
 // Mock ProductService
 type mockProductService struct {
-	products map[int64]*model.Product
-	err      error
+	products     map[int64]*model.Product
+	lastModified time.Time
+	err          error
 }
 
-func (m *mockProductService) GetAllAvailableProducts() ([]model.Product, error) {
+func (m *mockProductService) GetCatalog(ctx context.Context) (*model.Catalog, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -23,10 +28,10 @@ func (m *mockProductService) GetAllAvailableProducts() ([]model.Product, error)
 	for _, p := range m.products {
 		products = append(products, *p)
 	}
-	return products, nil
+	return &model.Catalog{Products: products, ETag: "abc", LastModified: m.lastModified}, nil
 }
 
-func (m *mockProductService) GetProductById(id int64) (*model.Product, error) {
+func (m *mockProductService) GetProductById(ctx context.Context, id int64) (*model.Product, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -36,6 +41,11 @@ func (m *mockProductService) GetProductById(id int64) (*model.Product, error) {
 	return nil, myerror.KartError{Code: 404, Msg: "Product not found"}
 }
 
+// The generated handlers of products, with orders backed by a mock
+func productApi(svc service.ProductService, cacheControl string) *Api {
+	return NewApi(NewProductController(svc, cacheControl), NewOrderController(&mockOrderService{}, time.Minute, time.Hour, nil))
+}
+
 // Test success
 func TestGetProductHandler_Success(t *testing.T) {
 	// Test success
@@ -44,7 +54,7 @@ func TestGetProductHandler_Success(t *testing.T) {
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	controller := NewProductController(mockSvc)
+	controller := productApi(mockSvc, "no-cache")
 	req := httptest.NewRequest("GET", "/product", nil)
 	w := httptest.NewRecorder()
 
@@ -70,7 +80,7 @@ func TestGetProductHandler_Failure(t *testing.T) {
 	mockSvc := &mockProductService{
 		err: myerror.KartError{Code: 500, Msg: "Internal error"},
 	}
-	controller := NewProductController(mockSvc)
+	controller := productApi(mockSvc, "no-cache")
 	req := httptest.NewRequest("GET", "/product", nil)
 	w := httptest.NewRecorder()
 
@@ -88,7 +98,7 @@ func TestGetProductByIdHandler_Success(t *testing.T) {
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	controller := NewProductController(mockSvc)
+	controller := productApi(mockSvc, "no-cache")
 	req := httptest.NewRequest("GET", "/product/1", nil)
 	req = mux.SetURLVars(req, map[string]string{"productId": "1"})
 	w := httptest.NewRecorder()
@@ -107,7 +117,7 @@ func TestGetProductByIdHandler_Failure(t *testing.T) {
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	controller := NewProductController(mockSvc)
+	controller := productApi(mockSvc, "no-cache")
 	w := httptest.NewRecorder()
 
 	// Test invalid product ID
@@ -128,3 +138,56 @@ func TestGetProductByIdHandler_Failure(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 }
+
+func TestGetProductHandler_Conditional(t *testing.T) {
+	lastModified := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	mockSvc := &mockProductService{
+		products:     map[int64]*model.Product{1: {Id: "1", Name: "Test Product", Price: 100.0}},
+		lastModified: lastModified,
+	}
+	controller := productApi(mockSvc, "public, no-cache")
+
+	tests := []struct {
+		name            string
+		handler         func(http.ResponseWriter, *http.Request)
+		ifNoneMatch     string
+		ifModifiedSince string
+		status          int
+		etag            string
+	}{
+		{"unconditional", controller.GetProductHandler, "", "", 200, `"abc-v1"`},
+		{"same etag", controller.GetProductHandler, `"abc-v1"`, "", 304, `"abc-v1"`},
+		{"weak etag in a list", controller.GetProductHandler, `"old-v1", W/"abc-v1"`, "", 304, `"abc-v1"`},
+		{"any", controller.GetProductHandler, "*", "", 304, `"abc-v1"`},
+		{"etag of v1 on v2", controller.ListProductsV2Handler, `"abc-v1"`, "", 200, `"abc-v2"`},
+		{"changed", controller.GetProductHandler, `"old-v1"`, "", 200, `"abc-v1"`},
+		{"not modified since", controller.GetProductHandler, "", "Thu, 01 Oct 2026 08:00:00 GMT", 304, `"abc-v1"`},
+		{"modified since", controller.GetProductHandler, "", "Wed, 30 Sep 2026 08:00:00 GMT", 200, `"abc-v1"`},
+		{"if-none-match wins", controller.GetProductHandler, `"old-v1"`, "Thu, 01 Oct 2026 08:00:00 GMT", 200, `"abc-v1"`},
+		{"invalid date", controller.GetProductHandler, "", "yesterday", 200, `"abc-v1"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/product", nil)
+		if tt.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		if tt.ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+		}
+		w := httptest.NewRecorder()
+		tt.handler(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s: expected ETag %s, got %s", tt.name, tt.etag, got)
+		}
+		if w.Header().Get("Last-Modified") != "Thu, 01 Oct 2026 08:00:00 GMT" || w.Header().Get("Cache-Control") != "public, no-cache" {
+			t.Errorf("%s: unexpected headers %v", tt.name, w.Header())
+		}
+		if tt.status == 304 && w.Body.Len() > 0 {
+			t.Errorf("%s: expected no body, got %s", tt.name, w.Body)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package coupon
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Tiers used by the hashed policy when none are configured. Keeps the
+// historical 10%-50% range.
+var defaultTiers = []float64{10, 15, 20, 25, 30, 35, 40, 45, 50}
+
+type PatternRule struct {
+	Pattern  string  `json:"pattern"`
+	Discount float64 `json:"discount"`
+}
+
+// PolicyConfig is the "discount_policy" block of config.json. Rules are
+// evaluated in order: exact code (codes, then CSV mapping), first matching
+// pattern, hashed tier.
+type PolicyConfig struct {
+	Codes    map[string]float64 `json:"codes"`
+	CsvPath  string             `json:"csv_path"`
+	Patterns []PatternRule      `json:"patterns"`
+	Tiers    []float64          `json:"tiers"`
+	Salt     string             `json:"salt"`
+}
+
+type DiscountPolicy interface {
+	Discount(code string) float64
+}
+
+type discountPolicy struct {
+	codes    map[string]float64
+	patterns []PatternRule
+	tiers    []float64
+	salt     string
+}
+
+// Build a discount policy from config. The CSV path is taken as is, main
+// resolves it against the token directory.
+func NewDiscountPolicy(cfg PolicyConfig) (DiscountPolicy, error) {
+	p := &discountPolicy{
+		codes:    map[string]float64{},
+		patterns: cfg.Patterns,
+		tiers:    cfg.Tiers,
+		salt:     cfg.Salt,
+	}
+	if len(p.tiers) == 0 {
+		p.tiers = defaultTiers
+	}
+
+	for _, t := range p.tiers {
+		if !IsValidDiscount(t) {
+			return nil, fmt.Errorf("invalid discount tier %v", t)
+		}
+	}
+
+	for _, r := range p.patterns {
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid coupon pattern %q: %w", r.Pattern, err)
+		}
+		if !IsValidDiscount(r.Discount) {
+			return nil, fmt.Errorf("invalid discount %v for pattern %q", r.Discount, r.Pattern)
+		}
+	}
+
+	if cfg.CsvPath != "" {
+		if err := loadCsvMapping(cfg.CsvPath, p.codes); err != nil {
+			return nil, err
+		}
+	}
+
+	// explicitly configured codes take precedence over the CSV mapping
+	for code, d := range cfg.Codes {
+		if !IsValidDiscount(d) {
+			return nil, fmt.Errorf("invalid discount %v for code %q", d, code)
+		}
+		p.codes[code] = d
+	}
+
+	return p, nil
+}
+
+func (p *discountPolicy) Discount(code string) float64 {
+	if d, ok := p.codes[code]; ok {
+		return d
+	}
+
+	for _, r := range p.patterns {
+		if ok, _ := path.Match(r.Pattern, code); ok {
+			return r.Discount
+		}
+	}
+
+	// Same code always hashes to the same tier, so rebuilding the DB
+	// gives back the same discounts
+	sum := sha256.Sum256([]byte(p.salt + code))
+	idx := binary.BigEndian.Uint64(sum[:8]) % uint64(len(p.tiers))
+	return p.tiers[idx]
+}
+
+// Codes are kept short and free of separators used in import files
+func IsValidCode(code string) bool {
+	if len(code) == 0 || len(code) > 32 {
+		return false
+	}
+	for _, r := range code {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Discount is a percentage kept to 2 decimals, so it must be in [0.01, 100].
+// Smaller ones would be stored as 0.
+func IsValidDiscount(d float64) bool {
+	return d >= 0.01 && d <= 100
+}
+
+// Read "CODE,discount" lines into codes
+func loadCsvMapping(filePath string, codes map[string]float64) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open discount mapping: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected CODE,discount", filePath, line)
+		}
+
+		d, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || !IsValidDiscount(d) {
+			return fmt.Errorf("%s:%d: invalid discount %q", filePath, line, fields[1])
+		}
+		codes[strings.TrimSpace(fields[0])] = d
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,74 @@
+package coupon
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiscountPolicy_Precedence(t *testing.T) {
+	policy, err := NewDiscountPolicy(PolicyConfig{
+		Codes:    map[string]float64{"HAPPYHRS": 18},
+		Patterns: []PatternRule{{Pattern: "HAPPY*", Discount: 25}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if d := policy.Discount("HAPPYHRS"); d != 18 {
+		t.Errorf("Expected exact code discount 18, got %f", d)
+	}
+	if d := policy.Discount("HAPPYDAY"); d != 25 {
+		t.Errorf("Expected pattern discount 25, got %f", d)
+	}
+}
+
+func TestDiscountPolicy_Deterministic(t *testing.T) {
+	cfg := PolicyConfig{Tiers: []float64{10, 20, 30}, Salt: "test"}
+	p1, _ := NewDiscountPolicy(cfg)
+	p2, _ := NewDiscountPolicy(cfg)
+
+	for _, code := range []string{"59TBB7Q1", "B74Q6WCV", "WIY02URK", "6AYNBG62"} {
+		d := p1.Discount(code)
+		if d != p2.Discount(code) {
+			t.Errorf("Expected same discount for %s across policies", code)
+		}
+		if d != 10 && d != 20 && d != 30 {
+			t.Errorf("Expected discount from tiers for %s, got %f", code, d)
+		}
+	}
+}
+
+func TestDiscountPolicy_CsvMapping(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "mapping-*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("# code,discount\nFIFTYOFF,50\nSAVE10AB, 10\n")
+	f.Close()
+
+	policy, err := NewDiscountPolicy(PolicyConfig{
+		CsvPath: f.Name(),
+		Codes:   map[string]float64{"SAVE10AB": 12},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if d := policy.Discount("FIFTYOFF"); d != 50 {
+		t.Errorf("Expected CSV discount 50, got %f", d)
+	}
+	if d := policy.Discount("SAVE10AB"); d != 12 {
+		t.Errorf("Expected configured code to win over CSV, got %f", d)
+	}
+}
+
+func TestNewDiscountPolicy_Failure(t *testing.T) {
+	if _, err := NewDiscountPolicy(PolicyConfig{Tiers: []float64{0}}); err == nil {
+		t.Error("Expected error for zero tier, got nil")
+	}
+	if _, err := NewDiscountPolicy(PolicyConfig{Patterns: []PatternRule{{Pattern: "[", Discount: 10}}}); err == nil {
+		t.Error("Expected error for bad pattern, got nil")
+	}
+	if _, err := NewDiscountPolicy(PolicyConfig{CsvPath: "does-not-exist.csv"}); err == nil {
+		t.Error("Expected error for missing CSV, got nil")
+	}
+}
@@ -7,16 +7,20 @@ import (
 
 var (
 	ErrInvalidInput        = errors.New("invalid input")         // 400
-	ErrNotFound            = errors.New("product not found")     // 404
+	ErrForbidden           = errors.New("forbidden")             // 403
+	ErrNotFound            = errors.New("not found")             // 404
+	ErrConflict            = errors.New("conflict")              // 409
 	ErrValidationException = errors.New("validation exception")  // 422
 	ErrInternalServer      = errors.New("internal server error") // 500
 )
 
 var Code2Err map[int]error = map[int]error{
-	400: errors.New("invalid input"),
-	404: errors.New("product not found"),
-	422: errors.New("validation exception"),
-	500: errors.New("internal server error"),
+	400: ErrInvalidInput,
+	403: ErrForbidden,
+	404: ErrNotFound,
+	409: ErrConflict,
+	422: ErrValidationException,
+	500: ErrInternalServer,
 }
 
 type KartError struct {
@@ -24,6 +28,14 @@ type KartError struct {
 	Msg  string
 }
 
+// Returned for coupon codes which don't exist, as opposed to ones which exist
+// but can't be used right now
+var ErrInvalidCoupon = KartError{Code: 400, Msg: "Invalid coupon code is provided"}
+
+func IsInvalidCoupon(err error) bool {
+	return errors.Is(err, ErrInvalidCoupon)
+}
+
 func (e KartError) Error() string {
 	err := Code2Err[e.Code]
 	if err != nil {
@@ -40,6 +52,14 @@ func IsInvalidInput(err error) bool {
 	return errors.Is(err, ErrInvalidInput)
 }
 
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
 func IsNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound)
 }
@@ -51,3 +71,51 @@ func IsIValidationException(err error) bool {
 func IsInternalServer(err error) bool {
 	return errors.Is(err, ErrInternalServer)
 }
+
+// A request field which failed validation. Field is its path in the request
+// body, e.g. items[0].quantity, or the query parameter's name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Invalid request fields, answered with Code and the details of every field
+type ValidationError struct {
+	Code   int
+	Fields []FieldError
+}
+
+// Collect the FieldErrors in errs, including those joined by errors.Join.
+// Other errors are kept as a field-less detail.
+func NewValidationError(code int, errs ...error) ValidationError {
+	v := ValidationError{Code: code}
+	for _, err := range errs {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			v.Fields = append(v.Fields, NewValidationError(code, joined.Unwrap()...).Fields...)
+			continue
+		}
+		var fe FieldError
+		if errors.As(err, &fe) {
+			v.Fields = append(v.Fields, fe)
+		} else if err != nil {
+			v.Fields = append(v.Fields, FieldError{Message: err.Error()})
+		}
+	}
+	return v
+}
+
+// The first field's message, as the legacy response reports a single message
+func (e ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return Code2Err[e.Code].Error()
+	}
+	return e.Fields[0].Message
+}
+
+func (e ValidationError) Unwrap() error {
+	return Code2Err[e.Code]
+}
@@ -0,0 +1,180 @@
+// In-process fan-out of order changes to the clients watching the orders,
+// with a bounded history so clients reconnecting can resume where they left.
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Event types, the SSE event names
+const (
+	Status = "status" // the status of the order changed
+	Eta    = "eta"    // only its ETA changed
+)
+
+// Events buffered for a subscriber which isn't reading. A subscriber falling
+// further behind is dropped, its client reconnects and resumes from the
+// history.
+const subscriberBuffer = 16
+
+// A change of an order. Ids are "<epoch>-<seq>", the epoch differs for every
+// hub so ids handed out by an earlier process aren't taken for ones of this
+// one.
+type Event struct {
+	Id    string
+	Type  string
+	Order model.OrderEvent
+	seq   uint64
+}
+
+type Hub struct {
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	history []Event // ring of the latest events of every order
+	next    int     // where the next event goes once history is full
+	subs    map[string]map[*Subscription]struct{}
+	closed  bool
+}
+
+// Hub remembering the last historySize events, 1024 when unset
+func NewHub(historySize int) *Hub {
+	if historySize <= 0 {
+		historySize = 1024
+	}
+	return &Hub{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		history: make([]Event, 0, historySize),
+		subs:    map[string]map[*Subscription]struct{}{},
+	}
+}
+
+func (h *Hub) id(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// Sequence number of an id handed out by this hub
+func (h *Hub) parse(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > h.seq {
+		return 0, false
+	}
+	return n, true
+}
+
+// Send an event to the subscribers of its order and remember it
+func (h *Hub) Publish(eventType string, order model.OrderEvent) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e := Event{Id: h.id(h.seq), Type: eventType, Order: order, seq: h.seq}
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, e)
+	} else {
+		h.history[h.next] = e
+		h.next = (h.next + 1) % len(h.history)
+	}
+
+	for s := range h.subs[order.OrderId] {
+		select {
+		case s.events <- e:
+		default:
+			h.drop(s)
+		}
+	}
+	return e
+}
+
+// Subscribe to the events of an order. The events published since
+// lastEventId are returned, to be sent first. complete is false when they
+// aren't known: lastEventId is empty, of another process or older than the
+// history. The client needs the current state of the order then.
+func (h *Hub) Subscribe(orderId, lastEventId string) (s *Subscription, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s = &Subscription{hub: h, orderId: orderId, position: h.id(h.seq), events: make(chan Event, subscriberBuffer)}
+	if h.closed {
+		close(s.events)
+		return s, nil, false
+	}
+	if h.subs[orderId] == nil {
+		h.subs[orderId] = map[*Subscription]struct{}{}
+	}
+	h.subs[orderId][s] = struct{}{}
+
+	last, ok := h.parse(lastEventId)
+	if !ok || h.seq-last > uint64(len(h.history)) {
+		return s, nil, false
+	}
+	// oldest first, the ring starts at next once full
+	for i := range h.history {
+		e := h.history[(h.next+i)%len(h.history)]
+		if e.seq > last && e.Order.OrderId == orderId {
+			missed = append(missed, e)
+		}
+	}
+	return s, missed, true
+}
+
+// Close every subscription, e.g. on shutdown so streams don't hold it up.
+// Later subscriptions are closed right away.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.drop(s)
+		}
+	}
+}
+
+func (h *Hub) drop(s *Subscription) {
+	subs := h.subs[s.orderId]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.orderId)
+	}
+	close(s.events)
+}
+
+// Events of one order. The channel is closed when the subscription is
+// closed, dropped for falling behind or the hub shuts down.
+type Subscription struct {
+	hub      *Hub
+	orderId  string
+	position string // id of the last event published before subscribing
+	events   chan Event
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// The current state of the order as an event, for clients which can't
+// resume. Its id resumes after the events published before subscribing.
+func (s *Subscription) Snapshot(order model.OrderEvent) Event {
+	return Event{Id: s.position, Type: Status, Order: order}
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+func status(orderId, status string) model.OrderEvent {
+	return model.OrderEvent{OrderId: orderId, Status: status}
+}
+
+func TestHub_Publish(t *testing.T) {
+	h := NewHub(0)
+	a, _, _ := h.Subscribe("a", "")
+	defer a.Close()
+	b, _, _ := h.Subscribe("b", "")
+	defer b.Close()
+
+	h.Publish(Status, status("a", model.OrderPreparing))
+	h.Publish(Eta, status("a", model.OrderPreparing))
+
+	for _, want := range []string{Status, Eta} {
+		if e := <-a.Events(); e.Type != want || e.Order.OrderId != "a" {
+			t.Errorf("Expected a %s event of a, got %+v", want, e)
+		}
+	}
+	if len(b.Events()) != 0 {
+		t.Error("Expected no event for another order")
+	}
+}
+
+func TestHub_Resume(t *testing.T) {
+	h := NewHub(4)
+	first := h.Publish(Status, status("a", model.OrderPreparing))
+	h.Publish(Status, status("b", model.OrderPreparing))
+	h.Publish(Status, status("a", model.OrderReady))
+
+	tests := []struct {
+		name        string
+		lastEventId string
+		missed      []string
+		complete    bool
+	}{
+		{"new client", "", nil, false},
+		{"after the first", first.Id, []string{model.OrderReady}, true},
+		{"before the first", h.epoch + "-0", []string{model.OrderPreparing, model.OrderReady}, true},
+		{"earlier process", "abc-1", nil, false},
+		{"not yet published", h.epoch + "-9", nil, false},
+		{"garbage", "x", nil, false},
+	}
+	for _, tt := range tests {
+		s, missed, complete := h.Subscribe("a", tt.lastEventId)
+		s.Close()
+		if complete != tt.complete || len(missed) != len(tt.missed) {
+			t.Errorf("%s: expected %v complete %v, got %+v complete %v", tt.name, tt.missed, tt.complete, missed, complete)
+			continue
+		}
+		for i, e := range missed {
+			if e.Order.Status != tt.missed[i] {
+				t.Errorf("%s: expected %v, got %+v", tt.name, tt.missed, missed)
+			}
+		}
+	}
+
+	// the history keeps the last 4 events, the first is gone
+	h.Publish(Status, status("b", model.OrderReady))
+	h.Publish(Status, status("b", model.OrderCompleted))
+	if s, missed, complete := h.Subscribe("a", h.epoch+"-0"); complete {
+		t.Errorf("Expected the state to be needed past the history, got %+v", missed)
+	} else {
+		s.Close()
+	}
+	s, missed, complete := h.Subscribe("a", first.Id)
+	s.Close()
+	if !complete || len(missed) != 1 || missed[0].Order.Status != model.OrderReady {
+		t.Errorf("Expected ready from the history, got %+v complete %v", missed, complete)
+	}
+}
+
+func TestHub_Snapshot(t *testing.T) {
+	h := NewHub(0)
+	h.Publish(Status, status("a", model.OrderPreparing))
+	s, _, _ := h.Subscribe("a", "")
+	s.Close()
+	snapshot := s.Snapshot(status("a", model.OrderPreparing))
+	h.Publish(Status, status("a", model.OrderReady))
+
+	// resuming from the snapshot skips what it already covered
+	s, missed, complete := h.Subscribe("a", snapshot.Id)
+	s.Close()
+	if !complete || len(missed) != 1 || missed[0].Order.Status != model.OrderReady {
+		t.Errorf("Expected only ready, got %+v complete %v", missed, complete)
+	}
+}
+
+// A subscriber which doesn't read is dropped rather than block publishing
+func TestHub_SlowSubscriber(t *testing.T) {
+	h := NewHub(0)
+	s, _, _ := h.Subscribe("a", "")
+	for range subscriberBuffer + 1 {
+		h.Publish(Eta, status("a", model.OrderPreparing))
+	}
+
+	n := 0
+	for range s.Events() {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d events before the subscription closed, got %d", subscriberBuffer, n)
+	}
+	s.Close()
+}
+
+func TestHub_Close(t *testing.T) {
+	h := NewHub(0)
+	s, _, _ := h.Subscribe("a", "")
+	h.Close()
+	if _, ok := <-s.Events(); ok {
+		t.Error("Expected the subscription closed")
+	}
+	s.Close()
+
+	s, _, _ = h.Subscribe("a", "")
+	if _, ok := <-s.Events(); ok {
+		t.Error("Expected subscriptions after closing closed right away")
+	}
+}
@@ -0,0 +1,149 @@
+// Prometheus metrics of the server. Collectors are package variables so any
+// layer can record into them, and are exposed by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kart"
+
+// Coupon lookup results
+const (
+	CouponHit  = "hit"
+	CouponMiss = "miss"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HttpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of SQLite repository operations, including their transaction.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5},
+	}, []string{"operation"})
+
+	DbRollbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_transaction_rollbacks_total",
+		Help:      "Transactions rolled back instead of committed, by repository operation.",
+	}, []string{"operation"})
+
+	OrdersPlaced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_placed_total",
+		Help:      "Orders placed successfully.",
+	})
+
+	OrderRevenue = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "order_revenue_total",
+		Help:      "Sum of order totals after discounts.",
+	})
+
+	OrderDiscount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "order_discount_total",
+		Help:      "Sum of discounts granted by coupons.",
+	})
+
+	OrderEventStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "order_event_streams",
+		Help:      "Clients currently watching an order.",
+	})
+
+	CouponGuardFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_guard_failures_total",
+		Help:      "Unknown coupon codes counted against callers and client IPs, by what they were counted against.",
+	}, []string{"key"})
+
+	CouponGuardLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_guard_lockouts_total",
+		Help:      "Callers and client IPs locked out for guessing coupon codes, by which was locked out.",
+	}, []string{"key"})
+
+	CouponLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_lookups_total",
+		Help:      "Coupon codes looked up by orders and coupon checks, by whether the code exists.",
+	}, []string{"source", "result"})
+
+	ArtifactLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_artifact_lines_total",
+		Help:      "Lines read from coupon artifact files.",
+	}, []string{"file"})
+
+	ArtifactCandidates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_artifact_candidates_total",
+		Help:      "Lines of coupon artifact files with a code of valid length.",
+	}, []string{"file"})
+
+	ArtifactDistinctCodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "coupon_artifact_distinct_codes",
+		Help:      "Distinct codes found across the artifacts in the last run.",
+	})
+
+	ArtifactValidCodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "coupon_artifact_valid_codes",
+		Help:      "Codes present in at least two artifacts in the last run.",
+	})
+
+	CouponPipelineDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "coupon_pipeline_duration_seconds",
+		Help:      "Duration of the last run of each coupon loading stage.",
+	}, []string{"stage"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequests,
+		HttpDuration,
+		DbQueryDuration,
+		DbRollbacks,
+		OrdersPlaced,
+		OrderRevenue,
+		OrderDiscount,
+		OrderEventStreams,
+		CouponGuardFailures,
+		CouponGuardLockouts,
+		CouponLookups,
+		ArtifactLines,
+		ArtifactCandidates,
+		ArtifactDistinctCodes,
+		ArtifactValidCodes,
+		CouponPipelineDuration,
+	)
+}
+
+// Serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/priykumar/oolio-kart-challenge/internal/negotiate"
+)
+
+var defaultEncodings = []string{"br", "zstd", "gzip"}
+
+type CompressionConfig struct {
+	// Leave responses uncompressed
+	Disabled bool `json:"disabled"`
+	// Smallest body compressed, 1024 bytes when unset. Smaller ones gain
+	// little over the framing. Streamed responses are compressed from their
+	// first flush whatever their size.
+	MinBytes int `json:"min_bytes"`
+	// Codings offered, preferred first when the client weighs them equally.
+	// br, zstd and gzip when unset.
+	Encodings []string `json:"encodings"`
+}
+
+// The encoders of a coding, reset for each response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Compresses responses in the coding the client prefers
+type Compression struct {
+	minBytes  int
+	encodings []string
+	pools     map[string]*sync.Pool
+}
+
+// Levels trade ratio for speed, as every response is compressed afresh
+func NewCompression(cfg CompressionConfig) *Compression {
+	c := &Compression{minBytes: cfg.MinBytes, encodings: cfg.Encodings, pools: map[string]*sync.Pool{}}
+	if c.minBytes <= 0 {
+		c.minBytes = 1024
+	}
+	if len(c.encodings) == 0 {
+		c.encodings = defaultEncodings
+	}
+	if cfg.Disabled {
+		c.encodings = nil
+	}
+
+	newEncoders := map[string]func() encoder{
+		"br": func() encoder { return brotli.NewWriterLevel(nil, 4) },
+		"zstd": func() encoder {
+			enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+			return enc
+		},
+		"gzip": func() encoder {
+			enc, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+			return enc
+		},
+	}
+	var offered []string
+	for _, encoding := range c.encodings {
+		if newEncoder, ok := newEncoders[encoding]; ok {
+			c.pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+			offered = append(offered, encoding)
+		}
+	}
+	c.encodings = offered
+	return c
+}
+
+func (c *Compression) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(c.encodings) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiate.Encoding(r, c.encodings...)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, c: c, encoding: encoding}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Holds the body back until it is large enough to be worth compressing, then
+// either compresses it or passes it through as is
+type compressWriter struct {
+	http.ResponseWriter
+	c        *Compression
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// informational responses go out, the final one is held back
+	if code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.passThrough()
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		if !cw.compressible() {
+			cw.passThrough()
+		} else {
+			cw.buf = append(cw.buf, b...)
+			if len(cw.buf) >= cw.c.minBytes {
+				if err := cw.start(); err != nil {
+					return 0, err
+				}
+			}
+			return len(b), nil
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Streaming responses, such as NDJSON lists, are compressed from their
+// first flush on
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.compressible() {
+			if err := cw.start(); err != nil {
+				return
+			}
+		} else {
+			cw.passThrough()
+		}
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Whether the response held back is worth compressing: a text type, not
+// encoded already. Event streams are left alone, their events are too small
+// to gain and proxies may hold compressed ones back.
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if mediaType == "text/event-stream" {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case negotiate.JSON, negotiate.NDJSON, negotiate.XML, "application/yaml", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// Send the status held back and what was buffered, uncompressed
+func (cw *compressWriter) passThrough() {
+	cw.decided = true
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) > 0 {
+		cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+	}
+}
+
+// Switch to compressing, sending the buffered body through the encoder.
+// The compressed body is a different representation, so a strong ETag
+// becomes weak, which conditional GETs still match.
+func (cw *compressWriter) start() error {
+	cw.decided = true
+	h := cw.Header()
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.enc = cw.c.pools[cw.encoding].Get().(encoder)
+	cw.enc.Reset(cw.ResponseWriter)
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.enc.Write(buf)
+	return err
+}
+
+// After the handler returned: a body still held back is too small to
+// compress
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		cw.passThrough()
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		cw.enc.Reset(nil)
+		cw.c.pools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed decoding %s: %v", encoding, err)
+	}
+	return string(out)
+}
+
+func TestCompression(t *testing.T) {
+	large := `[` + strings.Repeat(`{"name":"Chicken Waffle","category":"Waffle"},`, 50) + `{}]`
+	small := `{"code":404}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		encoding       string
+	}{
+		{"gzip", "gzip", "application/json", 200, large, "gzip"},
+		{"brotli preferred", "gzip, deflate, br, zstd", "application/json", 200, large, "br"},
+		{"zstd weighed higher", "gzip;q=0.5, zstd", "application/json", 200, large, "zstd"},
+		{"below threshold", "gzip", "application/json", 404, small, ""},
+		{"not text", "gzip", "image/png", 200, large, ""},
+		{"event stream", "gzip", "text/event-stream", 200, large, ""},
+		{"no accept-encoding", "", "application/json", 200, large, ""},
+		{"unknown coding", "compress", "application/json", 200, large, ""},
+		{"not modified", "gzip", "application/json", 304, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCompression(CompressionConfig{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", `"abc-v1"`)
+				w.WriteHeader(tt.status)
+				// written in pieces, as encoders do
+				for i := 0; i < len(tt.body); i += 100 {
+					w.Write([]byte(tt.body[i:min(i+100, len(tt.body))]))
+				}
+			}))
+			req := httptest.NewRequest("GET", "/product", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tt.encoding, got)
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+			}
+			if got := decompress(t, tt.encoding, w.Body.Bytes()); got != tt.body {
+				t.Errorf("Expected body %.40q, got %.40q", tt.body, got)
+			}
+			etag := `"abc-v1"`
+			if tt.encoding != "" {
+				etag = "W/" + etag
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, got)
+			}
+		})
+	}
+}
+
+// Lines of a stream go out compressed as they are flushed
+func TestCompression_Flush(t *testing.T) {
+	flushed := make(chan string, 1)
+	h := NewCompression(CompressionConfig{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"id":"1"}`+"\n")
+		w.(http.Flusher).Flush()
+		flushed <- w.(*compressWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.String()
+		io.WriteString(w, `{"id":"2"}`+"\n")
+	}))
+	req := httptest.NewRequest("GET", "/order", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected the stream compressed, got %v", w.Header())
+	}
+	// a sync flush ends the first line's block, so it can be read as is
+	first, _ := io.ReadAll(io.LimitReader(mustGzip(t, <-flushed), 11))
+	if string(first) != `{"id":"1"}`+"\n" {
+		t.Errorf("Expected the first line after the flush, got %q", first)
+	}
+	if got := decompress(t, "gzip", w.Body.Bytes()); got != `{"id":"1"}`+"\n"+`{"id":"2"}`+"\n" {
+		t.Errorf("Unexpected stream %q", got)
+	}
+}
+
+func mustGzip(t *testing.T, body string) io.Reader {
+	t.Helper()
+	gz, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gz
+}
+
+func TestCompression_Disabled(t *testing.T) {
+	h := NewCompression(CompressionConfig{Disabled: true}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, strings.Repeat(" ", 4096))
+	}))
+	req := httptest.NewRequest("GET", "/product", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" || w.Body.Len() != 4096 {
+		t.Errorf("Expected the response untouched, got %v", w.Header())
+	}
+}
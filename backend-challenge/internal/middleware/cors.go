@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+)
+
+var (
+	defaultCorsMethods = []string{"GET", "POST", "PUT"}
+	defaultCorsHeaders = []string{"Accept", "Content-Type", AUTHORIZATION_HEADER, API_KEY_HEADER, "Idempotency-Key", "If-None-Match", "If-Modified-Since", "Last-Event-ID"}
+	defaultCorsExposed = []string{REQUEST_ID_HEADER, API_VERSION_HEADER, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Deprecation", "Sunset", "Link", "ETag", "Last-Modified"}
+)
+
+type CORSConfig struct {
+	// Origins allowed to call the API, e.g. https://cart.example.com. "*"
+	// allows any, "https://*.example.com" any subdomain. CORS is off when
+	// empty.
+	AllowedOrigins []string `json:"allowed_origins"`
+	// Methods allowed in preflights, GET, POST and PUT when unset
+	AllowedMethods []string `json:"allowed_methods"`
+	// Request headers allowed in preflights, by default Accept,
+	// Content-Type, Authorization, api_key, Idempotency-Key and the
+	// conditional request headers
+	AllowedHeaders []string `json:"allowed_headers"`
+	// Response headers scripts may read, by default the request id, API
+	// version, rate limit, deprecation and validator headers
+	ExposedHeaders []string `json:"exposed_headers"`
+	// Let scripts read responses to requests sent with cookies or HTTP
+	// authentication. Not allowed with the "*" origin, which would let any
+	// site read them.
+	AllowCredentials bool `json:"allow_credentials"`
+	// How long browsers may cache a preflight, not sent when 0
+	MaxAgeSeconds int `json:"max_age_seconds"`
+}
+
+func (c CORSConfig) withDefaults() CORSConfig {
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = defaultCorsMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = defaultCorsHeaders
+	}
+	if len(c.ExposedHeaders) == 0 {
+		c.ExposedHeaders = defaultCorsExposed
+	}
+	return c
+}
+
+// Cross-origin access for browser clients such as the shopping cart
+type CORS struct {
+	cfg     CORSConfig
+	methods string
+	headers string
+	exposed string
+}
+
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		return nil, fmt.Errorf("allow_credentials can't be set with the \"*\" origin, list the origins instead")
+	}
+	cfg = cfg.withDefaults()
+	return &CORS{
+		cfg:     cfg,
+		methods: strings.Join(cfg.AllowedMethods, ", "),
+		headers: strings.Join(cfg.AllowedHeaders, ", "),
+		exposed: strings.Join(cfg.ExposedHeaders, ", "),
+	}, nil
+}
+
+func (c *CORS) Enabled() bool {
+	return len(c.cfg.AllowedOrigins) > 0
+}
+
+// Answer preflights and add the CORS headers to the responses of allowed
+// origins. Must run before authentication, since browsers send preflights
+// without credentials. Preflights only get here with a route matching
+// OPTIONS, see PreflightHandler.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !c.Enabled() || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if c.allowOrigin(w, origin) && c.exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", c.exposed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	switch {
+	case !c.originAllowed(origin):
+		problem.Write(w, r, myerror.KartError{Code: http.StatusForbidden, Msg: "Origin " + origin + " is not allowed"})
+		return
+	case !slices.Contains(c.cfg.AllowedMethods, method):
+		problem.Write(w, r, myerror.KartError{Code: http.StatusForbidden, Msg: "Method " + method + " is not allowed"})
+		return
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !slices.ContainsFunc(c.cfg.AllowedHeaders, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+			problem.Write(w, r, myerror.KartError{Code: http.StatusForbidden, Msg: "Header " + header + " is not allowed"})
+			return
+		}
+	}
+
+	c.allowOrigin(w, origin)
+	h.Set("Access-Control-Allow-Methods", c.methods)
+	h.Set("Access-Control-Allow-Headers", c.headers)
+	if c.cfg.MaxAgeSeconds > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.cfg.MaxAgeSeconds))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Set Access-Control-Allow-Origin if origin may read the response. NewCORS
+// doesn't allow credentials with "*".
+func (c *CORS) allowOrigin(w http.ResponseWriter, origin string) bool {
+	if !c.originAllowed(origin) {
+		return false
+	}
+	if slices.Contains(c.cfg.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	for _, allowed := range c.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// https://*.example.com matches https://cart.example.com
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+			return true
+		}
+	}
+	return false
+}
+
+// Route for OPTIONS requests on any path, without which the router would
+// answer preflights with 405 before the middleware sees them. Only OPTIONS
+// requests which aren't preflights end up here.
+func (c *CORS) PreflightHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", c.methods+", OPTIONS")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsRequest(method, origin string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, "/order", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://cart.example.com", "https://*.preview.example.com"},
+		AllowCredentials: true,
+		MaxAgeSeconds:    600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { t.Error("Preflight reached the handler") })
+	h := cors.Middleware(next)
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		status  int
+	}{
+		{"order with api key", "https://cart.example.com", "POST", "content-type, api_key, idempotency-key", 204},
+		{"subdomain", "https://pr-42.preview.example.com", "GET", "", 204},
+		{"unknown origin", "https://evil.example.com", "POST", "api_key", 403},
+		{"nested subdomain trick", "https://evil.com/.preview.example.com", "GET", "", 403},
+		{"method not allowed", "https://cart.example.com", "DELETE", "", 403},
+		{"header not allowed", "https://cart.example.com", "POST", "x-debug", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, corsRequest("OPTIONS", tt.origin, map[string]string{
+				"Access-Control-Request-Method":  tt.method,
+				"Access-Control-Request-Headers": tt.headers,
+			}))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			got := w.Header()
+			if tt.status != 204 {
+				if got.Get("Access-Control-Allow-Origin") != "" {
+					t.Errorf("Expected no Access-Control-Allow-Origin, got %q", got.Get("Access-Control-Allow-Origin"))
+				}
+				return
+			}
+			if got.Get("Access-Control-Allow-Origin") != tt.origin || got.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("Expected origin %s with credentials, got %v", tt.origin, got)
+			}
+			if got.Get("Access-Control-Allow-Methods") != "GET, POST, PUT" || got.Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("Unexpected methods or max age: %v", got)
+			}
+			if got.Get("Access-Control-Allow-Headers") != "Accept, Content-Type, Authorization, api_key, Idempotency-Key, If-None-Match, If-Modified-Since, Last-Event-ID" {
+				t.Errorf("Unexpected allowed headers %q", got.Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+// Any site could read responses sent with credentials, so the config is
+// refused rather than served
+func TestNewCORS_AnyOriginWithCredentials(t *testing.T) {
+	if _, err := NewCORS(CORSConfig{AllowedOrigins: []string{"https://cart.example.com", "*"}, AllowCredentials: true}); err == nil {
+		t.Error("Expected an error for credentials with any origin")
+	}
+	if _, err := NewCORS(CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}); err != nil {
+		t.Errorf("Expected subdomains with credentials to be allowed, got %v", err)
+	}
+}
+
+func TestCORS_Request(t *testing.T) {
+	served := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served++ })
+
+	tests := []struct {
+		name   string
+		cfg    CORSConfig
+		origin string
+		allow  string
+	}{
+		{"allowed", CORSConfig{AllowedOrigins: []string{"https://cart.example.com"}}, "https://cart.example.com", "https://cart.example.com"},
+		{"any", CORSConfig{AllowedOrigins: []string{"*"}}, "https://cart.example.com", "*"},
+		{"not allowed", CORSConfig{AllowedOrigins: []string{"https://cart.example.com"}}, "https://evil.example.com", ""},
+		{"same origin", CORSConfig{AllowedOrigins: []string{"https://cart.example.com"}}, "", ""},
+		{"disabled", CORSConfig{}, "https://cart.example.com", ""},
+	}
+	for _, tt := range tests {
+		served = 0
+		cors, err := NewCORS(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		w := httptest.NewRecorder()
+		cors.Middleware(next).ServeHTTP(w, corsRequest("POST", tt.origin, nil))
+		if served != 1 {
+			t.Errorf("%s: expected the request to be served", tt.name)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", tt.name, tt.allow, got)
+		}
+		if tt.allow != "" && w.Header().Get("Access-Control-Expose-Headers") == "" {
+			t.Errorf("%s: expected exposed headers", tt.name)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+)
+
+type CouponGuardConfig struct {
+	MaxFailures       int `json:"max_failures"`
+	WindowSeconds     int `json:"window_seconds"`
+	LockoutSeconds    int `json:"lockout_seconds"`
+	MaxLockoutSeconds int `json:"max_lockout_seconds"`
+}
+
+// Failed coupon guesses of a single caller or client IP
+type couponAttempts struct {
+	failures    int
+	inFlight    int // requests let through which may still fail
+	windowStart time.Time
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// Locks out callers guessing coupon codes. Each consecutive lockout of the
+// same caller lasts twice as long as the previous one, up to the maximum.
+type CouponGuard struct {
+	mu         sync.Mutex
+	cfg        CouponGuardConfig
+	window     time.Duration
+	lockout    time.Duration
+	maxLockout time.Duration
+	attempts   map[string]*couponAttempts
+	loading    func() bool
+	now        func() time.Time
+}
+
+type couponGuardKey struct{}
+
+// Per request record of which callers to charge for an invalid coupon
+type guardedRequest struct {
+	guard  *CouponGuard
+	keys   []string
+	failed bool
+}
+
+// loading tells whether coupons are still being loaded. Coupon requests get
+// 503 meanwhile rather than fail, and count, for codes which are valid. May
+// be nil.
+func NewCouponGuard(cfg CouponGuardConfig, loading func() bool) *CouponGuard {
+	return &CouponGuard{
+		cfg:        cfg,
+		window:     time.Duration(cfg.WindowSeconds) * time.Second,
+		lockout:    time.Duration(cfg.LockoutSeconds) * time.Second,
+		maxLockout: time.Duration(cfg.MaxLockoutSeconds) * time.Second,
+		attempts:   map[string]*couponAttempts{},
+		loading:    loading,
+		now:        time.Now,
+	}
+}
+
+// Attempts of key, with its failure window started over once it ran out.
+// Must hold mu.
+func (g *CouponGuard) attempt(key string, now time.Time) *couponAttempts {
+	if len(g.attempts) >= maxTrackedBuckets {
+		g.prune(now)
+	}
+
+	a, ok := g.attempts[key]
+	if !ok {
+		a = &couponAttempts{windowStart: now}
+		g.attempts[key] = a
+	}
+
+	if now.Sub(a.windowStart) > g.window {
+		a.failures = 0
+		a.windowStart = now
+		// caller behaved for a while, start backing off from scratch again
+		if now.Sub(a.lockedUntil) > g.maxLockout {
+			a.lockouts = 0
+		}
+	}
+	return a
+}
+
+// Let a request of keys through unless one of them is locked out, or has as
+// many coupon requests in flight as failures left before its lockout.
+// Checked and counted in one go, so parallel guesses can't all slip past the
+// check before their failures are recorded. Requests without a coupon are
+// only checked. Returns how long to wait otherwise.
+func (g *CouponGuard) admit(keys []string, coupon bool) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for _, key := range keys {
+		a := g.attempt(key, now)
+		if wait := a.lockedUntil.Sub(now); wait > 0 {
+			return wait
+		}
+		if coupon && g.cfg.MaxFailures > 0 && a.failures+a.inFlight >= g.cfg.MaxFailures {
+			return time.Second
+		}
+	}
+	if !coupon {
+		return 0
+	}
+	for _, key := range keys {
+		g.attempts[key].inFlight++
+	}
+	return 0
+}
+
+// Finish a request let through by admit, counting it as a failed guess
+func (g *CouponGuard) release(keys []string, failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for _, key := range keys {
+		a := g.attempt(key, now)
+		a.inFlight = max(a.inFlight-1, 0)
+		if failed {
+			g.recordFailure(key, a, now)
+		}
+	}
+}
+
+// Count a failed guess against key, locking it out when it hits the
+// threshold. Must hold mu.
+func (g *CouponGuard) recordFailure(key string, a *couponAttempts, now time.Time) {
+	kind, _, _ := strings.Cut(key, ":")
+	metrics.CouponGuardFailures.WithLabelValues(kind).Inc()
+	a.failures++
+	if a.failures < g.cfg.MaxFailures {
+		return
+	}
+
+	a.lockouts++
+	backoff := time.Duration(float64(g.lockout) * math.Pow(2, float64(a.lockouts-1)))
+	a.lockedUntil = now.Add(min(backoff, g.maxLockout))
+	a.failures = 0
+	a.windowStart = now
+	metrics.CouponGuardLockouts.WithLabelValues(kind).Inc()
+}
+
+// Drop callers which are neither locked out nor inside a failure window
+func (g *CouponGuard) prune(now time.Time) {
+	for key, a := range g.attempts {
+		if now.After(a.lockedUntil.Add(g.maxLockout)) && now.Sub(a.windowStart) > g.window {
+			delete(g.attempts, key)
+		}
+	}
+}
+
+// Reject locked out callers with 429. Handlers report invalid coupons through
+// ReportInvalidCoupon, which counts against both the caller and client IP.
+// Runs after Auth, unauthenticated requests are only charged to their IP.
+// Every request is taken for a coupon lookup.
+func (g *CouponGuard) Middleware(next http.Handler) http.Handler {
+	return g.guard(next, func(*http.Request) bool { return true })
+}
+
+// Middleware for requests with a JSON body, e.g. orders, which are only
+// taken for a coupon lookup when they carry a couponCode
+func (g *CouponGuard) BodyMiddleware(next http.Handler) http.Handler {
+	return g.guard(next, hasCouponCode)
+}
+
+func (g *CouponGuard) guard(next http.Handler, isLookup func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{"ip:" + ClientIP(r)}
+		if p := PrincipalFromContext(r.Context()); p != nil {
+			keys = append(keys, "caller:"+p.Id())
+		}
+
+		lookup := isLookup(r)
+		if lookup && g.loading != nil && g.loading() {
+			w.Header().Set("Retry-After", "5")
+			writeResponse(w, r, 503, "Service Unavailable", "Coupons are still loading, retry later")
+			return
+		}
+		if wait := g.admit(keys, lookup); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeResponse(w, r, 429, "Too Many Requests", "Too many invalid coupon codes, retry later")
+			return
+		}
+		if !lookup {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		req := &guardedRequest{guard: g, keys: keys}
+		defer func() { g.release(req.keys, req.failed) }()
+		ctx := context.WithValue(r.Context(), couponGuardKey{}, req)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Whether the JSON body names a coupon. The body, already limited by the
+// request validator, is put back for the handler.
+func hasCouponCode(r *http.Request) bool {
+	if r.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var req struct {
+		CouponCode string `json:"couponCode"`
+	}
+	json.Unmarshal(body, &req)
+	return strings.TrimSpace(req.CouponCode) != ""
+}
+
+// Charge the caller of the request for guessing an unknown coupon code, once
+// the request is done. No-op for requests which didn't go through a
+// CouponGuard.
+func ReportInvalidCoupon(ctx context.Context) {
+	if req, ok := ctx.Value(couponGuardKey{}).(*guardedRequest); ok {
+		req.failed = true
+	}
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// How long key is locked out for, zero when it isn't
+func lockedFor(g *CouponGuard, key string) time.Duration {
+	return g.admit([]string{key}, false)
+}
+
+// Failed guesses counted against client IPs so far
+func ipFailures() float64 {
+	return testutil.ToFloat64(metrics.CouponGuardFailures.WithLabelValues("ip"))
+}
+
+// A guess by key which turned out invalid
+func fail(g *CouponGuard, key string) {
+	keys := []string{key}
+	if g.admit(keys, true) == 0 {
+		g.release(keys, true)
+	}
+}
+
+func TestCouponGuard_Lockout(t *testing.T) {
+	failures, lockouts := ipFailures(), testutil.ToFloat64(metrics.CouponGuardLockouts.WithLabelValues("ip"))
+	now := time.Now()
+	g := NewCouponGuard(CouponGuardConfig{MaxFailures: 2, WindowSeconds: 60, LockoutSeconds: 10, MaxLockoutSeconds: 30}, nil)
+	g.now = func() time.Time { return now }
+
+	fail(g, "ip:1.2.3.4")
+	if wait := lockedFor(g, "ip:1.2.3.4"); wait != 0 {
+		t.Errorf("Expected no lockout after one failure, got %v", wait)
+	}
+
+	fail(g, "ip:1.2.3.4")
+	if wait := lockedFor(g, "ip:1.2.3.4"); wait != 10*time.Second {
+		t.Errorf("Expected 10s lockout, got %v", wait)
+	}
+
+	// Second lockout backs off exponentially
+	now = now.Add(10 * time.Second)
+	fail(g, "ip:1.2.3.4")
+	fail(g, "ip:1.2.3.4")
+	if wait := lockedFor(g, "ip:1.2.3.4"); wait != 20*time.Second {
+		t.Errorf("Expected 20s lockout, got %v", wait)
+	}
+
+	// ... up to the maximum
+	now = now.Add(20 * time.Second)
+	fail(g, "ip:1.2.3.4")
+	fail(g, "ip:1.2.3.4")
+	if wait := lockedFor(g, "ip:1.2.3.4"); wait != 30*time.Second {
+		t.Errorf("Expected 30s lockout, got %v", wait)
+	}
+
+	if got := ipFailures() - failures; got != 6 {
+		t.Errorf("Expected 6 failures counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.CouponGuardLockouts.WithLabelValues("ip")) - lockouts; got != 3 {
+		t.Errorf("Expected 3 lockouts counted, got %v", got)
+	}
+}
+
+func TestCouponGuard_Middleware(t *testing.T) {
+	g := NewCouponGuard(CouponGuardConfig{MaxFailures: 1, WindowSeconds: 60, LockoutSeconds: 60, MaxLockoutSeconds: 60}, nil)
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportInvalidCoupon(r.Context())
+		w.WriteHeader(400)
+	}))
+
+	caller := &Principal{ApiKey: &model.ApiKey{Id: 1}}
+	req := httptest.NewRequest("POST", "/order", nil)
+	req = req.WithContext(WithPrincipal(req.Context(), caller))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	// Same API key from another IP is locked out too
+	req = httptest.NewRequest("POST", "/order", nil)
+	req = req.WithContext(WithPrincipal(req.Context(), caller))
+	req.RemoteAddr = "10.0.0.1:1234"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 429 {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+// Parallel guesses can't all pass the check before the first one fails
+func TestCouponGuard_InFlight(t *testing.T) {
+	now := time.Now()
+	g := NewCouponGuard(CouponGuardConfig{MaxFailures: 2, WindowSeconds: 60, LockoutSeconds: 60, MaxLockoutSeconds: 60}, nil)
+	g.now = func() time.Time { return now }
+	keys := []string{"ip:1.2.3.4"}
+
+	if g.admit(keys, true) != 0 || g.admit(keys, true) != 0 {
+		t.Fatal("Expected two guesses let through")
+	}
+	if wait := g.admit(keys, true); wait == 0 {
+		t.Error("Expected a third guess in flight rejected")
+	}
+	if wait := g.admit(keys, false); wait != 0 {
+		t.Errorf("Expected requests without a coupon let through, got %v", wait)
+	}
+
+	// a guess which succeeded frees its place
+	g.release(keys, false)
+	if wait := g.admit(keys, true); wait != 0 {
+		t.Errorf("Expected a guess let through again, got %v", wait)
+	}
+	g.release(keys, true)
+	g.release(keys, true)
+	if wait := lockedFor(g, "ip:1.2.3.4"); wait != 60*time.Second {
+		t.Errorf("Expected 60s lockout, got %v", wait)
+	}
+}
+
+func TestCouponGuard_BodyMiddleware(t *testing.T) {
+	g := NewCouponGuard(CouponGuardConfig{MaxFailures: 1, WindowSeconds: 60, LockoutSeconds: 60, MaxLockoutSeconds: 60}, nil)
+	var inFlight int
+	h := g.BodyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = g.attempts["ip:192.0.2.1"].inFlight
+		ReportInvalidCoupon(r.Context())
+		w.WriteHeader(400)
+	}))
+
+	// orders without a coupon aren't guesses
+	req := httptest.NewRequest("POST", "/order", strings.NewReader(`{"items":[]}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if inFlight != 0 || lockedFor(g, "ip:192.0.2.1") != 0 {
+		t.Errorf("Expected an order without coupon not counted, %d in flight", inFlight)
+	}
+
+	req = httptest.NewRequest("POST", "/order", strings.NewReader(`{"items":[],"couponCode":"GUESS123"}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if inFlight != 1 || lockedFor(g, "ip:192.0.2.1") == 0 {
+		t.Errorf("Expected an order with coupon counted, %d in flight", inFlight)
+	}
+}
+
+// Valid codes can't be found while coupons load, so lookups wait for it
+// rather than count as guesses
+func TestCouponGuard_Loading(t *testing.T) {
+	loading := true
+	failures := ipFailures()
+	g := NewCouponGuard(CouponGuardConfig{MaxFailures: 1, WindowSeconds: 60, LockoutSeconds: 60, MaxLockoutSeconds: 60}, func() bool { return loading })
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportInvalidCoupon(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/coupon/HAPPYHRS", nil))
+	if w.Code != 503 || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After, got %d", w.Code)
+	}
+	if got := ipFailures() - failures; got != 0 {
+		t.Errorf("Expected no failure counted, got %v", got)
+	}
+
+	loading = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/coupon/HAPPYHRS", nil))
+	if w.Code != 200 || ipFailures()-failures != 1 {
+		t.Errorf("Expected the lookup handled once loaded, got %d", w.Code)
+	}
+}
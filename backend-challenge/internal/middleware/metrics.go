@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+)
+
+// Remembers the status code written, for logging and metrics
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Streaming responses such as the audit export flush as they go
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Count requests and their latency by route template rather than path, so
+// ids in paths don't create a series each. Must be added with Router.Use,
+// which only runs it for matched routes.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		labels := []string{r.Method, route, strconv.Itoa(rec.status)}
+		metrics.HttpRequests.WithLabelValues(labels...).Inc()
+		metrics.HttpDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Template of the route mux matched, e.g. /order/{orderId}
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_ByRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Metrics)
+	r.HandleFunc("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	counter := metrics.HttpRequests.WithLabelValues("GET", "/order/{orderId}", "404")
+	before := testutil.ToFloat64(counter)
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/order/"+id, nil))
+	}
+
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("Expected 2 requests counted under the route template, got %v", got)
+	}
+}
+
+func TestStatusRecorder_ImplicitOk(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	rec.Write([]byte("{}"))
+	rec.WriteHeader(http.StatusInternalServerError)
+	if rec.status != http.StatusOK {
+		t.Errorf("Expected status 200 after an implicit header, got %d", rec.status)
+	}
+}
@@ -1,38 +1,210 @@
 package middleware
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/priykumar/oolio-kart-challenge/internal/apikey"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/bearer"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
 )
 
 const API_KEY_HEADER = "api_key"
-const EXPECTED_API_KEY = "apitest"
-
-func ApiKeyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get(API_KEY_HEADER)
-		if apiKey == "" {
-			w.WriteHeader(401)
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(model.Response{
-				Code:    401,
-				Type:    "Unauthorised",
-				Message: "Missing API key",
-			})
-			return
+const AUTHORIZATION_HEADER = "Authorization"
+
+// Last use of a key is written at most this often
+const touchInterval = time.Minute
+
+type ApiKeyStore interface {
+	GetApiKeyByHash(context.Context, string) (*model.ApiKey, error)
+	TouchApiKey(context.Context, int64, time.Time) error
+}
+
+type BearerVerifier interface {
+	Verify(string) (*bearer.Identity, error)
+}
+
+// Authenticated caller of a request, either an API key or a customer holding
+// a bearer token
+type Principal struct {
+	ApiKey     *model.ApiKey
+	CustomerId string
+	Role       rbac.Role
+	Scopes     []string
+	// When the key or token stops being valid, nil if it doesn't expire
+	ExpiresAt *time.Time
+}
+
+// Stable identifier of the caller, e.g. for per-customer limits
+func (p *Principal) Id() string {
+	if p.ApiKey != nil {
+		return fmt.Sprintf("apikey:%d", p.ApiKey.Id)
+	}
+	return "customer:" + p.CustomerId
+}
+
+type principalCtxKey struct{}
+
+// Authenticates requests by their api_key header against the key store, or
+// by an Authorization bearer token when a verifier is configured
+type Auth struct {
+	store     ApiKeyStore
+	bearer    BearerVerifier
+	mu        sync.Mutex
+	lastTouch map[int64]time.Time
+	now       func() time.Time
+}
+
+// bearer may be nil, in which case only API keys are accepted
+func NewAuth(store ApiKeyStore, bearer BearerVerifier) *Auth {
+	return &Auth{
+		store:     store,
+		bearer:    bearer,
+		lastTouch: map[int64]time.Time{},
+		now:       time.Now,
+	}
+}
+
+// Only let requests through whose API key or bearer token is valid, whose
+// role grants perm and whose key or token holds the scope perm needs. Missing
+// credentials and bad tokens are answered with 401, bad API keys and missing
+// permissions with 403.
+func (a *Auth) Authorize(perm rbac.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := a.principal(w, r)
+			if principal == nil {
+				return
+			}
+
+			if !rbac.Allows(principal.Role, principal.Scopes, perm) {
+				fmt.Printf("Denied %s to %s with role %s\n", perm, principal.Id(), principal.Role)
+				writeResponse(w, r, 403, "Forbidden", fmt.Sprintf("Missing permission %s", perm))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// Identify the caller by its bearer token or API key. On failure the error
+// response is written and nil returned.
+func (a *Auth) principal(w http.ResponseWriter, r *http.Request) *Principal {
+	token, hasToken := bearerToken(r)
+	plain := r.Header.Get(API_KEY_HEADER)
+	switch {
+	case hasToken && a.bearer != nil:
+		identity, err := a.bearer.Verify(token)
+		if err != nil {
+			fmt.Println("Rejected bearer token:", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeResponse(w, r, 401, "Unauthorised", "Invalid bearer token")
+			return nil
+		}
+		p := &Principal{CustomerId: identity.CustomerId, Role: tokenRole(identity.Roles), Scopes: identity.Scopes}
+		if !identity.ExpiresAt.IsZero() {
+			p.ExpiresAt = &identity.ExpiresAt
 		}
-		if apiKey != EXPECTED_API_KEY {
-			w.WriteHeader(403)
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(model.Response{
-				Code:    403,
-				Type:    "Forbidden",
-				Message: "Wrong API key",
-			})
-			return
+		return p
+	case plain != "":
+		key, reason := a.authenticate(r.Context(), plain)
+		if key == nil {
+			writeResponse(w, r, 403, "Forbidden", reason)
+			return nil
 		}
-		next.ServeHTTP(w, r)
-	})
+		role, err := rbac.ParseRole(key.Role)
+		if err != nil {
+			role = rbac.RoleCustomer
+		}
+		return &Principal{ApiKey: key, Role: role, Scopes: key.Scopes, ExpiresAt: key.ExpiresAt}
+	case a.bearer != nil:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeResponse(w, r, 401, "Unauthorised", "Missing API key or bearer token")
+		return nil
+	default:
+		writeResponse(w, r, 401, "Unauthorised", "Missing API key")
+		return nil
+	}
+}
+
+// Token holders are customers unless the identity provider grants a known
+// role
+func tokenRole(roles []string) rbac.Role {
+	for _, r := range roles {
+		if role, err := rbac.ParseRole(r); err == nil {
+			return role
+		}
+	}
+	return rbac.RoleCustomer
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get(AUTHORIZATION_HEADER), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Look up a live key, or say why it can't be used
+func (a *Auth) authenticate(ctx context.Context, plain string) (*model.ApiKey, string) {
+	key, err := a.store.GetApiKeyByHash(ctx, apikey.Hash(plain))
+	if err != nil {
+		if myerror.IsNotFound(err) {
+			return nil, "Wrong API key"
+		}
+		return nil, "Failed to verify API key"
+	}
+
+	now := a.now()
+	if key.RevokedAt != nil {
+		return nil, "API key has been revoked"
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, "API key has expired"
+	}
+
+	a.touch(ctx, key.Id, now)
+	return key, ""
+}
+
+// Record the last use of a key, throttled so busy keys don't cause a write
+// per request
+func (a *Auth) touch(ctx context.Context, id int64, now time.Time) {
+	a.mu.Lock()
+	last, ok := a.lastTouch[id]
+	if ok && now.Sub(last) < touchInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastTouch[id] = now
+	a.mu.Unlock()
+
+	a.store.TouchApiKey(ctx, id, now.UTC())
+}
+
+// Attach the caller to ctx, changes made with ctx are audited as theirs
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	ctx = audit.WithActor(ctx, p.Id())
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// Caller the request was authenticated as, nil when it wasn't
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalCtxKey{}).(*Principal)
+	return p
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, code int, errType, msg string) {
+	problem.WriteStatus(w, r, code, errType, msg)
 }
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/apikey"
+	"github.com/priykumar/oolio-kart-challenge/internal/bearer"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
+)
+
+// Mock ApiKeyStore
+type mockApiKeyStore struct {
+	keys    map[string]*model.ApiKey
+	touches int
+}
+
+func (m *mockApiKeyStore) GetApiKeyByHash(ctx context.Context, hash string) (*model.ApiKey, error) {
+	if key, exists := m.keys[hash]; exists {
+		return key, nil
+	}
+	return nil, myerror.KartError{Code: 404, Msg: "API key not found"}
+}
+
+func (m *mockApiKeyStore) TouchApiKey(context.Context, int64, time.Time) error {
+	m.touches++
+	return nil
+}
+
+// Mock BearerVerifier accepting tokens named after the customer
+type mockVerifier struct{}
+
+func (m *mockVerifier) Verify(token string) (*bearer.Identity, error) {
+	if token == "customer-7" {
+		return &bearer.Identity{CustomerId: token, Scopes: []string{apikey.ScopeCreateOrder}}, nil
+	}
+	return nil, fmt.Errorf("%w: bad signature", bearer.ErrInvalidToken)
+}
+
+func TestAuth_ApiKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	store := &mockApiKeyStore{keys: map[string]*model.ApiKey{
+		apikey.Hash("customer"): {Id: 1, Scopes: []string{apikey.ScopeCreateOrder}},
+		apikey.Hash("admin"):    {Id: 2, Scopes: []string{apikey.ScopeAdmin}},
+		apikey.Hash("expired"):  {Id: 3, Scopes: []string{apikey.ScopeCreateOrder}, ExpiresAt: &past},
+		apikey.Hash("revoked"):  {Id: 4, Scopes: []string{apikey.ScopeCreateOrder}, RevokedAt: &past},
+	}}
+	auth := NewAuth(store, nil)
+
+	var seen *model.ApiKey
+	h := auth.Authorize(rbac.OrderCreate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := PrincipalFromContext(r.Context()); p != nil {
+			seen = p.ApiKey
+		}
+	}))
+
+	tests := []struct {
+		key  string
+		code int
+	}{
+		{"", 401},
+		{"wrong", 403},
+		{"expired", 403},
+		{"revoked", 403},
+		{"customer", 200},
+		{"admin", 200},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/order", nil)
+		if tt.key != "" {
+			req.Header.Set(API_KEY_HEADER, tt.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("Key %q: expected status %d, got %d", tt.key, tt.code, w.Code)
+		}
+	}
+	if seen == nil || seen.Id != 2 {
+		t.Errorf("Expected admin key in request context, got %+v", seen)
+	}
+
+	// Customer key may not manage coupons
+	h = auth.Authorize(rbac.CouponManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/coupon", nil)
+	req.Header.Set(API_KEY_HEADER, "customer")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 403 {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+
+	// Last use is recorded once per key within the interval
+	if store.touches != 2 {
+		t.Errorf("Expected 2 touches, got %d", store.touches)
+	}
+}
+
+func TestAuth_Bearer(t *testing.T) {
+	store := &mockApiKeyStore{keys: map[string]*model.ApiKey{
+		apikey.Hash("customer"): {Id: 1, Scopes: []string{apikey.ScopeCreateOrder}},
+	}}
+	auth := NewAuth(store, &mockVerifier{})
+
+	var seen *Principal
+	h := auth.Authorize(rbac.OrderCreate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = PrincipalFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		code   int
+		caller string
+	}{
+		{"no credentials", "", "", 401, ""},
+		{"bad token", AUTHORIZATION_HEADER, "Bearer forged", 401, ""},
+		{"bearer token", AUTHORIZATION_HEADER, "Bearer customer-7", 200, "customer:customer-7"},
+		{"api key", API_KEY_HEADER, "customer", 200, "apikey:1"},
+	}
+	for _, tt := range tests {
+		seen = nil
+		req := httptest.NewRequest("POST", "/order", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.code, w.Code)
+		}
+		if tt.caller != "" && (seen == nil || seen.Id() != tt.caller) {
+			t.Errorf("%s: expected caller %s, got %+v", tt.name, tt.caller, seen)
+		}
+		if tt.code == 401 && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected WWW-Authenticate header", tt.name)
+		}
+	}
+
+	// Customer token may not manage coupons
+	h = auth.Authorize(rbac.CouponManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/coupon", nil)
+	req.Header.Set(AUTHORIZATION_HEADER, "Bearer customer-7")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 403 {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestAuth_Authorize(t *testing.T) {
+	store := &mockApiKeyStore{keys: map[string]*model.ApiKey{
+		apikey.Hash("customer"): {Id: 1, Role: "customer", Scopes: []string{apikey.ScopeCreateOrder}},
+		apikey.Hash("manager"):  {Id: 2, Role: "manager", Scopes: []string{apikey.ScopeCreateOrder}},
+		apikey.Hash("legacy"):   {Id: 3, Scopes: []string{apikey.ScopeAdmin}},
+	}}
+	auth := NewAuth(store, &mockVerifier{})
+	h := auth.Authorize(rbac.CouponManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		code   int
+	}{
+		{"manager key", API_KEY_HEADER, "manager", 200},
+		{"customer key", API_KEY_HEADER, "customer", 403},
+		{"key without role", API_KEY_HEADER, "legacy", 403},
+		{"customer token", AUTHORIZATION_HEADER, "Bearer customer-7", 403},
+		{"no credentials", "", "", 401},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/coupon/SAVE10/rules", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.code, w.Code)
+		}
+	}
+
+	// 403 names the missing permission
+	req := httptest.NewRequest("PUT", "/coupon/SAVE10/rules", nil)
+	req.Header.Set(API_KEY_HEADER, "customer")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var resp model.Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != 403 || resp.Message != "Missing permission coupon:manage" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
+
+func TestTokenRole(t *testing.T) {
+	if role := tokenRole([]string{"owner", "kitchen"}); role != rbac.RoleKitchen {
+		t.Errorf("Expected first known role, got %s", role)
+	}
+	if role := tokenRole(nil); role != rbac.RoleCustomer {
+		t.Errorf("Expected customer by default, got %s", role)
+	}
+}
@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Past this many tracked buckets, the ones which refilled are dropped
+const maxTrackedBuckets = 10000
+
+type RateLimitConfig struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// A zero rate disables limiting
+func (c RateLimitConfig) Enabled() bool {
+	return c.PerMinute > 0 && c.Burst > 0
+}
+
+// Limits of every route. Routes without their own entry use Default.
+type RateLimitsConfig struct {
+	Default RateLimitConfig            `json:"default"`
+	Routes  map[string]RateLimitConfig `json:"routes"`
+}
+
+// Outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until the next token, set when not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// Keeps the token buckets. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take a token from key's bucket, which starts out full
+	Take(key string, limit RateLimitConfig, now time.Time) RateLimitResult
+	// Put back a token taken for a request another bucket then refused
+	Refund(key string, limit RateLimitConfig, now time.Time)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64 // tokens per second
+	burst  float64
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+}
+
+// Buckets held in process memory, so limits are per server instance
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimitConfig, now time.Time) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buckets) >= maxTrackedBuckets {
+		s.prune(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.rate = limit.PerMinute / 60
+	b.burst = float64(limit.Burst)
+
+	b.tokens = b.refill(now)
+	b.last = now
+	res := RateLimitResult{Allowed: b.tokens >= 1}
+	if res.Allowed {
+		b.tokens--
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((b.burst - b.tokens) / b.rate * float64(time.Second))
+	return res
+}
+
+func (s *MemoryRateLimitStore) Refund(key string, limit RateLimitConfig, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.refill(now)+1)
+	b.last = now
+}
+
+// Drop buckets which have refilled completely, they are the same as new ones
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	for key, b := range s.buckets {
+		if b.refill(now) >= b.burst {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Token bucket rate limiter of one route, keyed by caller and client IP
+type RateLimiter struct {
+	route string
+	limit RateLimitConfig
+	store RateLimitStore
+	keys  func(*http.Request) []string
+	now   func() time.Time
+}
+
+func newRateLimiter(route string, cfg RateLimitConfig, store RateLimitStore, keys func(*http.Request) []string) *RateLimiter {
+	return &RateLimiter{route: route, limit: cfg, store: store, keys: keys, now: time.Now}
+}
+
+// Buckets of the client IP, and of the caller once Auth identified it.
+// Credentials which weren't verified never key a bucket, they'd give every
+// made up key a fresh one.
+func clientAndCallerKeys(r *http.Request) []string {
+	keys := clientKeys(r)
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		keys = append(keys, "caller:"+p.Id())
+	}
+	return keys
+}
+
+func clientKeys(r *http.Request) []string {
+	return []string{"ip:" + ClientIP(r)}
+}
+
+// The caller's bucket, the client IP's for requests Auth didn't identify
+func callerKeys(r *http.Request) []string {
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		return []string{"caller:" + p.Id()}
+	}
+	return clientKeys(r)
+}
+
+func (l *RateLimiter) take(key string) RateLimitResult {
+	return l.store.Take(l.route+" "+key, l.limit, l.now())
+}
+
+func (l *RateLimiter) refund(key string) {
+	l.store.Refund(l.route+" "+key, l.limit, l.now())
+}
+
+type takenTokensKey struct{}
+
+// Tokens taken for a request by the limiters it went through so far
+type takenTokens []takenToken
+
+type takenToken struct {
+	limiter *RateLimiter
+	key     string
+}
+
+// Give back every token taken for the request, once a bucket refused it. A
+// caller over its own limit doesn't use up the budget of its client IP,
+// which other callers behind the same address share.
+func (t *takenTokens) refund() {
+	for _, token := range *t {
+		token.limiter.refund(token.key)
+	}
+	*t = nil
+}
+
+// Reject the request with 429 when any of its buckets is out of tokens,
+// giving back the tokens its other buckets had. Every response carries the RateLimit headers of the tightest bucket, also
+// when limiters are stacked.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		taken, ok := r.Context().Value(takenTokensKey{}).(*takenTokens)
+		if !ok {
+			taken = &takenTokens{}
+			r = r.WithContext(context.WithValue(r.Context(), takenTokensKey{}, taken))
+		}
+
+		var tightest RateLimitResult
+		for i, key := range l.keys(r) {
+			res := l.take(key)
+			if i == 0 || res.Remaining < tightest.Remaining {
+				tightest = res
+			}
+			if !res.Allowed {
+				tightest = res
+				taken.refund()
+				break
+			}
+			*taken = append(*taken, takenToken{l, key})
+		}
+
+		setRateLimitHeaders(w, l.limit, tightest)
+		if !tightest.Allowed {
+			fmt.Println("Rate limit exceeded for client", ip, "on", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(tightest.RetryAfter)))
+			writeResponse(w, r, 429, "Too Many Requests", "Rate limit exceeded, retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, limit RateLimitConfig, res RateLimitResult) {
+	// an outer limiter left a tighter bucket
+	if prev, err := strconv.Atoi(w.Header().Get("RateLimit-Remaining")); err == nil && res.Allowed && prev <= res.Remaining {
+		return
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Rate limiters of all routes, sharing one store
+type RateLimits struct {
+	cfg   RateLimitsConfig
+	store RateLimitStore
+}
+
+func NewRateLimits(cfg RateLimitsConfig, store RateLimitStore) *RateLimits {
+	return &RateLimits{cfg: cfg, store: store}
+}
+
+// Middleware limiting route by its configured limit, or the default one, per
+// client IP and per caller when it runs after Auth. Routes whose limit is
+// disabled are let through untouched.
+func (rl *RateLimits) For(route string) func(http.Handler) http.Handler {
+	return rl.middleware(route, clientAndCallerKeys)
+}
+
+// For, per client IP only. Goes before Auth so requests failing it are
+// limited too, e.g. guesses of API keys or tokens.
+func (rl *RateLimits) ForClient(route string) func(http.Handler) http.Handler {
+	return rl.middleware(route, clientKeys)
+}
+
+// For, per caller only. Goes after Auth, next to ForClient before it.
+func (rl *RateLimits) ForCaller(route string) func(http.Handler) http.Handler {
+	return rl.middleware(route, callerKeys)
+}
+
+func (rl *RateLimits) middleware(route string, keys func(*http.Request) []string) func(http.Handler) http.Handler {
+	limit, ok := rl.cfg.Routes[route]
+	if !ok {
+		limit = rl.cfg.Default
+	}
+	if !limit.Enabled() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return newRateLimiter(route, limit, rl.store, keys).Middleware
+}
+
+// IP of the connecting client. Forwarding headers are not trusted since the
+// server is not deployed behind a known proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/rbac"
+)
+
+// Middleware limiting one route to cfg, with its own in-memory buckets
+func limitTo(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	return NewRateLimits(RateLimitsConfig{Default: cfg}, NewMemoryRateLimitStore()).For("test")
+}
+
+func TestMemoryRateLimitStore_Take(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	limit := RateLimitConfig{PerMinute: 60, Burst: 2}
+
+	if !store.Take("a", limit, now).Allowed {
+		t.Error("Expected first request to be allowed")
+	}
+	if !store.Take("a", limit, now).Allowed {
+		t.Error("Expected second request to be allowed")
+	}
+	res := store.Take("a", limit, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("Expected third request to wait 1s, got %+v", res)
+	}
+
+	// Other keys have their own bucket
+	if !store.Take("b", limit, now).Allowed {
+		t.Error("Expected other key to be allowed")
+	}
+
+	// Bucket refills over time
+	now = now.Add(time.Second)
+	if !store.Take("a", limit, now).Allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+
+	// ... and when a token is given back, up to its burst
+	store.Refund("a", limit, now)
+	store.Refund("a", limit, now)
+	store.Refund("a", limit, now)
+	if res := store.Take("a", limit, now); res.Remaining != 1 {
+		t.Errorf("Expected the bucket refilled up to its burst, got %+v", res)
+	}
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	h := limitTo(RateLimitConfig{PerMinute: 1, Burst: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/coupon/HAPPYHRS", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 429 {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+}
+
+func TestRateLimiter_Headers(t *testing.T) {
+	h := limitTo(RateLimitConfig{PerMinute: 60, Burst: 3})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/product", nil))
+	want := map[string]string{"RateLimit-Limit": "3", "RateLimit-Remaining": "2", "RateLimit-Reset": "1"}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+}
+
+// Sends a request of customer from remoteAddr, returning its status
+func sendAs(h http.Handler, customer, remoteAddr string) int {
+	req := httptest.NewRequest("POST", "/order", nil)
+	req.RemoteAddr = remoteAddr
+	req = req.WithContext(WithPrincipal(req.Context(), &Principal{CustomerId: customer}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRateLimiter_KeyedByPrincipal(t *testing.T) {
+	h := limitTo(RateLimitConfig{PerMinute: 1, Burst: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	if code := sendAs(h, "alice", "10.0.0.1:1000"); code != 200 {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+	// Same customer from another IP shares the caller bucket
+	if code := sendAs(h, "alice", "10.0.0.2:1000"); code != 429 {
+		t.Errorf("Expected status 429, got %d", code)
+	}
+	if code := sendAs(h, "bob", "10.0.0.3:1000"); code != 200 {
+		t.Errorf("Expected status 200 for other customer, got %d", code)
+	}
+}
+
+// A caller over its limit doesn't use up the budget of the IP it shares with
+// others, whether both buckets are in one limiter or stacked around Auth
+func TestRateLimiter_RefundsOnDenial(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cfg := RateLimitsConfig{Default: RateLimitConfig{PerMinute: 1, Burst: 2}}
+	stacked := NewRateLimits(cfg, NewMemoryRateLimitStore())
+
+	for name, h := range map[string]http.Handler{
+		"one limiter": NewRateLimits(cfg, NewMemoryRateLimitStore()).For("test")(ok),
+		"stacked":     stacked.ForClient("test")(stacked.ForCaller("test")(ok)),
+	} {
+		sendAs(h, "alice", "10.0.0.1:1000")
+		sendAs(h, "alice", "10.0.0.1:1000")
+		for i := 0; i < 3; i++ {
+			if code := sendAs(h, "alice", "10.0.0.2:1000"); code != 429 {
+				t.Errorf("%s: expected alice limited, got %d", name, code)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if code := sendAs(h, "bob", "10.0.0.2:1000"); code != 200 {
+				t.Errorf("%s: expected bob let through on the shared IP, got %d", name, code)
+			}
+		}
+	}
+}
+
+func TestRateLimits_For(t *testing.T) {
+	limits := NewRateLimits(RateLimitsConfig{
+		Default: RateLimitConfig{PerMinute: 1, Burst: 1},
+		Routes: map[string]RateLimitConfig{
+			"product.list": {},
+		},
+	}, NewMemoryRateLimitStore())
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	send := func(h http.Handler) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+
+	// Routes have their own buckets even with a shared store
+	create, get := limits.For("order.create")(ok), limits.For("order.get")(ok)
+	if send(create) != 200 || send(get) != 200 {
+		t.Error("Expected first request of each route to be allowed")
+	}
+	if code := send(create); code != 429 {
+		t.Errorf("Expected status 429, got %d", code)
+	}
+
+	// A disabled limit lets everything through
+	list := limits.For("product.list")(ok)
+	for i := 0; i < 5; i++ {
+		if code := send(list); code != 200 {
+			t.Fatalf("Expected status 200 on unlimited route, got %d", code)
+		}
+	}
+}
+
+// Made up API keys don't get buckets of their own, and requests failing
+// authentication still use up the client's
+func TestRateLimits_AroundAuth(t *testing.T) {
+	limits := NewRateLimits(RateLimitsConfig{Default: RateLimitConfig{PerMinute: 1, Burst: 2}}, NewMemoryRateLimitStore())
+	auth := NewAuth(&mockApiKeyStore{keys: map[string]*model.ApiKey{}}, nil)
+	h := limits.ForClient("order.get")(auth.Authorize(rbac.OrderRead)(limits.ForCaller("order.get")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	codes := []int{}
+	for _, key := range []string{"guess1", "guess2", "guess3"} {
+		req := httptest.NewRequest("GET", "/order/1", nil)
+		req.Header.Set(API_KEY_HEADER, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != 403 || codes[1] != 403 || codes[2] != 429 {
+		t.Errorf("Expected 403, 403, 429, got %v", codes)
+	}
+}
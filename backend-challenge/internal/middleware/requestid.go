@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+)
+
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// Longest request id accepted from a client, longer ones are replaced
+const maxRequestIdLength = 128
+
+// Tag every request with an id, reusing the client's X-Request-Id when it
+// sent a sane one. The id is echoed in the response and recorded with audit
+// events.
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !validRequestId(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(audit.WithRequestId(r.Context(), id)))
+	})
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+)
+
+func TestRequestId(t *testing.T) {
+	var seen string
+	h := RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = audit.RequestId(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"client id", "abc-123", true},
+		{"no id", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"control characters", "abc\x00def", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/product", nil)
+		if tt.header != "" {
+			req.Header.Set(REQUEST_ID_HEADER, tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		got := w.Header().Get(REQUEST_ID_HEADER)
+		if got == "" || got != seen {
+			t.Errorf("%s: expected response id %q to match context id %q", tt.name, got, seen)
+		}
+		if tt.keep != (got == tt.header) {
+			t.Errorf("%s: unexpected id %q", tt.name, got)
+		}
+	}
+}
+
+func TestWithPrincipal_SetsAuditActor(t *testing.T) {
+	ctx := WithPrincipal(httptest.NewRequest("GET", "/", nil).Context(), &Principal{CustomerId: "sub-1"})
+	if actor := audit.Actor(ctx); actor != "customer:sub-1" {
+		t.Errorf("Expected actor customer:sub-1, got %s", actor)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Start a server span per request, continuing the caller's trace when it
+// sent a W3C traceparent. Routes in skip, e.g. probes, aren't traced. Must be
+// added with Router.Use after RequestId.
+func Tracing(skip ...string) func(http.Handler) http.Handler {
+	untraced := map[string]bool{}
+	for _, route := range skip {
+		untraced[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return traceRequests(next, untraced)
+	}
+}
+
+func traceRequests(next http.Handler, untraced map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if untraced[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", ClientIP(r)),
+				attribute.String("request.id", audit.RequestId(r.Context())),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", rec.status))
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_ContinuesTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer provider.Shutdown(t.Context())
+
+	r := mux.NewRouter()
+	r.Use(Tracing("/healthz"))
+	r.HandleFunc("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/order/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, skipping /healthz, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /order/{orderId}" {
+		t.Errorf("Expected span named after the route template, got %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the caller's trace id, got %s", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's span as parent, got %s", got)
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Errorf("Expected error status for a 500, got %v", span.Status())
+	}
+}
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+)
+
+const (
+	defaultMaxBodyBytes   = 64 << 10
+	defaultMaxUploadBytes = 10 << 20
+)
+
+type ValidationConfig struct {
+	// Cap of JSON request bodies, 64 KiB when unset
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// Cap of other request bodies, i.e. coupon imports, 10 MiB when unset
+	MaxUploadBytes int64 `json:"max_upload_bytes"`
+}
+
+func (c ValidationConfig) withDefaults() ValidationConfig {
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if c.MaxUploadBytes <= 0 {
+		c.MaxUploadBytes = defaultMaxUploadBytes
+	}
+	return c
+}
+
+// Validates requests against the operations of the OpenAPI spec
+type RequestValidator struct {
+	doc *openapi3.T
+	cfg ValidationConfig
+}
+
+func NewRequestValidator(doc *openapi3.T, cfg ValidationConfig) *RequestValidator {
+	return &RequestValidator{doc: doc, cfg: cfg.withDefaults()}
+}
+
+// Reject requests whose parameters or JSON body don't match the spec of
+// their operation. Malformed JSON, wrong types, unknown fields and bad
+// parameters are answered with 400, values breaking a constraint of the
+// schema with 422, oversized bodies with 413. Other bodies are only capped,
+// their handlers read them as a stream. Must be added with Router.Use, so the
+// route is known. Routes missing from the spec are let through.
+func (v *RequestValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := v.route(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		isJson := takesJson(route.Operation)
+		limit := v.cfg.MaxUploadBytes
+		if isJson {
+			limit = v.cfg.MaxBodyBytes
+			switch ct := r.Header.Get("Content-Type"); {
+			case ct == "":
+				// clients predating validation didn't always send it
+				r.Header.Set("Content-Type", "application/json")
+			case !isJsonMediaType(ct):
+				problem.Write(w, r, myerror.KartError{Code: 415, Msg: "Request body must be application/json"})
+				return
+			}
+		}
+		if r.ContentLength > limit {
+			problem.Write(w, r, &http.MaxBytesError{Limit: limit})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: mux.Vars(r),
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody:  !isJson,
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				// Auth and the route's permission check the credentials
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		if err != nil {
+			problem.Write(w, r, requestError(err))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Operation of the spec matching the request's route template and method
+func (v *RequestValidator) route(r *http.Request) *routers.Route {
+	path := SpecPath(routeTemplate(r))
+	pathItem := v.doc.Paths.Value(path)
+	if pathItem == nil {
+		return nil
+	}
+	op := pathItem.GetOperation(r.Method)
+	if op == nil {
+		return nil
+	}
+	return &routers.Route{Spec: v.doc, Path: path, PathItem: pathItem, Method: r.Method, Operation: op}
+}
+
+func takesJson(op *openapi3.Operation) bool {
+	return op.RequestBody != nil && op.RequestBody.Value.Content.Get("application/json") != nil
+}
+
+func isJsonMediaType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && mediaType == "application/json"
+}
+
+// Translate the errors of openapi3filter into field errors. Anything but
+// constraint violations of well-formed values makes the request a 400.
+func requestError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return tooLarge
+	}
+
+	code := 422
+	var fields []error
+	for _, e := range flatten(err) {
+		var reqErr *openapi3filter.RequestError
+		if !errors.As(e, &reqErr) {
+			code = 400
+			fields = append(fields, e)
+			continue
+		}
+
+		switch {
+		case reqErr.Parameter != nil:
+			code = 400
+			msg := reqErr.Reason
+			if se := schemaError(reqErr.Err); se != nil {
+				msg = se.Reason
+			} else if reqErr.Err != nil {
+				msg = reqErr.Err.Error()
+			}
+			fields = append(fields, fieldError(reqErr.Parameter.Name, msg))
+		case errors.Is(reqErr.Err, openapi3filter.ErrInvalidRequired):
+			code = 400
+			fields = append(fields, myerror.FieldError{Message: "No request body found"})
+		case reqErr.Err == nil:
+			code = 400
+			fields = append(fields, myerror.FieldError{Message: reqErr.Reason})
+		default:
+			schemaErrs := schemaErrors(reqErr.Err)
+			if len(schemaErrs) == 0 {
+				// the body couldn't be decoded at all
+				code = 400
+				fields = append(fields, myerror.FieldError{Message: "Request body is not valid JSON"})
+			}
+			for _, se := range schemaErrs {
+				if se.SchemaField == "type" || se.SchemaField == "properties" {
+					code = 400
+				}
+				reason := se.Reason
+				if se.SchemaField == "format" {
+					// the reason spells out the format's regexp
+					reason = fmt.Sprintf("value must be a %s", se.Schema.Format)
+				}
+				fields = append(fields, fieldError(bodyField(se), reason))
+			}
+		}
+	}
+	return myerror.NewValidationError(code, fields...)
+}
+
+// Messages name their field, as legacy responses only carry the message
+func fieldError(field, reason string) myerror.FieldError {
+	if field == "" {
+		return myerror.FieldError{Message: reason}
+	}
+	return myerror.FieldError{Field: field, Message: field + ": " + reason}
+}
+
+// Errors of a MultiError, recursively. Errors wrapping one are kept whole.
+func flatten(err error) []error {
+	me, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range me {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
+
+func schemaErrors(err error) []*openapi3.SchemaError {
+	var errs []*openapi3.SchemaError
+	for _, e := range flatten(err) {
+		if se := schemaError(e); se != nil {
+			errs = append(errs, se)
+		}
+	}
+	return errs
+}
+
+func schemaError(err error) *openapi3.SchemaError {
+	var se *openapi3.SchemaError
+	if errors.As(err, &se) {
+		return se
+	}
+	return nil
+}
+
+// Path of the offending value in the body, e.g. items[0].quantity. Unknown
+// properties are reported on their object, so their name is taken from the
+// reason.
+func bodyField(se *openapi3.SchemaError) string {
+	path := se.JSONPointer()
+	if se.SchemaField == "properties" {
+		var name string
+		if _, err := fmt.Sscanf(se.Reason, "property %q is unsupported", &name); err == nil {
+			path = append(path, name)
+		}
+	}
+
+	var b strings.Builder
+	for _, part := range path {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/openapi"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+)
+
+func validatedRouter(t *testing.T, cfg ValidationConfig) *mux.Router {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	r := mux.NewRouter()
+	r.Use(NewRequestValidator(doc, cfg).Middleware)
+	r.HandleFunc("/order", ok).Methods("POST", "GET")
+	r.HandleFunc("/product/{productId}", ok).Methods("GET")
+	r.HandleFunc("/coupon/import", ok).Methods("POST")
+	r.HandleFunc("/unlisted", ok).Methods("POST")
+	return r
+}
+
+func TestRequestValidator(t *testing.T) {
+	r := validatedRouter(t, ValidationConfig{})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		fields      []string
+	}{
+		{"valid order", "POST", "/order", "application/json", `{"items":[{"productId":"1","quantity":2}]}`, 204, nil},
+		{"no content type", "POST", "/order", "", `{"items":[{"productId":"1","quantity":2}]}`, 204, nil},
+		{"malformed json", "POST", "/order", "application/json", `{"items":[`, 400, nil},
+		{"empty body", "POST", "/order", "application/json", ``, 400, nil},
+		{"unknown field", "POST", "/order", "application/json", `{"items":[{"productId":"1","quantity":2,"price":1}]}`, 400, []string{"items[0].price"}},
+		{"wrong type", "POST", "/order", "application/json", `{"items":[{"productId":"1","quantity":"2"}]}`, 400, []string{"items[0].quantity"}},
+		{"constraints", "POST", "/order", "application/json", `{"items":[{"productId":"","quantity":0}]}`, 422, []string{"items[0].productId", "items[0].quantity"}},
+		{"missing field", "POST", "/order", "application/json", `{"items":[{"quantity":1}]}`, 422, []string{"items[0].productId"}},
+		{"form body", "POST", "/order", "application/x-www-form-urlencoded", `items=1`, 415, nil},
+		{"query out of range", "GET", "/order?limit=501", "", ``, 400, []string{"limit"}},
+		{"path not an integer", "GET", "/product/abc", "", ``, 400, []string{"productId"}},
+		{"csv not parsed", "POST", "/coupon/import", "text/csv", "HAPPYHRS,18\n", 204, nil},
+		{"not in spec", "POST", "/unlisted", "application/json", `{"anything":1}`, 204, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req.Header.Set("Accept", problem.ContentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if tt.fields == nil {
+				return
+			}
+			var body model.Problem
+			json.NewDecoder(w.Body).Decode(&body)
+			var fields []string
+			for _, f := range body.Errors {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Expected errors for %v, got %+v", tt.fields, body.Errors)
+			}
+		})
+	}
+}
+
+func TestRequestValidator_BodyLimits(t *testing.T) {
+	r := validatedRouter(t, ValidationConfig{MaxBodyBytes: 64, MaxUploadBytes: 16})
+
+	for target, body := range map[string]string{
+		"/order":         `{"items":[{"productId":"1","quantity":2}],"couponCode":"HAPPYHRSHAPPYHRS"}`,
+		"/coupon/import": "HAPPYHRS,18\nFIFTYOFF,50\n",
+	} {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		if target == "/coupon/import" {
+			req.Header.Set("Content-Type", "text/csv")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status 413, got %d", target, w.Code)
+		}
+	}
+}
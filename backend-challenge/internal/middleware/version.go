@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/problem"
+)
+
+// Version served on the unversioned paths when Accept doesn't ask for one
+const DefaultVersion = "v1"
+
+// Header naming the API version which served the response
+const API_VERSION_HEADER = "Api-Version"
+
+type VersionConfig struct {
+	// When the version was or will be deprecated
+	Deprecation *time.Time `json:"deprecation"`
+	// When the version stops being served
+	Sunset *time.Time `json:"sunset"`
+	// Version clients should move to, e.g. v2
+	Successor string `json:"successor"`
+}
+
+// Lifecycle of each API version, by name. Versions missing are current.
+type VersionsConfig map[string]VersionConfig
+
+// e.g. application/vnd.kart.v2+json
+var versionMediaType = regexp.MustCompile(`^application/vnd\.kart\.(v[0-9]+)\+json$`)
+
+// Version asked for by the Accept header, empty if it names none
+func AcceptedVersion(r *http.Request) string {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if m := versionMediaType.FindStringSubmatch(mediaType); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// Matches requests whose Accept header asks for version
+func AcceptsVersion(version string) mux.MatcherFunc {
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		return AcceptedVersion(r) == version
+	}
+}
+
+// Path of the spec documenting a route template. The default version is
+// listed without its prefix.
+func SpecPath(template string) string {
+	if rest, ok := strings.CutPrefix(template, "/"+DefaultVersion+"/"); ok {
+		return "/" + rest
+	}
+	return template
+}
+
+// Mark responses with the version serving them. Deprecated versions add the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers, and a Link to the
+// same path in their successor.
+func (c VersionsConfig) Middleware(version string) func(http.Handler) http.Handler {
+	cfg := c[version]
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set(API_VERSION_HEADER, version)
+			if cfg.Deprecation != nil {
+				h.Set("Deprecation", fmt.Sprintf("@%d", cfg.Deprecation.Unix()))
+			}
+			if cfg.Sunset != nil {
+				h.Set("Sunset", cfg.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if cfg.Successor != "" {
+				h.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successorPath(r.URL.Path, version, cfg.Successor)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func successorPath(path, version, successor string) string {
+	return "/" + successor + strings.TrimPrefix(path, "/"+version)
+}
+
+// For the unversioned paths, where the Accept header picks the version:
+// responses vary by it, and versions other than supported are answered
+// with 406.
+func NegotiateVersion(supported ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			if v := AcceptedVersion(r); v != "" && !slices.Contains(supported, v) {
+				problem.Write(w, r, myerror.KartError{Code: http.StatusNotAcceptable, Msg: fmt.Sprintf("API version %s is not supported, use one of %s", v, strings.Join(supported, ", "))})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
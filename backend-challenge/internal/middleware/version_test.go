@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAcceptedVersion(t *testing.T) {
+	tests := []struct {
+		accept  string
+		version string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"application/vnd.kart.v2+json", "v2"},
+		{"application/json, application/vnd.kart.v2+json;q=0.9", "v2"},
+		{"application/vnd.kart.v10+json", "v10"},
+		{"application/vnd.kart.2+json", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/order", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := AcceptedVersion(req); got != tt.version {
+			t.Errorf("%q: expected version %q, got %q", tt.accept, tt.version, got)
+		}
+	}
+}
+
+func TestSpecPath(t *testing.T) {
+	for template, path := range map[string]string{
+		"/v1/order/{orderId}": "/order/{orderId}",
+		"/v2/order/{orderId}": "/v2/order/{orderId}",
+		"/order":              "/order",
+		"/v1abc":              "/v1abc",
+	} {
+		if got := SpecPath(template); got != path {
+			t.Errorf("%s: expected %s, got %s", template, path, got)
+		}
+	}
+}
+
+func TestVersionsConfig_Middleware(t *testing.T) {
+	deprecation := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC)
+	versions := VersionsConfig{"v1": {Deprecation: &deprecation, Sunset: &sunset, Successor: "v2"}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for path, link := range map[string]string{"/v1/order/1": "</v2/order/1>", "/order/1": "</v2/order/1>"} {
+		w := httptest.NewRecorder()
+		versions.Middleware("v1")(ok).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		h := w.Header()
+		if h.Get("Deprecation") != "@1792368000" || h.Get("Sunset") != "Fri, 30 Apr 2027 00:00:00 GMT" {
+			t.Errorf("%s: unexpected Deprecation %q or Sunset %q", path, h.Get("Deprecation"), h.Get("Sunset"))
+		}
+		if h.Get("Link") != link+`; rel="successor-version"` || h.Get(API_VERSION_HEADER) != "v1" {
+			t.Errorf("%s: unexpected Link %q or version %q", path, h.Get("Link"), h.Get(API_VERSION_HEADER))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	versions.Middleware("v2")(ok).ServeHTTP(w, httptest.NewRequest("GET", "/v2/order", nil))
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" || w.Header().Get("Link") != "" {
+		t.Errorf("Expected no lifecycle headers on a current version, got %v", w.Header())
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	h := NegotiateVersion("v1", "v2")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for accept, status := range map[string]int{"": 200, "application/vnd.kart.v2+json": 200, "application/vnd.kart.v3+json": 406} {
+		req := httptest.NewRequest("GET", "/order", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%q: expected status %d, got %d", accept, status, w.Code)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%q: expected Vary: Accept, got %q", accept, w.Header().Get("Vary"))
+		}
+	}
+}
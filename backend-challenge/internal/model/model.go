@@ -1,8 +1,33 @@
 package model
 
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// ApiResponse of the spec, which names its XML element
 type Response struct {
-	Code    int32  `json:"code"`
-	Type    string `json:"type"`
+	XMLName xml.Name `json:"-" xml:"ApiResponse"`
+	Code    int32    `json:"code" xml:"code"`
+	Type    string   `json:"type" xml:"type"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// RFC 7807 problem details, sent instead of Response to clients accepting
+// application/problem+json
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	RequestId string         `json:"requestId,omitempty"`
+	Errors    []ProblemField `json:"errors,omitempty"`
+}
+
+type ProblemField struct {
+	Field   string `json:"field,omitempty"`
 	Message string `json:"message"`
 }
 
@@ -19,6 +44,17 @@ type Product struct {
 	Price    float64 `json:"price"`
 	Category string  `json:"category"`
 	Image    Image   `json:"image"`
+	// When the product was last changed, not part of the API
+	UpdatedAt time.Time `json:"-"`
+}
+
+// The available products, with validators for conditional requests
+type Catalog struct {
+	Products []Product
+	// Hash of the products, changing with any of them
+	ETag string
+	// Latest change to any product, including unavailable ones
+	LastModified time.Time
 }
 
 type OrderedProduct struct {
@@ -29,6 +65,9 @@ type OrderedProduct struct {
 type OrderDetail struct {
 	CouponCode     string           `json:"couponCode"`
 	OrderedProduct []OrderedProduct `json:"items"`
+	CallerId       string           `json:"-"` // caller placing the order, for per-customer coupon caps
+	Subject        string           `json:"-"` // bearer token subject of the caller, if any
+	CustomerId     string           `json:"-"` // registered customer owning the order
 }
 
 type OrderResp struct {
@@ -36,4 +75,190 @@ type OrderResp struct {
 	Total          float64          `json:"total"`
 	Discount       float64          `json:"discounts"`
 	OrderedProduct []OrderedProduct `json:"items"`
+	Products       []Product        `json:"products"` // each ordered product once
+	CustomerId     string           `json:"customerId,omitempty"`
+	CouponCode     string           `json:"couponCode,omitempty"`
+	CreatedAt      *time.Time       `json:"createdAt,omitempty"`
+	Status         string           `json:"status,omitempty"`
+	Eta            *time.Time       `json:"eta,omitempty"` // when the order should be ready, while it is placed or preparing
+	UpdatedAt      *time.Time       `json:"-"`
+}
+
+// Lifecycle of an order: placed, preparing, ready and completed. It can be
+// cancelled until it is ready.
+const (
+	OrderPlaced    = "placed"
+	OrderPreparing = "preparing"
+	OrderReady     = "ready"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+)
+
+// Statuses an order doesn't leave
+var FinalOrderStatuses = []string{OrderCompleted, OrderCancelled}
+
+// Move an order to Status and set its ETA. Without a status only the ETA
+// changes, a nil ETA keeps the current one.
+type OrderStatusUpdate struct {
+	Status string     `json:"status"`
+	Eta    *time.Time `json:"eta"`
+}
+
+// State of an order as streamed to the clients watching it
+type OrderEvent struct {
+	OrderId   string     `json:"orderId"`
+	Status    string     `json:"status"`
+	Eta       *time.Time `json:"eta,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Who is reading orders. Staff see every order, anyone else only the orders
+// of the customer their bearer token belongs to.
+type OrderAccess struct {
+	Subject string
+	All     bool
+}
+
+type OrderFilter struct {
+	CustomerId string
+	Limit      int
+	Offset     int
+}
+
+type Coupon struct {
+	Code     string  `json:"code"`
+	Discount float64 `json:"discount"`
+	Locked   bool    `json:"-"` // discount was set explicitly, the discount policy must not change it
+}
+
+// Coupon created by an admin. Discount falls back to the discount policy.
+type CouponCreateReq struct {
+	Code     string       `json:"code"`
+	Discount *float64     `json:"discount"`
+	Rules    *CouponRules `json:"rules"`
+}
+
+// Coupon along with its state and usage, as listed to admins
+type CouponInfo struct {
+	Code          string      `json:"code"`
+	Discount      float64     `json:"discount"`
+	Disabled      bool        `json:"disabled"`
+	Rules         CouponRules `json:"rules"`
+	TimesUsed     int64       `json:"timesUsed"`
+	TotalDiscount float64     `json:"totalDiscount"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
+
+type CouponFilter struct {
+	Search   string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+type CouponImportResult struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Invalid  int      `json:"invalid"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type CouponDiscountReq struct {
+	Discount *float64 `json:"discount"`
+}
+
+// Lifecycle rules of a coupon. Nil fields mean no restriction.
+type CouponRules struct {
+	ValidFrom          *time.Time `json:"validFrom,omitempty"`
+	ValidTo            *time.Time `json:"validTo,omitempty"`
+	MaxUses            *int64     `json:"maxUses,omitempty"`
+	MaxUsesPerCustomer *int64     `json:"maxUsesPerCustomer,omitempty"`
+	MinOrderValue      float64    `json:"minOrderValue"`
+}
+
+// Result of looking up a coupon code. Unknown codes only get a reason. Known
+// codes get their rules even when they can't be applied, so the lookup does
+// tell whether a code exists; the discount is only filled for valid ones.
+type CouponStatus struct {
+	Code         string       `json:"code"`
+	Valid        bool         `json:"valid"`
+	Reason       string       `json:"reason,omitempty"`
+	DiscountType string       `json:"discountType,omitempty"`
+	Discount     float64      `json:"discount,omitempty"`
+	Rules        *CouponRules `json:"rules,omitempty"`
+}
+
+// API key as stored, without the key itself
+type ApiKey struct {
+	Id         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       string     `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type Customer struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CustomerReq struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// A recorded change. Before is absent for creations, After for deletions.
+type AuditEvent struct {
+	Id         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Entity     string          `json:"entity"`
+	EntityId   string          `json:"entityId"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestId  string          `json:"requestId,omitempty"`
+}
+
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	Entity    string
+	EntityId  string
+	RequestId string
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int
+	Offset    int
+}
+
+// Result of a liveness or readiness check, with the state of each checked
+// component
+type Health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type ComponentStatus struct {
+	Status  string `json:"status"`
+	Version string `json:"version,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type Status struct {
+	Status     string                     `json:"status"`
+	Version    string                     `json:"version"`
+	Revision   string                     `json:"revision,omitempty"`
+	GoVersion  string                     `json:"goVersion"`
+	StartedAt  time.Time                  `json:"startedAt"`
+	Uptime     string                     `json:"uptime"`
+	Components map[string]ComponentStatus `json:"components"`
 }
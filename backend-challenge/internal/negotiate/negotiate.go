@@ -0,0 +1,127 @@
+// Picks the media type and content coding of a response from the Accept and
+// Accept-Encoding headers of the request, as RFC 9110 section 12.5 describes.
+package negotiate
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSON   = "application/json"
+	NDJSON = "application/x-ndjson"
+	XML    = "application/xml"
+)
+
+// A media range or coding of an Accept header, with its weight
+type preference struct {
+	value string
+	q     float64
+}
+
+func parse(headers []string, mediaTypes bool) []preference {
+	var prefs []preference
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			value, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			// codings are case-insensitive, as mime lower-cases them too
+			if !mediaTypes && strings.Contains(value, "/") {
+				continue
+			}
+			pref := preference{value: value, q: 1}
+			if q, ok := params["q"]; ok {
+				v, err := strconv.ParseFloat(q, 64)
+				if err != nil || v < 0 || v > 1 {
+					continue
+				}
+				pref.q = v
+			}
+			prefs = append(prefs, pref)
+		}
+	}
+	return prefs
+}
+
+// Weight the client gives mediaType, taken from the most specific range
+// matching it, and how specific that range is. -1 when none matches.
+func weight(prefs []preference, mediaType string) (float64, int) {
+	q, specificity := -1.0, -1
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, pref := range prefs {
+		s := -1
+		switch {
+		case pref.value == mediaType:
+			s = 2
+		case pref.value == major+"/*":
+			s = 1
+		case pref.value == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = pref.q, s
+		}
+	}
+	return q, specificity
+}
+
+// The offer the client prefers, by weight and then by the order of offers.
+// Without an Accept header that is the first offer, when the client accepts
+// none of them it is "".
+func MediaType(r *http.Request, offers ...string) string {
+	values := r.Header.Values("Accept")
+	if len(values) == 0 && len(offers) > 0 {
+		return offers[0]
+	}
+	prefs := parse(values, true)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q, _ := weight(prefs, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Whether the client asks for mediaType explicitly and prefers it to
+// application/json. Clients sending */* only get JSON.
+func Prefers(r *http.Request, mediaType string) bool {
+	prefs := parse(r.Header.Values("Accept"), true)
+	q, specificity := weight(prefs, mediaType)
+	if specificity < 2 || q <= 0 {
+		return false
+	}
+	jsonQ, _ := weight(prefs, JSON)
+	return q > jsonQ
+}
+
+// The coding among offers the client prefers, by weight and then by the
+// order of offers, or "" when the response should not be encoded
+func Encoding(r *http.Request, offers ...string) string {
+	prefs := parse(r.Header.Values("Accept-Encoding"), false)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := -1.0
+		for _, pref := range prefs {
+			if pref.value == offer {
+				q = pref.q
+				break
+			}
+			if pref.value == "*" {
+				q = pref.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,82 @@
+package negotiate
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", JSON},
+		{"*/*", JSON},
+		{"application/x-ndjson", NDJSON},
+		{"application/json;q=0.5, application/x-ndjson", NDJSON},
+		{"application/*;q=0.5, application/x-ndjson;q=0.2", JSON},
+		{"application/x-ndjson, */*;q=0.1", NDJSON},
+		{"application/x-ndjson;q=0, */*", JSON},
+		{"application/vnd.kart.v2+json", ""},
+		{"text/html", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/product", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := MediaType(r, JSON, NDJSON); got != tt.want {
+			t.Errorf("Accept %q: expected %q, got %q", tt.accept, tt.want, got)
+		}
+	}
+}
+
+func TestPrefers(t *testing.T) {
+	tests := map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/*":                     false,
+		"application/xml":                   true,
+		"application/json, application/xml": false,
+		"application/xml, application/json;q=0.9":                         true,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": true,
+		"application/xml;q=0": false,
+	}
+	for accept, want := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		if got := Prefers(r, XML); got != want {
+			t.Errorf("Accept %q: expected %v, got %v", accept, want, got)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	offers := []string{"br", "zstd", "gzip"}
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "br"},
+		{"gzip;q=1, br;q=0.5", "gzip"},
+		{"GZIP", "gzip"},
+		{"*", "br"},
+		{"*, br;q=0", "zstd"},
+		{"gzip;q=0", ""},
+		{"deflate", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		if got := Encoding(r, offers...); got != tt.want {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", tt.acceptEncoding, tt.want, got)
+		}
+	}
+}
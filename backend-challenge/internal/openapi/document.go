@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Page rendering the spec served next to it, without external assets
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// The embedded spec parsed once, keeping the order of its keys
+var specNode = sync.OnceValues(func() (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(Spec, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("openapi.yaml is not a mapping")
+	}
+	return &doc, nil
+})
+
+// The spec as YAML with its servers replaced by serverURL
+func YAML(serverURL string) ([]byte, error) {
+	doc, err := withServer(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// The spec as JSON with its servers replaced by serverURL. Keys keep the
+// order of the YAML.
+func JSON(serverURL string) ([]byte, error) {
+	doc, err := withServer(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := writeJSON(&compact, doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Shallow copy of the spec with a servers block of its own. The parsed spec
+// is shared between requests, so it is never modified.
+func withServer(serverURL string) (*yaml.Node, error) {
+	spec, err := specNode()
+	if err != nil {
+		return nil, err
+	}
+	root := *spec.Content[0]
+	root.Content = slices.Clone(root.Content)
+
+	servers := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "url"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: serverURL},
+		},
+	}}}
+	i := slices.IndexFunc(root.Content, func(n *yaml.Node) bool { return n.Value == "servers" })
+	if i >= 0 && i%2 == 0 {
+		root.Content[i+1] = servers
+	} else {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "servers"}, servers)
+	}
+
+	doc := *spec
+	doc.Content = []*yaml.Node{&root}
+	return &doc, nil
+}
+
+func writeJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return writeJSON(buf, n.Content[0])
+	case yaml.AliasNode:
+		return writeJSON(buf, n.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(n.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		var v any = n.Value
+		switch n.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			if err := n.Decode(&v); err != nil {
+				return err
+			}
+		}
+		// timestamps and anything else stay strings, as in the YAML
+		out, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		buf.Write(out)
+	}
+	return nil
+}
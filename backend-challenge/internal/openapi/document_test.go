@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func TestYAMLAndJSON_RewriteServers(t *testing.T) {
+	for name, render := range map[string]func(string) ([]byte, error){"yaml": YAML, "json": JSON} {
+		t.Run(name, func(t *testing.T) {
+			out, err := render("http://localhost:8080")
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := openapi3.NewLoader().LoadFromData(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Servers) != 1 || doc.Servers[0].URL != "http://localhost:8080" {
+				t.Errorf("Expected the server http://localhost:8080, got %+v", doc.Servers)
+			}
+			if doc.Paths.Find("/order/{orderId}") == nil {
+				t.Error("Expected /order/{orderId} in the spec")
+			}
+		})
+	}
+}
+
+func TestJSON_KeepsOrder(t *testing.T) {
+	out, err := JSON("http://localhost:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("{\n  \"openapi\": \"3.1.0\",\n  \"info\"")) {
+		t.Errorf("Expected openapi and info first, got %.60s", out)
+	}
+}
+
+func TestYAML_LeavesSpecAlone(t *testing.T) {
+	before := append([]byte(nil), Spec...)
+	if _, err := YAML("http://a.example"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := YAML("http://b.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("a.example")) {
+		t.Error("Server of an earlier request leaked into the spec")
+	}
+	if !bytes.Equal(before, Spec) {
+		t.Error("Embedded spec was modified")
+	}
+}
@@ -0,0 +1,29 @@
+// The API specification, api/openapi.yaml at the repository root. A copy is
+// embedded so the binary validates against the spec it was built with; run
+// go generate after editing the original.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:generate cp ../../../api/openapi.yaml openapi.yaml
+
+//go:embed openapi.yaml
+var Spec []byte
+
+// Parse and validate the embedded spec
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+	if err != nil {
+		return nil, err
+	}
+	// the spec is OpenAPI 3.1, where schemas may list examples
+	if err := doc.Validate(context.Background(), openapi3.AllowExtraSiblingFields("examples")); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
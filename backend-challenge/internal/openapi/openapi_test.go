@@ -0,0 +1,27 @@
+package openapi
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSpec_InSyncWithApi(t *testing.T) {
+	original, err := os.ReadFile("../../../api/openapi.yaml")
+	if err != nil {
+		t.Skip("api/openapi.yaml not found:", err)
+	}
+	if !bytes.Equal(original, Spec) {
+		t.Error("Embedded spec differs from api/openapi.yaml, run go generate ./internal/openapi")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Paths.Find("/order/{orderId}") == nil {
+		t.Error("Expected /order/{orderId} in the spec")
+	}
+}
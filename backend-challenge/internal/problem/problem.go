@@ -0,0 +1,137 @@
+// Translates errors into HTTP error responses, either RFC 7807 problem
+// details or the legacy model.Response, as JSON or XML, depending on what the
+// client accepts.
+package problem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/negotiate"
+)
+
+const ContentType = "application/problem+json"
+
+// Shown instead of the messages of unexpected errors, which may leak internals
+const internalMessage = "Internal server error"
+
+// Status, legacy type and message of an error response
+type Problem struct {
+	Status int
+	// type of the legacy model.Response, e.g. "invalid input"
+	Type   string
+	Detail string
+	Fields []myerror.FieldError
+}
+
+// Map any error chain to a response. ValidationErrors and KartErrors keep
+// their code and message, bare sentinels of myerror their code, bodies over
+// http.MaxBytesReader's limit are a 413, anything else is a 500 without
+// details.
+func From(err error) Problem {
+	var verr myerror.ValidationError
+	if errors.As(err, &verr) {
+		return Problem{Status: verr.Code, Type: legacyType(verr.Code), Detail: verr.Error(), Fields: verr.Fields}
+	}
+	var kErr myerror.KartError
+	if errors.As(err, &kErr) && kErr.Code >= 400 && kErr.Code < 600 {
+		return Problem{Status: kErr.Code, Type: legacyType(kErr.Code), Detail: kErr.Msg}
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return Problem{Status: 413, Type: legacyType(413), Detail: fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)}
+	}
+	for code, sentinel := range myerror.Code2Err {
+		if code != 500 && errors.Is(err, sentinel) {
+			return Problem{Status: code, Type: legacyType(code), Detail: sentinel.Error()}
+		}
+	}
+
+	fmt.Println("Unexpected error:", err)
+	return Problem{Status: 500, Type: legacyType(500), Detail: internalMessage}
+}
+
+// Type of legacy responses, as they always reported it
+func legacyType(code int) string {
+	if err, ok := myerror.Code2Err[code]; ok {
+		return err.Error()
+	}
+	return http.StatusText(code)
+}
+
+// Write err as the response to r
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	From(err).Write(w, r)
+}
+
+// Write an error response which doesn't stem from an error value
+func WriteStatus(w http.ResponseWriter, r *http.Request, code int, errType, msg string) {
+	Problem{Status: code, Type: errType, Detail: msg}.Write(w, r)
+}
+
+func (p Problem) Write(w http.ResponseWriter, r *http.Request) {
+	if !Accepts(r) {
+		resp := model.Response{
+			Code:    int32(p.Status),
+			Type:    p.Type,
+			Message: p.Detail,
+		}
+		if negotiate.Prefers(r, negotiate.XML) {
+			w.Header().Set("Content-Type", negotiate.XML)
+			w.WriteHeader(p.Status)
+			io.WriteString(w, xml.Header)
+			xml.NewEncoder(w).Encode(resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(p.Status)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	body := model.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(p.Status),
+		Status:    p.Status,
+		Detail:    p.Detail,
+		Instance:  r.URL.Path,
+		RequestId: audit.RequestId(r.Context()),
+	}
+	for _, f := range p.Fields {
+		body.Errors = append(body.Errors, model.ProblemField{Field: f.Field, Message: f.Message})
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Whether the client prefers problem details. Only an explicit
+// application/problem+json with a non-zero q does, so existing clients
+// sending */* or application/json keep getting model.Response.
+func Accepts(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != ContentType {
+				continue
+			}
+			if q, ok := params["q"]; ok {
+				if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package problem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"kart error", myerror.KartError{Code: 404, Msg: "Order not found"}, 404, "Order not found"},
+		{"wrapped kart error", fmt.Errorf("placing order: %w", myerror.KartError{Code: 409, Msg: "Already used"}), 409, "Already used"},
+		{"sentinel", fmt.Errorf("lookup: %w", myerror.ErrForbidden), 403, myerror.ErrForbidden.Error()},
+		{"validation", myerror.NewValidationError(422, myerror.FieldError{Field: "email", Message: "bad email"}), 422, "bad email"},
+		{"unexpected", errors.New("disk I/O error"), 500, internalMessage},
+		{"internal sentinel", myerror.ErrInternalServer, 500, internalMessage},
+		{"out of range code", myerror.KartError{Code: 200, Msg: "fine"}, 500, internalMessage},
+		{"nil", nil, 500, internalMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := From(tt.err)
+			if p.Status != tt.status || p.Detail != tt.detail {
+				t.Errorf("Expected %d %q, got %d %q", tt.status, tt.detail, p.Status, p.Detail)
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := map[string]bool{
+		"":                         false,
+		"*/*":                      false,
+		"application/json":         false,
+		"application/problem+json": true,
+		"application/json, application/problem+json;q=0.5": true,
+		"application/problem+json;q=0":                     false,
+		"application/problem+json;q=nope":                  false,
+	}
+
+	for accept, want := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		if got := Accepts(r); got != want {
+			t.Errorf("Accept %q: expected %v, got %v", accept, want, got)
+		}
+	}
+}
+
+func TestWrite_Problem(t *testing.T) {
+	r := httptest.NewRequest("POST", "/customer", nil)
+	r.Header.Set("Accept", ContentType)
+	r = r.WithContext(audit.WithRequestId(r.Context(), "req-1"))
+	w := httptest.NewRecorder()
+
+	err := errors.Join(
+		myerror.FieldError{Field: "name", Message: "name is required"},
+		myerror.FieldError{Field: "email", Message: "email is not a valid address"},
+	)
+	Write(w, r, myerror.NewValidationError(422, err))
+
+	if w.Code != 422 || w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("Expected 422 %s, got %d %s", ContentType, w.Code, w.Header().Get("Content-Type"))
+	}
+	var body model.Problem
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Type != "about:blank" || body.Title != "Unprocessable Entity" || body.Instance != "/customer" || body.RequestId != "req-1" {
+		t.Errorf("Unexpected problem %+v", body)
+	}
+	if len(body.Errors) != 2 || body.Errors[0].Field != "name" || body.Errors[1].Field != "email" {
+		t.Errorf("Expected errors for name and email, got %+v", body.Errors)
+	}
+}
+
+func TestWrite_Legacy(t *testing.T) {
+	r := httptest.NewRequest("GET", "/order/1", nil)
+	w := httptest.NewRecorder()
+	Write(w, r, myerror.KartError{Code: 404, Msg: "Order not found"})
+
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected 404 application/json, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var body model.Response
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := model.Response{Code: 404, Type: myerror.ErrNotFound.Error(), Message: "Order not found"}
+	if body != want {
+		t.Errorf("Expected %+v, got %+v", want, body)
+	}
+}
+
+func TestWrite_XML(t *testing.T) {
+	r := httptest.NewRequest("GET", "/order/1", nil)
+	r.Header.Set("Accept", "application/xml, application/json;q=0.5")
+	w := httptest.NewRecorder()
+	Write(w, r, myerror.KartError{Code: 404, Msg: "Order not found"})
+
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/xml" {
+		t.Fatalf("Expected 404 application/xml, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := xml.Header + "<ApiResponse><code>404</code><type>" + myerror.ErrNotFound.Error() + "</type><message>Order not found</message></ApiResponse>"
+	if w.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, w.Body)
+	}
+}
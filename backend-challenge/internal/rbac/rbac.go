@@ -0,0 +1,77 @@
+package rbac
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/apikey"
+)
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleStaff    Role = "staff"
+	RoleKitchen  Role = "kitchen"
+	RoleManager  Role = "manager"
+	RoleAdmin    Role = "admin"
+)
+
+var KnownRoles = []Role{RoleCustomer, RoleStaff, RoleKitchen, RoleManager, RoleAdmin}
+
+type Permission string
+
+const (
+	OrderCreate  Permission = "order:create"
+	OrderRead    Permission = "order:read"
+	OrderReadAll Permission = "order:read_all" // orders of every customer, not just the caller's
+	OrderUpdate  Permission = "order:update"   // move orders along their lifecycle
+	CouponRead   Permission = "coupon:read"
+	CouponManage Permission = "coupon:manage"
+	CouponImport Permission = "coupon:import"
+	CustomerSelf Permission = "customer:self"
+	AuditRead    Permission = "audit:read"
+	MetricsRead  Permission = "metrics:read" // business figures such as revenue are among them
+)
+
+// What each role may do. Admins may do everything, including permissions
+// added later which aren't listed here.
+var matrix = map[Role][]Permission{
+	RoleCustomer: {OrderCreate, OrderRead, CouponRead, CustomerSelf},
+	RoleKitchen:  {OrderRead, OrderReadAll, OrderUpdate},
+	RoleStaff:    {OrderCreate, OrderRead, OrderReadAll, OrderUpdate, CouponRead},
+	RoleManager:  {OrderCreate, OrderRead, OrderReadAll, OrderUpdate, CouponRead, CouponManage, CouponImport, MetricsRead},
+}
+
+// Scope a key or token must also hold to use a permission. Scopes narrow
+// what a credential may do on behalf of its role, management permissions
+// are granted by role alone.
+var scopes = map[Permission]string{
+	OrderCreate:  apikey.ScopeCreateOrder,
+	CouponRead:   apikey.ScopeCreateOrder,
+	CustomerSelf: apikey.ScopeCreateOrder,
+	OrderRead:    apikey.ScopeReadOrders,
+	OrderReadAll: apikey.ScopeReadOrders,
+}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if !slices.Contains(KnownRoles, role) {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Whether role grants the permission
+func Can(role Role, perm Permission) bool {
+	return role == RoleAdmin || slices.Contains(matrix[role], perm)
+}
+
+// Whether a credential of role holding scopes may use the permission
+func Allows(role Role, granted []string, perm Permission) bool {
+	if !Can(role, perm) {
+		return false
+	}
+	scope, ok := scopes[perm]
+	return !ok || apikey.HasScope(granted, scope)
+}
@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/apikey"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{RoleCustomer, OrderCreate, true},
+		{RoleCustomer, OrderReadAll, false},
+		{RoleCustomer, CouponManage, false},
+		{RoleKitchen, OrderReadAll, true},
+		{RoleKitchen, OrderCreate, false},
+		{RoleKitchen, OrderUpdate, true},
+		{RoleCustomer, OrderUpdate, false},
+		{RoleStaff, OrderReadAll, true},
+		{RoleStaff, CouponManage, false},
+		{RoleManager, CouponManage, true},
+		{RoleManager, CouponImport, true},
+		{RoleManager, MetricsRead, true},
+		{RoleStaff, MetricsRead, false},
+		{RoleAdmin, CouponImport, true},
+		{RoleAdmin, Permission("anything:new"), true},
+		{Role(""), OrderCreate, false},
+	}
+	for _, tt := range tests {
+		if got := Can(tt.role, tt.perm); got != tt.want {
+			t.Errorf("Can(%s, %s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestAllows_NarrowedByScopes(t *testing.T) {
+	if !Allows(RoleCustomer, []string{apikey.ScopeCreateOrder}, OrderCreate) {
+		t.Error("Expected customer with create_order to place orders")
+	}
+	if Allows(RoleCustomer, []string{apikey.ScopeCreateOrder}, OrderRead) {
+		t.Error("Expected customer without read_orders to be denied reading orders")
+	}
+	if !Allows(RoleManager, nil, CouponManage) {
+		t.Error("Expected manager to manage coupons regardless of scopes")
+	}
+	if Allows(RoleCustomer, []string{apikey.ScopeAdmin}, CouponManage) {
+		t.Error("Expected the admin scope not to grant permissions the role lacks")
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if role, err := ParseRole("kitchen"); err != nil || role != RoleKitchen {
+		t.Errorf("Expected kitchen, got %q, %v", role, err)
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
@@ -0,0 +1,255 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+type ApiKeyRepository interface {
+	CreateApiKey(context.Context, model.ApiKey, string) (*model.ApiKey, error)
+	GetApiKeyByHash(context.Context, string) (*model.ApiKey, error)
+	ListApiKeys(context.Context) ([]model.ApiKey, error)
+	CountApiKeys(context.Context) (int, error)
+	RevokeApiKey(context.Context, int64) error
+	ExpireApiKeys(context.Context, string, time.Time) (int64, error)
+	TouchApiKey(context.Context, int64, time.Time) error
+}
+
+// API keys live in the same database as the rest of the kart
+func InitialiseApiKeyRepository() ApiKeyRepository {
+	InitialiseDatabase()
+	return repo
+}
+
+const apiKeyColumns = `id, name, prefix, role, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func scanApiKey(scan func(...any) error) (*model.ApiKey, error) {
+	var key model.ApiKey
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := scan(
+		&key.Id,
+		&key.Name,
+		&key.Prefix,
+		&key.Role,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = strings.Split(scopes, ",")
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// Store a new key by its hash
+func (k *kartRepository) CreateApiKey(ctx context.Context, key model.ApiKey, hash string) (*model.ApiKey, error) {
+	defer observe("create_api_key")()
+	key.CreatedAt = time.Now().UTC()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "create_api_key")
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO api_keys (name, key_hash, prefix, role, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.Name, hash, key.Prefix, key.Role, strings.Join(key.Scopes, ","), key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			fmt.Println("API key already exists:", key.Name)
+			return nil, myerror.KartError{Code: 409, Msg: "API key already exists"}
+		}
+		fmt.Println("Failed inserting API key. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	key.Id, _ = res.LastInsertId()
+	if err = recordEvent(ctx, tx, audit.ApiKeyCreate, audit.EntityApiKey, fmt.Sprint(key.Id), nil, key); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	return &key, nil
+}
+
+func loadApiKey(ctx context.Context, q queryRower, id int64) (*model.ApiKey, error) {
+	row := q.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id)
+	key, err := scanApiKey(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, myerror.KartError{Code: 404, Msg: "API key not found"}
+		}
+		fmt.Println("Failed quering api_keys table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	return key, nil
+}
+
+func (k *kartRepository) GetApiKeyByHash(ctx context.Context, hash string) (*model.ApiKey, error) {
+	defer observe("get_api_key")()
+	row := k.dbClient.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash)
+	key, err := scanApiKey(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, myerror.KartError{Code: 404, Msg: "API key not found"}
+		}
+		fmt.Println("Failed quering api_keys table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+
+	return key, nil
+}
+
+func (k *kartRepository) ListApiKeys(ctx context.Context) ([]model.ApiKey, error) {
+	defer observe("list_api_keys")()
+	rows, err := k.dbClient.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		fmt.Println("Failed quering api_keys table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	defer rows.Close()
+
+	keys := []model.ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows.Scan)
+		if err != nil {
+			fmt.Println("Failed scanning rows. Error:", err)
+			return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		fmt.Println("Failed scanning rows. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+	}
+
+	return keys, nil
+}
+
+func (k *kartRepository) CountApiKeys(ctx context.Context) (int, error) {
+	defer observe("count_api_keys")()
+	var count int
+	if err := k.dbClient.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys`).Scan(&count); err != nil {
+		fmt.Println("Failed counting API keys. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	return count, nil
+}
+
+func (k *kartRepository) RevokeApiKey(ctx context.Context, id int64) error {
+	defer observe("revoke_api_key")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "revoke_api_key")
+
+	before, err := loadApiKey(ctx, tx, id)
+	if err != nil || before.RevokedAt != nil {
+		return myerror.KartError{Code: 404, Msg: "API key not found or already revoked"}
+	}
+
+	now := time.Now().UTC()
+	if _, err = tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ?`, now, id); err != nil {
+		fmt.Println("Failed revoking API key. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+
+	after := *before
+	after.RevokedAt = &now
+	if err = recordEvent(ctx, tx, audit.ApiKeyRevoke, audit.EntityApiKey, fmt.Sprint(id), before, after); err != nil {
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+	return nil
+}
+
+// Make the live keys of name expire at the given time at the latest. Used to
+// give clients a grace period when rotating keys.
+func (k *kartRepository) ExpireApiKeys(ctx context.Context, name string, at time.Time) (int64, error) {
+	defer observe("expire_api_keys")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "expire_api_keys")
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE name = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`, name, at)
+	if err != nil {
+		fmt.Println("Failed quering api_keys table. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	keys := []model.ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows.Scan)
+		if err != nil {
+			rows.Close()
+			fmt.Println("Failed scanning rows. Error:", err)
+			return 0, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+		keys = append(keys, *key)
+	}
+	rows.Close()
+
+	for _, before := range keys {
+		if _, err = tx.ExecContext(ctx, `UPDATE api_keys SET expires_at = ? WHERE id = ?`, at, before.Id); err != nil {
+			fmt.Println("Failed expiring API keys. Error:", err)
+			return 0, myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+		}
+
+		after := before
+		after.ExpiresAt = &at
+		if err = recordEvent(ctx, tx, audit.ApiKeyExpire, audit.EntityApiKey, fmt.Sprint(before.Id), before, after); err != nil {
+			return 0, myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+	return int64(len(keys)), nil
+}
+
+func (k *kartRepository) TouchApiKey(ctx context.Context, id int64, at time.Time) error {
+	defer observe("touch_api_key")()
+	if _, err := k.dbClient.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id); err != nil {
+		fmt.Println("Failed updating API key last use. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+	return nil
+}
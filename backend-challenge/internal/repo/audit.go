@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Satisfied by both *sql.DB and *sql.Tx. Events are recorded in the
+// transaction of the change they describe, so neither is kept without the
+// other.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Append an audit event for a change made with ctx. before and after are
+// stored as JSON, nil for none.
+func recordEvent(ctx context.Context, ex execer, action, entity, entityId string, before, after any) error {
+	snapshot := func(v any) (sql.NullString, error) {
+		if v == nil {
+			return sql.NullString{}, nil
+		}
+		b, err := json.Marshal(v)
+		return sql.NullString{String: string(b), Valid: true}, err
+	}
+
+	b, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	a, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.ExecContext(ctx, `INSERT INTO audit_events (occurred_at, actor, action, entity, entity_id, before, after, request_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC(), audit.Actor(ctx), action, entity, entityId, b, a, audit.RequestId(ctx))
+	if err != nil {
+		fmt.Println("Failed recording audit event. Error:", err)
+	}
+	return err
+}
+
+const auditColumns = `id, occurred_at, actor, action, entity, entity_id, before, after, request_id`
+
+func auditWhere(filter model.AuditFilter) (string, []any) {
+	conds := []string{}
+	args := []any{}
+	add := func(cond string, arg any) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	if filter.Actor != "" {
+		add("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		add("entity = ?", filter.Entity)
+	}
+	if filter.EntityId != "" {
+		add("entity_id = ?", filter.EntityId)
+	}
+	if filter.RequestId != "" {
+		add("request_id = ?", filter.RequestId)
+	}
+	if filter.Since != nil {
+		add("occurred_at >= ?", filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		add("occurred_at < ?", filter.Until.UTC())
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func scanAuditEvent(scan func(...any) error) (*model.AuditEvent, error) {
+	var e model.AuditEvent
+	var before, after sql.NullString
+	err := scan(
+		&e.Id,
+		&e.OccurredAt,
+		&e.Actor,
+		&e.Action,
+		&e.Entity,
+		&e.EntityId,
+		&before,
+		&after,
+		&e.RequestId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return &e, nil
+}
+
+// List audit events matching filter, newest first
+func (k *kartRepository) ListAuditEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	defer observe("list_audit_events")()
+	where, args := auditWhere(filter)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := k.dbClient.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		fmt.Println("Failed quering audit_events table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	defer rows.Close()
+
+	events := []model.AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			fmt.Println("Failed scanning rows. Error:", err)
+			return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+		events = append(events, *e)
+	}
+
+	if err = rows.Err(); err != nil {
+		fmt.Println("Failed scanning rows. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+	}
+
+	return events, nil
+}
+
+// Stream every audit event matching filter to emit, oldest first. Limit and
+// offset are ignored.
+func (k *kartRepository) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, emit func(model.AuditEvent) error) error {
+	defer observe("export_audit_events")()
+	where, args := auditWhere(filter)
+
+	rows, err := k.dbClient.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY id`, args...)
+	if err != nil {
+		fmt.Println("Failed quering audit_events table. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			fmt.Println("Failed scanning rows. Error:", err)
+			return myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+		if err = emit(*e); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		fmt.Println("Failed scanning rows. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+// Satisfied by both *sql.DB and *sql.Tx, so coupon checks can run inside the
+// order transaction or standalone
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// A coupon row along with its lifecycle rules
+type couponRecord struct {
+	id                 int64
+	code               string
+	discount           float64
+	validFrom          sql.NullTime
+	validTo            sql.NullTime
+	maxUses            sql.NullInt64
+	maxUsesPerCustomer sql.NullInt64
+	minOrderValue      float64
+	timesUsed          int64
+	disabled           bool
+}
+
+func loadCoupon(ctx context.Context, q queryRower, promo string) (*couponRecord, error) {
+	c := couponRecord{code: promo}
+	err := q.QueryRowContext(ctx, `SELECT id, discount, valid_from, valid_to, max_uses, max_uses_per_customer, min_order_value, times_used, disabled
+		FROM coupons WHERE promo_code = ?`, promo).Scan(
+		&c.id,
+		&c.discount,
+		&c.validFrom,
+		&c.validTo,
+		&c.maxUses,
+		&c.maxUsesPerCustomer,
+		&c.minOrderValue,
+		&c.timesUsed,
+		&c.disabled,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fmt.Println("Invalid coupon code is provided")
+			return nil, myerror.ErrInvalidCoupon
+		}
+		fmt.Println("Failed to check promo code in coupon table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+
+	return &c, nil
+}
+
+// Check the activation window and global usage cap
+func (c *couponRecord) checkUsable(now time.Time) error {
+	if c.disabled {
+		fmt.Println("Coupon disabled:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code is disabled"}
+	}
+	if c.validFrom.Valid && now.Before(c.validFrom.Time) {
+		fmt.Println("Coupon not yet active:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code is not yet active"}
+	}
+	if c.validTo.Valid && !now.Before(c.validTo.Time) {
+		fmt.Println("Coupon expired:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code has expired"}
+	}
+	if c.maxUses.Valid && c.timesUsed >= c.maxUses.Int64 {
+		fmt.Println("Coupon usage limit reached:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code has been fully redeemed"}
+	}
+
+	return nil
+}
+
+// Record a redemption of the coupon. Caps are re-checked in the UPDATE/INSERT
+// statements themselves so concurrent orders can't overshoot them.
+func redeemCoupon(ctx context.Context, tx *sql.Tx, c *couponRecord, customerId, orderId string) error {
+	res, err := tx.ExecContext(ctx, `UPDATE coupons SET times_used = times_used + 1
+		WHERE id = ? AND (max_uses IS NULL OR times_used < max_uses)`, c.id)
+	if err != nil {
+		fmt.Println("Failed updating coupon usage. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		fmt.Println("Coupon usage limit reached:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code has been fully redeemed"}
+	}
+
+	res, err = tx.ExecContext(ctx, `INSERT INTO coupon_redemptions (coupon_id, customer_id, order_id)
+		SELECT ?, ?, ? WHERE ? IS NULL OR
+			(SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND customer_id = ?) < ?`,
+		c.id, customerId, orderId, c.maxUsesPerCustomer, c.id, customerId, c.maxUsesPerCustomer)
+	if err != nil {
+		fmt.Println("Failed recording coupon redemption. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		fmt.Println("Coupon usage limit reached for customer:", c.code)
+		return myerror.KartError{Code: 422, Msg: "Coupon code usage limit reached for this customer"}
+	}
+
+	return nil
+}
+
+// State of a coupon recorded in audit events
+type couponSnapshot struct {
+	Code      string            `json:"code"`
+	Discount  float64           `json:"discount"`
+	Disabled  bool              `json:"disabled"`
+	Rules     model.CouponRules `json:"rules"`
+	TimesUsed int64             `json:"timesUsed"`
+}
+
+func (c *couponRecord) snapshot() couponSnapshot {
+	return couponSnapshot{
+		Code:      c.code,
+		Discount:  c.discount,
+		Disabled:  c.disabled,
+		Rules:     *c.rules(),
+		TimesUsed: c.timesUsed,
+	}
+}
+
+// Apply update to a coupon in a transaction and audit it as action. update
+// runs the UPDATE and applies the same change to the record it's given.
+func (k *kartRepository) updateCoupon(ctx context.Context, promo, action string, update func(*sql.Tx, *couponRecord) error) error {
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "update_coupon")
+
+	c, err := loadCoupon(ctx, tx, promo)
+	if err != nil {
+		if myerror.IsInvalidCoupon(err) {
+			fmt.Println("Coupon not found:", promo)
+			return myerror.KartError{Code: 404, Msg: "Coupon not found"}
+		}
+		return err
+	}
+	before := c.snapshot()
+
+	if err = update(tx, c); err != nil {
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+	if err = recordEvent(ctx, tx, action, audit.EntityCoupon, promo, before, c.snapshot()); err != nil {
+		return myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+	return nil
+}
+
+// Replace the lifecycle rules of a coupon
+func (k *kartRepository) UpdateCouponRules(ctx context.Context, promo string, rules model.CouponRules) (*model.CouponRules, error) {
+	defer observe("update_coupon_rules")()
+	err := k.updateCoupon(ctx, promo, audit.CouponUpdateRules, func(tx *sql.Tx, c *couponRecord) error {
+		_, err := tx.ExecContext(ctx, `UPDATE coupons SET valid_from = ?, valid_to = ?, max_uses = ?, max_uses_per_customer = ?,
+			min_order_value = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			rules.ValidFrom, rules.ValidTo, rules.MaxUses, rules.MaxUsesPerCustomer, rules.MinOrderValue, c.id)
+		if err != nil {
+			fmt.Println("Failed updating coupon rules. Error:", err)
+			return err
+		}
+
+		c.validFrom, c.validTo = sql.NullTime{}, sql.NullTime{}
+		c.maxUses, c.maxUsesPerCustomer = sql.NullInt64{}, sql.NullInt64{}
+		if rules.ValidFrom != nil {
+			c.validFrom = sql.NullTime{Time: *rules.ValidFrom, Valid: true}
+		}
+		if rules.ValidTo != nil {
+			c.validTo = sql.NullTime{Time: *rules.ValidTo, Valid: true}
+		}
+		if rules.MaxUses != nil {
+			c.maxUses = sql.NullInt64{Int64: *rules.MaxUses, Valid: true}
+		}
+		if rules.MaxUsesPerCustomer != nil {
+			c.maxUsesPerCustomer = sql.NullInt64{Int64: *rules.MaxUsesPerCustomer, Valid: true}
+		}
+		c.minOrderValue = rules.MinOrderValue
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+func (c *couponRecord) rules() *model.CouponRules {
+	rules := &model.CouponRules{MinOrderValue: c.minOrderValue}
+	if c.validFrom.Valid {
+		rules.ValidFrom = &c.validFrom.Time
+	}
+	if c.validTo.Valid {
+		rules.ValidTo = &c.validTo.Time
+	}
+	if c.maxUses.Valid {
+		rules.MaxUses = &c.maxUses.Int64
+	}
+	if c.maxUsesPerCustomer.Valid {
+		rules.MaxUsesPerCustomer = &c.maxUsesPerCustomer.Int64
+	}
+	return rules
+}
+
+// Look up whether a coupon can be applied right now, without redeeming it
+func (k *kartRepository) GetCouponStatus(ctx context.Context, promo string) (*model.CouponStatus, error) {
+	defer observe("get_coupon_status")()
+	status := &model.CouponStatus{Code: promo}
+
+	c, err := loadCoupon(ctx, k.dbClient, promo)
+	observeCoupon("lookup", err)
+	if err != nil {
+		if myerror.IsInvalidCoupon(err) {
+			status.Reason = myerror.ErrInvalidCoupon.Msg
+			return status, nil
+		}
+		return nil, err
+	}
+	status.Rules = c.rules()
+
+	if err = c.checkUsable(time.Now()); err != nil {
+		var kErr myerror.KartError
+		if !errors.As(err, &kErr) || kErr.Code == 500 {
+			return nil, err
+		}
+		status.Reason = kErr.Msg
+		return status, nil
+	}
+	status.Valid = true
+	status.DiscountType = "percentage"
+	status.Discount = c.discount
+
+	return status, nil
+}
+
+// Add a single coupon. Locked coupons keep their discount when coupons are
+// re-populated from the discount policy.
+func (k *kartRepository) CreateCoupon(ctx context.Context, cpn model.Coupon, rules model.CouponRules) (*model.CouponInfo, error) {
+	defer observe("create_coupon")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "create_coupon")
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO coupons (promo_code, discount, discount_locked, valid_from, valid_to,
+		max_uses, max_uses_per_customer, min_order_value) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		cpn.Code, cpn.Discount, cpn.Locked, rules.ValidFrom, rules.ValidTo, rules.MaxUses, rules.MaxUsesPerCustomer, rules.MinOrderValue)
+	if err != nil {
+		if isUniqueViolation(err) {
+			fmt.Println("Coupon already exists:", cpn.Code)
+			return nil, myerror.KartError{Code: 409, Msg: "Coupon already exists"}
+		}
+		fmt.Println("Failed inserting coupon. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	info := &model.CouponInfo{
+		Code:      cpn.Code,
+		Discount:  cpn.Discount,
+		Rules:     rules,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = recordEvent(ctx, tx, audit.CouponCreate, audit.EntityCoupon, cpn.Code, nil, info); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	return info, nil
+}
+
+// Add a batch of coupons in one transaction, skipping codes which already
+// exist. Returns how many were inserted.
+func (k *kartRepository) ImportCoupons(ctx context.Context, coupons []model.Coupon) (int, error) {
+	defer observe("import_coupons")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "import_coupons")
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO coupons (promo_code, discount, discount_locked) VALUES (?, ?, ?)`)
+	if err != nil {
+		fmt.Println("failed to prepare statement to be executed")
+		return 0, myerror.KartError{Code: 500, Msg: "Failed to prepare statement"}
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, cpn := range coupons {
+		res, err := stmt.ExecContext(ctx, cpn.Code, cpn.Discount, cpn.Locked)
+		if err != nil {
+			fmt.Println("Failed inserting coupon. Error:", err)
+			return 0, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+			after := couponSnapshot{Code: cpn.Code, Discount: cpn.Discount}
+			if err = recordEvent(ctx, tx, audit.CouponImport, audit.EntityCoupon, cpn.Code, nil, after); err != nil {
+				return 0, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return 0, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	return inserted, nil
+}
+
+// List coupons with their usage, newest first
+func (k *kartRepository) ListCoupons(ctx context.Context, filter model.CouponFilter) ([]model.CouponInfo, error) {
+	defer observe("list_coupons")()
+	cmd := `SELECT c.promo_code, c.discount, c.disabled, c.valid_from, c.valid_to, c.max_uses, c.max_uses_per_customer,
+		c.min_order_value, c.times_used, COALESCE(SUM(o.discounts), 0), c.created_at
+	FROM coupons c LEFT JOIN orders o ON o.coupon_id = c.id
+	WHERE c.promo_code LIKE ? ESCAPE '\'`
+	args := []any{"%" + escapeLike(filter.Search) + "%"}
+	if filter.Disabled != nil {
+		cmd += ` AND c.disabled = ?`
+		args = append(args, *filter.Disabled)
+	}
+	cmd += ` GROUP BY c.id ORDER BY c.id DESC LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := k.dbClient.QueryContext(ctx, cmd, args...)
+	if err != nil {
+		fmt.Println("Failed quering coupons table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	defer rows.Close()
+
+	coupons := []model.CouponInfo{}
+	for rows.Next() {
+		var c couponRecord
+		var info model.CouponInfo
+		err := rows.Scan(
+			&c.code,
+			&c.discount,
+			&c.disabled,
+			&c.validFrom,
+			&c.validTo,
+			&c.maxUses,
+			&c.maxUsesPerCustomer,
+			&c.minOrderValue,
+			&c.timesUsed,
+			&info.TotalDiscount,
+			&info.CreatedAt,
+		)
+		if err != nil {
+			fmt.Println("Failed scanning rows. Error:", err)
+			return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+
+		info.Code = c.code
+		info.Discount = c.discount
+		info.Disabled = c.disabled
+		info.Rules = *c.rules()
+		info.TimesUsed = c.timesUsed
+		coupons = append(coupons, info)
+	}
+
+	if err = rows.Err(); err != nil {
+		fmt.Println("Failed scanning rows. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+	}
+
+	return coupons, nil
+}
+
+// Disabled coupons stay in the table so orders keep referencing them
+func (k *kartRepository) SetCouponDisabled(ctx context.Context, promo string, disabled bool) error {
+	defer observe("set_coupon_disabled")()
+	action := audit.CouponEnable
+	if disabled {
+		action = audit.CouponDisable
+	}
+
+	return k.updateCoupon(ctx, promo, action, func(tx *sql.Tx, c *couponRecord) error {
+		_, err := tx.ExecContext(ctx, `UPDATE coupons SET disabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			disabled, c.id)
+		if err != nil {
+			fmt.Println("Failed updating coupon. Error:", err)
+			return err
+		}
+		c.disabled = disabled
+		return nil
+	})
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
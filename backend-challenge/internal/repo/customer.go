@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+func customerConflict(err error, c model.CustomerReq, subject string) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed: customers.external_id") {
+		fmt.Println("Customer already registered:", subject)
+		return myerror.KartError{Code: 409, Msg: "Customer already registered"}
+	}
+	if strings.Contains(err.Error(), "UNIQUE constraint failed: customers.email") {
+		fmt.Println("Email already registered:", c.Email)
+		return myerror.KartError{Code: 409, Msg: "Email already registered"}
+	}
+	return nil
+}
+
+// Register a customer for the identity provider subject
+func (k *kartRepository) CreateCustomer(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	defer observe("create_customer")()
+	c := model.Customer{
+		Id:        uuid.New().String(),
+		Name:      req.Name,
+		Email:     req.Email,
+		Phone:     req.Phone,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "create_customer")
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO customers (id, external_id, name, email, phone, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.Id, subject, c.Name, c.Email, c.Phone, c.CreatedAt)
+	if err != nil {
+		if cErr := customerConflict(err, req, subject); cErr != nil {
+			return nil, cErr
+		}
+		fmt.Println("Failed inserting customer. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	if err = recordEvent(ctx, tx, audit.CustomerCreate, audit.EntityCustomer, c.Id, nil, c); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	return &c, nil
+}
+
+func loadCustomer(ctx context.Context, q queryRower, subject string) (*model.Customer, error) {
+	var c model.Customer
+	err := q.QueryRowContext(ctx, `SELECT id, name, email, phone, created_at FROM customers WHERE external_id = ?`, subject).Scan(
+		&c.Id,
+		&c.Name,
+		&c.Email,
+		&c.Phone,
+		&c.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, myerror.KartError{Code: 404, Msg: "Customer not registered"}
+		}
+		fmt.Println("Failed quering customers table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+
+	return &c, nil
+}
+
+func (k *kartRepository) GetCustomerBySubject(ctx context.Context, subject string) (*model.Customer, error) {
+	defer observe("get_customer")()
+	return loadCustomer(ctx, k.dbClient, subject)
+}
+
+func (k *kartRepository) UpdateCustomer(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	defer observe("update_customer")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "update_customer")
+
+	before, err := loadCustomer(ctx, tx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE customers SET name = ?, email = ?, phone = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, req.Name, req.Email, req.Phone, before.Id)
+	if err != nil {
+		if cErr := customerConflict(err, req, subject); cErr != nil {
+			return nil, cErr
+		}
+		fmt.Println("Failed updating customer. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+
+	after := *before
+	after.Name, after.Email, after.Phone = req.Name, req.Email, req.Phone
+	if err = recordEvent(ctx, tx, audit.CustomerUpdate, audit.EntityCustomer, before.Id, before, after); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	return &after, nil
+}
@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"context"
+)
+
+// Stored in PRAGMA user_version once CreateTables has run. Bump it when
+// CreateTables changes the schema.
+const CurrentSchemaVersion = 1
+
+type HealthRepository interface {
+	Ping(context.Context) error
+	SchemaVersion(context.Context) (int, error)
+	SqliteVersion(context.Context) (string, error)
+}
+
+func InitialiseHealthRepository() HealthRepository {
+	InitialiseDatabase()
+	return repo
+}
+
+func (k *kartRepository) Ping(ctx context.Context) error {
+	return k.dbClient.PingContext(ctx)
+}
+
+// Version of the schema the database was last migrated to
+func (k *kartRepository) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := k.dbClient.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+func (k *kartRepository) SqliteVersion(ctx context.Context) (string, error) {
+	var version string
+	err := k.dbClient.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&version)
+	return version, err
+}
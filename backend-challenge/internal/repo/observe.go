@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Time a repository operation, the returned func records it when done:
+//
+//	defer observe("place_order")()
+func observe(operation string) func() {
+	start := time.Now()
+	return func() {
+		metrics.DbQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Deferred in place of tx.Rollback, counts the transactions which weren't
+// committed
+func rollback(tx *sql.Tx, operation string) {
+	if err := tx.Rollback(); err == nil {
+		metrics.DbRollbacks.WithLabelValues(operation).Inc()
+	}
+}
+
+// Count whether a coupon code looked up for source exists. Failed queries
+// are neither.
+func observeCoupon(source string, err error) {
+	switch {
+	case err == nil:
+		metrics.CouponLookups.WithLabelValues(source, metrics.CouponHit).Inc()
+	case myerror.IsInvalidCoupon(err):
+		metrics.CouponLookups.WithLabelValues(source, metrics.CouponMiss).Inc()
+	}
+}
+
+// Every statement, transaction and commit becomes a span, but only within a
+// traced request. Startup work such as populating coupons would otherwise
+// export a trace per statement.
+var traceOptions = []otelsql.Option{
+	otelsql.WithAttributes(attribute.String("db.system", "sqlite")),
+	otelsql.WithSpanOptions(otelsql.SpanOptions{
+		OmitConnResetSession: true,
+		OmitConnectorConnect: true,
+		OmitRows:             true,
+		SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+			return trace.SpanContextFromContext(ctx).IsValid()
+		},
+	}),
+}
@@ -0,0 +1,327 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+)
+
+const orderColumns = `o.id, o.total, o.discounts, COALESCE(o.customer_id, ''), COALESCE(c.promo_code, ''), o.created_at,
+	o.status, o.eta, o.updated_at`
+
+func scanOrder(scan func(...any) error) (*model.OrderResp, error) {
+	var o model.OrderResp
+	var createdAt, updatedAt time.Time
+	var eta sql.NullTime
+	err := scan(
+		&o.Id,
+		&o.Total,
+		&o.Discount,
+		&o.CustomerId,
+		&o.CouponCode,
+		&createdAt,
+		&o.Status,
+		&eta,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.CreatedAt = &createdAt
+	o.UpdatedAt = &updatedAt
+	if eta.Valid {
+		o.Eta = &eta.Time
+	}
+	return &o, nil
+}
+
+const productColumns = `id, name, price, category, COALESCE(image_thumbnail, ''), COALESCE(image_mobile, ''),
+	COALESCE(image_tablet, ''), COALESCE(image_desktop, ''), updated_at`
+
+func scanProduct(scan func(...any) error) (*model.Product, error) {
+	var p model.Product
+	var id int
+	err := scan(
+		&id,
+		&p.Name,
+		&p.Price,
+		&p.Category,
+		&p.Image.Thumbnail,
+		&p.Image.Mobile,
+		&p.Image.Tablet,
+		&p.Image.Desktop,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Id = fmt.Sprintf("%d", id)
+	return &p, nil
+}
+
+// Satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// "?, ?, ?" for n arguments of an IN list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Details of each product ordered, in the order of the items, for all the
+// orders in one query. Products which became unavailable since are included.
+func loadOrderProducts(ctx context.Context, q querier, orders []model.OrderResp) error {
+	ids := []any{}
+	seen := map[string]bool{}
+	for _, o := range orders {
+		for _, item := range o.OrderedProduct {
+			if !seen[item.ProductId] {
+				seen[item.ProductId] = true
+				ids = append(ids, item.ProductId)
+			}
+		}
+	}
+
+	products := map[string]model.Product{}
+	if len(ids) > 0 {
+		rows, err := q.QueryContext(ctx, `SELECT `+productColumns+` FROM products WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			p, err := scanProduct(rows.Scan)
+			if err != nil {
+				return err
+			}
+			products[p.Id] = *p
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range orders {
+		o := &orders[i]
+		o.Products = []model.Product{}
+		clear(seen)
+		for _, item := range o.OrderedProduct {
+			if seen[item.ProductId] {
+				continue
+			}
+			seen[item.ProductId] = true
+			p, ok := products[item.ProductId]
+			if !ok {
+				return fmt.Errorf("product %s of order %s not found", item.ProductId, o.Id)
+			}
+			o.Products = append(o.Products, p)
+		}
+	}
+	return nil
+}
+
+// Items of all the orders in one query
+func (k *kartRepository) loadOrderItems(ctx context.Context, orders []model.OrderResp) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]any, len(orders))
+	index := make(map[string]int, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].Id
+		index[orders[i].Id] = i
+		orders[i].OrderedProduct = []model.OrderedProduct{}
+	}
+
+	rows, err := k.dbClient.QueryContext(ctx, `SELECT order_id, product_id, quantity FROM order_items
+		WHERE order_id IN (`+placeholders(len(ids))+`) ORDER BY id`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderId string
+		var item model.OrderedProduct
+		var productId int64
+		if err := rows.Scan(&orderId, &productId, &item.Quantity); err != nil {
+			return err
+		}
+		item.ProductId = fmt.Sprintf("%d", productId)
+		o := &orders[index[orderId]]
+		o.OrderedProduct = append(o.OrderedProduct, item)
+	}
+	return rows.Err()
+}
+
+func (k *kartRepository) GetOrder(ctx context.Context, orderId string) (*model.OrderResp, error) {
+	defer observe("get_order")()
+	row := k.dbClient.QueryRowContext(ctx, `SELECT `+orderColumns+`
+	FROM orders o LEFT JOIN coupons c ON o.coupon_id = c.id WHERE o.id = ?`, orderId)
+	o, err := scanOrder(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fmt.Println("Order not found:", orderId)
+			return nil, myerror.KartError{Code: 404, Msg: "Order not found"}
+		}
+		fmt.Println("Failed quering orders table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+
+	orders := []model.OrderResp{*o}
+	if err = k.loadOrderDetails(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return &orders[0], nil
+}
+
+// Items and products of the orders, two queries however many orders there are
+func (k *kartRepository) loadOrderDetails(ctx context.Context, orders []model.OrderResp) error {
+	if err := k.loadOrderItems(ctx, orders); err != nil {
+		fmt.Println("Failed quering order items. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	if err := loadOrderProducts(ctx, k.dbClient, orders); err != nil {
+		fmt.Println("Failed quering ordered products. Error:", err)
+		return myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	return nil
+}
+
+// List orders newest first, optionally only those of one customer
+func (k *kartRepository) ListOrders(ctx context.Context, filter model.OrderFilter) ([]model.OrderResp, error) {
+	defer observe("list_orders")()
+	cmd := `SELECT ` + orderColumns + ` FROM orders o LEFT JOIN coupons c ON o.coupon_id = c.id`
+	args := []any{}
+	if filter.CustomerId != "" {
+		cmd += ` WHERE o.customer_id = ?`
+		args = append(args, filter.CustomerId)
+	}
+	cmd += ` ORDER BY o.created_at DESC, o.rowid DESC LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := k.dbClient.QueryContext(ctx, cmd, args...)
+	if err != nil {
+		fmt.Println("Failed quering orders table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+
+	orders := []model.OrderResp{}
+	for rows.Next() {
+		o, err := scanOrder(rows.Scan)
+		if err != nil {
+			rows.Close()
+			fmt.Println("Failed scanning rows. Error:", err)
+			return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+		}
+		orders = append(orders, *o)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		fmt.Println("Failed scanning rows. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
+	}
+
+	// items are loaded once the list query is closed, SQLite may only have
+	// a single connection
+	if err = k.loadOrderDetails(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Statuses an order may move to from each status. Completed and cancelled
+// orders don't change any more.
+var orderTransitions = map[string][]string{
+	model.OrderPlaced:    {model.OrderPreparing, model.OrderCancelled},
+	model.OrderPreparing: {model.OrderReady, model.OrderCancelled},
+	model.OrderReady:     {model.OrderCompleted},
+}
+
+// Only orders still being worked on have an ETA
+func hasEta(status string) bool {
+	return status == model.OrderPlaced || status == model.OrderPreparing
+}
+
+// Lifecycle state of an order recorded in audit events
+type orderStatusSnapshot struct {
+	Status string     `json:"status"`
+	Eta    *time.Time `json:"eta,omitempty"`
+}
+
+// Move an order along its lifecycle, or only change its ETA when the update
+// has no status, and audit it. Moves the lifecycle doesn't allow are
+// conflicts.
+func (k *kartRepository) UpdateOrderStatus(ctx context.Context, orderId string, update model.OrderStatusUpdate) (*model.OrderResp, error) {
+	defer observe("update_order_status")()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Println("Failed to begin transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
+	}
+	defer rollback(tx, "update_order_status")
+
+	var before orderStatusSnapshot
+	var eta sql.NullTime
+	err = tx.QueryRowContext(ctx, `SELECT status, eta FROM orders WHERE id = ?`, orderId).Scan(&before.Status, &eta)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fmt.Println("Order not found:", orderId)
+			return nil, myerror.KartError{Code: 404, Msg: "Order not found"}
+		}
+		fmt.Println("Failed quering orders table. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	if eta.Valid {
+		before.Eta = &eta.Time
+	}
+
+	after := before
+	switch {
+	case update.Status == "":
+		if !hasEta(before.Status) {
+			return nil, myerror.KartError{Code: 409, Msg: fmt.Sprintf("Order is %s, its ETA can't change", before.Status)}
+		}
+	case !slices.Contains(orderTransitions[before.Status], update.Status):
+		return nil, myerror.KartError{Code: 409, Msg: fmt.Sprintf("Order is %s, it can't become %s", before.Status, update.Status)}
+	default:
+		after.Status = update.Status
+	}
+	if update.Eta != nil {
+		e := update.Eta.UTC()
+		after.Eta = &e
+	}
+	if !hasEta(after.Status) {
+		after.Eta = nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = ?, eta = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		after.Status, after.Eta, orderId)
+	if err != nil {
+		fmt.Println("Failed updating order status. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed updating DB"}
+	}
+	if err = recordEvent(ctx, tx, audit.OrderUpdateStatus, audit.EntityOrder, orderId, before, after); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	}
+
+	fmt.Printf("Order %s is %s\n", orderId, after.Status)
+	return k.GetOrder(ctx, orderId)
+}
@@ -1,12 +1,18 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
 	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
 )
@@ -14,11 +20,31 @@ import (
 var mu = &sync.Mutex{}
 var repo *kartRepository
 
+// SQLite file opened by InitialiseDatabase, relative to internal/cmd where
+// the server runs. Tests point it elsewhere before the first InitialiseDatabase.
+var DatabasePath = "../repo/mydb.db"
+
 type KartRepository interface {
-	ListAvailableProducts() ([]model.Product, error)
-	GetProductById(int64) (*model.Product, error)
-	PlaceOrder(model.OrderDetail) (*model.OrderResp, error)
-	PopulateCoupons(string)
+	ListAvailableProducts(context.Context) ([]model.Product, error)
+	GetProductById(context.Context, int64) (*model.Product, error)
+	ProductsLastModified(context.Context) (time.Time, error)
+	PlaceOrder(context.Context, model.OrderDetail) (*model.OrderResp, error)
+	PopulateCoupons(context.Context, string, coupon.DiscountPolicy) error
+	OverrideCouponDiscount(context.Context, string, float64) (*model.Coupon, error)
+	UpdateCouponRules(context.Context, string, model.CouponRules) (*model.CouponRules, error)
+	GetCouponStatus(context.Context, string) (*model.CouponStatus, error)
+	CreateCoupon(context.Context, model.Coupon, model.CouponRules) (*model.CouponInfo, error)
+	ImportCoupons(context.Context, []model.Coupon) (int, error)
+	ListCoupons(context.Context, model.CouponFilter) ([]model.CouponInfo, error)
+	SetCouponDisabled(context.Context, string, bool) error
+	CreateCustomer(context.Context, string, model.CustomerReq) (*model.Customer, error)
+	GetCustomerBySubject(context.Context, string) (*model.Customer, error)
+	UpdateCustomer(context.Context, string, model.CustomerReq) (*model.Customer, error)
+	GetOrder(context.Context, string) (*model.OrderResp, error)
+	UpdateOrderStatus(context.Context, string, model.OrderStatusUpdate) (*model.OrderResp, error)
+	ListOrders(context.Context, model.OrderFilter) ([]model.OrderResp, error)
+	ListAuditEvents(context.Context, model.AuditFilter) ([]model.AuditEvent, error)
+	ExportAuditEvents(context.Context, model.AuditFilter, func(model.AuditEvent) error) error
 }
 
 type kartRepository struct {
@@ -26,7 +52,9 @@ type kartRepository struct {
 }
 
 func getDatabase() *sql.DB {
-	db, err := sql.Open("sqlite3", "../repo/mydb.db")
+	// immediate transactions take the write lock up front, so checks done
+	// inside an order transaction can't race with another order
+	db, err := otelsql.Open("sqlite3", DatabasePath+"?_txlock=immediate&_busy_timeout=5000", traceOptions...)
 	if err != nil || db == nil {
 		fmt.Println("Error while opening db driver for sql-lite. Error: ", err)
 		panic(err)
@@ -58,12 +86,26 @@ func InitialiseDatabase() KartRepository {
 	return repo
 }
 
+// Close the database handle. Repositories handed out before fail with
+// errors from then on, the next InitialiseDatabase opens a new handle.
+func CloseDatabase() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if repo == nil || repo.dbClient == nil {
+		return nil
+	}
+
+	err := repo.dbClient.Close()
+	repo = nil
+	return err
+}
+
 // Get list of available products
-func (k *kartRepository) ListAvailableProducts() ([]model.Product, error) {
-	cmd := `SELECT id, name, price, category, image_thumbnail, image_mobile, image_tablet, image_desktop
-	FROM products WHERE is_available=1`
+func (k *kartRepository) ListAvailableProducts(ctx context.Context) ([]model.Product, error) {
+	defer observe("list_products")()
+	cmd := `SELECT ` + productColumns + ` FROM products WHERE is_available=1`
 
-	rows, err := k.dbClient.Query(cmd)
+	rows, err := k.dbClient.QueryContext(ctx, cmd)
 	if err != nil {
 		fmt.Println("Failed quering products table for available products. Error:", err)
 		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
@@ -72,34 +114,12 @@ func (k *kartRepository) ListAvailableProducts() ([]model.Product, error) {
 
 	var products []model.Product
 	for rows.Next() {
-		var p model.Product
-		var thumb, mobile, tablet, desktop string
-		var id int
-		err := rows.Scan(
-			&id,
-			&p.Name,
-			&p.Price,
-			&p.Category,
-			&thumb,
-			&mobile,
-			&tablet,
-			&desktop,
-		)
+		p, err := scanProduct(rows.Scan)
 		if err != nil {
 			fmt.Println("Failed scanning rows. Error:", err)
 			return nil, myerror.KartError{Code: 500, Msg: "Failed scanning rows in DB"}
 		}
-
-		// Image details
-		p.Image = model.Image{
-			Thumbnail: thumb,
-			Mobile:    mobile,
-			Tablet:    tablet,
-			Desktop:   desktop,
-		}
-
-		p.Id = fmt.Sprintf("%d", id)
-		products = append(products, p)
+		products = append(products, *p)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -111,23 +131,11 @@ func (k *kartRepository) ListAvailableProducts() ([]model.Product, error) {
 }
 
 // Get single product by ID
-func (k *kartRepository) GetProductById(productId int64) (*model.Product, error) {
-	cmd := `SELECT id, name, price, category, image_thumbnail, image_mobile, image_tablet, image_desktop
-	FROM products WHERE id = ? AND is_available=1`
-
-	var p model.Product
-	var thumb, mobile, tablet, desktop string
-	var id int
-	err := k.dbClient.QueryRow(cmd, productId).Scan(
-		&id,
-		&p.Name,
-		&p.Price,
-		&p.Category,
-		&thumb,
-		&mobile,
-		&tablet,
-		&desktop,
-	)
+func (k *kartRepository) GetProductById(ctx context.Context, productId int64) (*model.Product, error) {
+	defer observe("get_product")()
+	cmd := `SELECT ` + productColumns + ` FROM products WHERE id = ? AND is_available=1`
+
+	p, err := scanProduct(k.dbClient.QueryRowContext(ctx, cmd, productId).Scan)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			fmt.Println("Product not found or not available: ID", productId)
@@ -137,56 +145,88 @@ func (k *kartRepository) GetProductById(productId int64) (*model.Product, error)
 		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
 	}
 
-	// Image details
-	p.Image = model.Image{
-		Thumbnail: thumb,
-		Mobile:    mobile,
-		Tablet:    tablet,
-		Desktop:   desktop,
-	}
+	return p, nil
+}
 
-	p.Id = fmt.Sprintf("%d", id)
-	return &p, nil
+// Latest updated_at of any product, available or not, so that a product
+// being withdrawn counts as a change. Zero without products.
+func (k *kartRepository) ProductsLastModified(ctx context.Context) (time.Time, error) {
+	defer observe("products_last_modified")()
+	var updatedAt time.Time
+	err := k.dbClient.QueryRowContext(ctx, `SELECT updated_at FROM products ORDER BY updated_at DESC LIMIT 1`).Scan(&updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		fmt.Println("Failed querying last product change. Error:", err)
+		return time.Time{}, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	return updatedAt, nil
 }
 
-func (k *kartRepository) validateCode(promo string) (float64, error) {
-	var discount float64 = 0
-	err := k.dbClient.QueryRow("SELECT discount FROM coupons WHERE promo_code = ?", promo).Scan(&discount)
+// Check that a coupon exists and is currently usable, returning its discount
+func (k *kartRepository) validateCode(ctx context.Context, promo string) (float64, error) {
+	c, err := loadCoupon(ctx, k.dbClient, promo)
+	observeCoupon("lookup", err)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Println("Invalid coupon code is provided")
-			return 0.0, myerror.KartError{Code: 400, Msg: "Invalid coupon code is provided"}
-		}
-		fmt.Println("Failed to check promo code in coupon table. Error:", err)
-		return 0.0, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+		return 0.0, err
+	}
+
+	if err = c.checkUsable(time.Now()); err != nil {
+		return 0.0, err
 	}
 
-	return discount, nil
+	return c.discount, nil
 }
 
-// Place order
-func (k *kartRepository) PlaceOrder(oDetail model.OrderDetail) (order *model.OrderResp, err error) {
-	var discountPercent float64 = 0
-	if oDetail.CouponCode != "" {
-		discountPercent, err = k.validateCode(oDetail.CouponCode)
+// Pin the discount of a coupon so that re-populating coupons keeps it
+func (k *kartRepository) OverrideCouponDiscount(ctx context.Context, promo string, discount float64) (*model.Coupon, error) {
+	defer observe("override_coupon_discount")()
+	err := k.updateCoupon(ctx, promo, audit.CouponUpdateDiscount, func(tx *sql.Tx, c *couponRecord) error {
+		_, err := tx.ExecContext(ctx, `UPDATE coupons SET discount = ?, discount_locked = 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`, discount, c.id)
 		if err != nil {
-			fmt.Println("failed validating coupon")
-			return nil, err
+			fmt.Println("Failed updating coupon discount. Error:", err)
+			return err
 		}
+		c.discount = discount
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return &model.Coupon{Code: promo, Discount: discount}, nil
+}
+
+// Place order
+func (k *kartRepository) PlaceOrder(ctx context.Context, oDetail model.OrderDetail) (order *model.OrderResp, err error) {
+	defer observe("place_order")()
 	// begin the transaction
-	tx, err := k.dbClient.Begin()
+	tx, err := k.dbClient.BeginTx(ctx, nil)
 	if err != nil {
 		fmt.Println("Failed to begin transaction. Error:", err)
 		return nil, myerror.KartError{Code: 500, Msg: "Failed to begin transaction"}
 	}
-	defer tx.Rollback()
+	defer rollback(tx, "place_order")
+
+	// Coupon is checked inside the transaction so its usage caps hold
+	var cpn *couponRecord
+	var discountPercent float64 = 0
+	if oDetail.CouponCode != "" {
+		cpn, err = loadCoupon(ctx, tx, oDetail.CouponCode)
+		observeCoupon("order", err)
+		if err == nil {
+			err = cpn.checkUsable(time.Now())
+		}
+		if err != nil {
+			fmt.Println("failed validating coupon")
+			return nil, err
+		}
+		discountPercent = cpn.discount
+	}
 
 	orderID := uuid.New().String()
 
 	// Prepare statement for order items
-	stmt, err := tx.Prepare(`INSERT INTO order_items (order_id, product_id, quantity) VALUES (?, ?, ?)`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity) VALUES (?, ?, ?)`)
 	if err != nil {
 		fmt.Println("failed to prepare statement to be executed")
 		return nil, myerror.KartError{Code: 500, Msg: "Failed to prepare statement"}
@@ -197,7 +237,7 @@ func (k *kartRepository) PlaceOrder(oDetail model.OrderDetail) (order *model.Ord
 	for _, item := range oDetail.OrderedProduct {
 		// Validate product exists and is available
 		var exists int
-		err = tx.QueryRow("SELECT COUNT(*) FROM products WHERE id = ? AND is_available = 1", item.ProductId).Scan(&exists)
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE id = ? AND is_available = 1", item.ProductId).Scan(&exists)
 		if err != nil {
 			fmt.Println("Failed to validate product. Error:", err)
 			return nil, myerror.KartError{Code: 500, Msg: "Failed to validate product"}
@@ -208,7 +248,7 @@ func (k *kartRepository) PlaceOrder(oDetail model.OrderDetail) (order *model.Ord
 		}
 
 		// Insert order item
-		_, err = stmt.Exec(orderID, item.ProductId, item.Quantity)
+		_, err = stmt.ExecContext(ctx, orderID, item.ProductId, item.Quantity)
 		if err != nil {
 			fmt.Println("Failed to execute transaction. Error", err)
 			return nil, myerror.KartError{Code: 500, Msg: "Failed to execute transaction"}
@@ -217,9 +257,14 @@ func (k *kartRepository) PlaceOrder(oDetail model.OrderDetail) (order *model.Ord
 		fmt.Printf("Added item: Product %s, Quantity %d\n", item.ProductId, item.Quantity)
 	}
 
-	total, err := calculateOrderTotal(tx, oDetail.OrderedProduct)
+	total, err := calculateOrderTotal(ctx, tx, oDetail.OrderedProduct)
 	if err != nil {
-		return nil, myerror.ErrInternalServer
+		return nil, err
+	}
+
+	if cpn != nil && total < cpn.minOrderValue {
+		fmt.Printf("Order total %.2f below coupon minimum %.2f\n", total, cpn.minOrderValue)
+		return nil, myerror.KartError{Code: 422, Msg: fmt.Sprintf("Coupon code requires a minimum order value of %.2f", cpn.minOrderValue)}
 	}
 	discount := total * (discountPercent / 100.0)
 	finalTotal := total - discount
@@ -228,37 +273,55 @@ func (k *kartRepository) PlaceOrder(oDetail model.OrderDetail) (order *model.Ord
 	finalTotal = float64(int(finalTotal*100)) / 100
 
 	// Insert main order
-	_, err = tx.Exec(`INSERT INTO orders (id, total, discounts, coupon_id) VALUES (?, ?, ?, (SELECT id FROM coupons WHERE promo_code = ?))`,
-		orderID, finalTotal, discount, oDetail.CouponCode)
+	_, err = tx.ExecContext(ctx, `INSERT INTO orders (id, total, discounts, coupon_id, customer_id)
+		VALUES (?, ?, ?, (SELECT id FROM coupons WHERE promo_code = ?), NULLIF(?, ''))`,
+		orderID, finalTotal, discount, oDetail.CouponCode, oDetail.CustomerId)
 	if err != nil {
 		fmt.Println("Failed inserting order detail. Error:", err)
 		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
 	}
 
-	// Commit transaction - all or nothing
-	if err = tx.Commit(); err != nil {
-		fmt.Println("Failed to commit transaction. Error:", err)
-		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
+	if cpn != nil {
+		if err = redeemCoupon(ctx, tx, cpn, oDetail.CallerId, orderID); err != nil {
+			return nil, err
+		}
 	}
 
-	// Return created order
 	order = &model.OrderResp{
 		Id:             orderID,
 		Total:          finalTotal,
 		Discount:       discount,
 		OrderedProduct: oDetail.OrderedProduct,
+		CustomerId:     oDetail.CustomerId,
+		CouponCode:     oDetail.CouponCode,
+		Status:         model.OrderPlaced,
+	}
+	orders := []model.OrderResp{*order}
+	if err = loadOrderProducts(ctx, tx, orders); err != nil {
+		fmt.Println("Failed quering ordered products. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed quering DB"}
+	}
+	order = &orders[0]
+	if err = recordEvent(ctx, tx, audit.OrderCreate, audit.EntityOrder, orderID, nil, order); err != nil {
+		return nil, myerror.KartError{Code: 500, Msg: "Failed inserting into DB"}
+	}
+
+	// Commit transaction - all or nothing
+	if err = tx.Commit(); err != nil {
+		fmt.Println("Failed to commit transaction. Error:", err)
+		return nil, myerror.KartError{Code: 500, Msg: "Failed to commit transaction"}
 	}
 
 	fmt.Printf("Order created successfully: %s (Total: %.2f)\n", orderID, finalTotal)
 	return order, nil
 }
 
-func calculateOrderTotal(tx *sql.Tx, items []model.OrderedProduct) (float64, error) {
+func calculateOrderTotal(ctx context.Context, tx *sql.Tx, items []model.OrderedProduct) (float64, error) {
 	var total float64 = 0.0
 
 	for _, item := range items {
 		var price float64
-		err := tx.QueryRow("SELECT price FROM products WHERE id = ?", item.ProductId).Scan(&price)
+		err := tx.QueryRowContext(ctx, "SELECT price FROM products WHERE id = ?", item.ProductId).Scan(&price)
 		if err != nil {
 			fmt.Println("failed getting price for product", item.ProductId, "Error: ", err)
 			return 0, myerror.KartError{Code: 500, Msg: "Failed to get price of product"}
@@ -268,3 +331,9 @@ func calculateOrderTotal(tx *sql.Tx, items []model.OrderedProduct) (float64, err
 
 	return total, nil
 }
+
+// Whether err is an insert or update clashing with a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
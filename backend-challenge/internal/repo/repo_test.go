@@ -1,13 +1,25 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
+var ctx = context.Background()
+
 func setupTestDB() *sql.DB {
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
@@ -29,7 +41,7 @@ func TestListAvailableProducts(t *testing.T) {
 	db.Exec(`INSERT INTO products (name, price, category, image_thumbnail, image_mobile, image_tablet, image_desktop, is_available) 
 		VALUES ('Test Product', 100.0, 'Test', 'thumb.jpg', 'mobile.jpg', 'tablet.jpg', 'desktop.jpg', 1)`)
 
-	products, err := repo.ListAvailableProducts()
+	products, err := repo.ListAvailableProducts(ctx)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -39,7 +51,7 @@ func TestListAvailableProducts(t *testing.T) {
 
 	// Test with unavailable products
 	db.Exec(`UPDATE products SET is_available = 0`)
-	products, err = repo.ListAvailableProducts()
+	products, err = repo.ListAvailableProducts(ctx)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -48,6 +60,34 @@ func TestListAvailableProducts(t *testing.T) {
 	}
 }
 
+// Any change to a product moves the catalog's Last-Modified, withdrawing
+// one included
+func TestProductsLastModified(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	long := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	db.Exec(`UPDATE products SET updated_at = ?`, long)
+	last, err := repo.ProductsLastModified(ctx)
+	if err != nil || !last.Equal(long) {
+		t.Fatalf("Expected %v, got %v (%v)", long, last, err)
+	}
+
+	db.Exec(`UPDATE products SET is_available = 0 WHERE id = (SELECT MIN(id) FROM products)`)
+	last, err = repo.ProductsLastModified(ctx)
+	if err != nil || time.Since(last) > time.Minute {
+		t.Errorf("Expected the withdrawn product to count as a change, got %v (%v)", last, err)
+	}
+
+	products, err := repo.ListAvailableProducts(ctx)
+	if err != nil || len(products) == 0 || !products[0].UpdatedAt.Equal(long) {
+		t.Errorf("Expected products to carry updated_at, got %+v (%v)", products, err)
+	}
+}
+
 func TestGetProductById_Success(t *testing.T) {
 	// Test success
 	db := setupTestDB()
@@ -60,7 +100,7 @@ func TestGetProductById_Success(t *testing.T) {
 	db.Exec(`INSERT INTO products (id, name, price, category, image_thumbnail, image_mobile, image_tablet, image_desktop, is_available) 
 		VALUES (1, 'Test Product', 100.0, 'Test', 'thumb.jpg', 'mobile.jpg', 'tablet.jpg', 'desktop.jpg', 1)`)
 
-	product, err := repo.GetProductById(1)
+	product, err := repo.GetProductById(ctx, 1)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -69,7 +109,7 @@ func TestGetProductById_Success(t *testing.T) {
 	}
 
 	// Test product not found
-	_, err = repo.GetProductById(999)
+	_, err = repo.GetProductById(ctx, 999)
 	if err == nil {
 		t.Error("Expected error for non-existent product, got nil")
 	}
@@ -87,7 +127,7 @@ func TestGetProductById_Failure(t *testing.T) {
 	db.Exec(`INSERT INTO products (id, name, price, category, image_thumbnail, image_mobile, image_tablet, image_desktop, is_available) 
 		VALUES (1, 'Test Product', 100.0, 'Test', 'thumb.jpg', 'mobile.jpg', 'tablet.jpg', 'desktop.jpg', 1)`)
 
-	_, err := repo.GetProductById(999)
+	_, err := repo.GetProductById(ctx, 999)
 	if err == nil {
 		t.Error("Expected error for non-existent product, got nil")
 	}
@@ -104,7 +144,7 @@ func TestValidateCode_Success(t *testing.T) {
 
 	db.Exec(`INSERT INTO coupons (promo_code, discount) VALUES ('SAVE10', 10.0)`)
 
-	discount, err := repo.validateCode("SAVE10")
+	discount, err := repo.validateCode(ctx, "SAVE10")
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -113,7 +153,7 @@ func TestValidateCode_Success(t *testing.T) {
 	}
 
 	// Test invalid coupon
-	_, err = repo.validateCode("INVALID")
+	_, err = repo.validateCode(ctx, "INVALID")
 	if err == nil {
 		t.Error("Expected error for invalid coupon, got nil")
 	}
@@ -131,7 +171,7 @@ func TestValidateCode_Failure(t *testing.T) {
 	db.Exec(`INSERT INTO coupons (promo_code, discount) VALUES ('SAVE10', 10.0)`)
 
 	// Test invalid coupon
-	_, err := repo.validateCode("INVALID")
+	_, err := repo.validateCode(ctx, "INVALID")
 	if err == nil {
 		t.Error("Expected error for invalid coupon, got nil")
 	}
@@ -157,7 +197,7 @@ func TestKartRepository_PlaceOrder(t *testing.T) {
 		},
 	}
 
-	order, err := repo.PlaceOrder(orderDetail)
+	order, err := repo.PlaceOrder(ctx, orderDetail)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -167,8 +207,568 @@ func TestKartRepository_PlaceOrder(t *testing.T) {
 
 	// Test invalid product
 	orderDetail.OrderedProduct[0].ProductId = "999"
-	_, err = repo.PlaceOrder(orderDetail)
+	_, err = repo.PlaceOrder(ctx, orderDetail)
 	if err == nil {
 		t.Error("Expected error for invalid product, got nil")
 	}
 }
+
+func TestUpdateOrderStatus(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Test Product', 100.0, 'Test', 1)`)
+
+	order, err := repo.PlaceOrder(ctx, model.OrderDetail{OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order, _ = repo.GetOrder(ctx, order.Id); order.Status != model.OrderPlaced || order.Eta != nil {
+		t.Errorf("Expected a placed order without ETA, got %s %v", order.Status, order.Eta)
+	}
+
+	eta := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := eta.Add(5 * time.Minute)
+	tests := []struct {
+		update model.OrderStatusUpdate
+		status string
+		eta    *time.Time
+		code   int
+	}{
+		{model.OrderStatusUpdate{Status: model.OrderReady}, model.OrderPlaced, nil, 409},
+		{model.OrderStatusUpdate{Status: model.OrderPreparing, Eta: &eta}, model.OrderPreparing, &eta, 0},
+		{model.OrderStatusUpdate{Eta: &later}, model.OrderPreparing, &later, 0},
+		{model.OrderStatusUpdate{Status: model.OrderPreparing}, model.OrderPreparing, &later, 409},
+		// ready orders have no ETA any more
+		{model.OrderStatusUpdate{Status: model.OrderReady}, model.OrderReady, nil, 0},
+		{model.OrderStatusUpdate{Eta: &eta}, model.OrderReady, nil, 409},
+		{model.OrderStatusUpdate{Status: model.OrderCancelled}, model.OrderReady, nil, 409},
+		{model.OrderStatusUpdate{Status: model.OrderCompleted}, model.OrderCompleted, nil, 0},
+	}
+	for _, tt := range tests {
+		got, err := repo.UpdateOrderStatus(ctx, order.Id, tt.update)
+		if tt.code != 0 {
+			if kErr, ok := err.(myerror.KartError); !ok || kErr.Code != tt.code {
+				t.Errorf("%+v: expected %d, got %v", tt.update, tt.code, err)
+			}
+			got, _ = repo.GetOrder(ctx, order.Id)
+		} else if err != nil {
+			t.Errorf("%+v: expected no error, got %v", tt.update, err)
+			continue
+		}
+		if got.Status != tt.status || (got.Eta == nil) != (tt.eta == nil) || (tt.eta != nil && !got.Eta.Equal(*tt.eta)) {
+			t.Errorf("%+v: expected %s %v, got %s %v", tt.update, tt.status, tt.eta, got.Status, got.Eta)
+		}
+	}
+
+	if _, err := repo.UpdateOrderStatus(ctx, "unknown", model.OrderStatusUpdate{Status: model.OrderReady}); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	events, _ := repo.ListAuditEvents(ctx, model.AuditFilter{Action: audit.OrderUpdateStatus, EntityId: order.Id, Limit: 10})
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 audited updates, got %d", len(events))
+	}
+	if !strings.Contains(string(events[0].Before), `"status":"ready"`) || !strings.Contains(string(events[0].After), `"status":"completed"`) {
+		t.Errorf("Expected before/after snapshots, got %s -> %s", events[0].Before, events[0].After)
+	}
+}
+
+func TestOverrideCouponDiscount(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	db.Exec(`INSERT INTO coupons (promo_code, discount) VALUES ('SAVE10', 10.0)`)
+
+	cpn, err := repo.OverrideCouponDiscount(ctx, "SAVE10", 25)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if cpn.Discount != 25 {
+		t.Errorf("Expected discount 25, got %f", cpn.Discount)
+	}
+
+	// Test unknown coupon
+	_, err = repo.OverrideCouponDiscount(ctx, "INVALID", 25)
+	if err == nil {
+		t.Error("Expected error for unknown coupon, got nil")
+	}
+}
+
+func TestPopulateCoupons_KeepsOverride(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	f, err := os.CreateTemp(t.TempDir(), "codes-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("SAVE10AB\nSAVE20AB\n")
+	f.Close()
+
+	policy, _ := coupon.NewDiscountPolicy(coupon.PolicyConfig{Codes: map[string]float64{"SAVE10AB": 10, "SAVE20AB": 20}})
+	name := f.Name()
+	repo.PopulateCoupons(ctx, name, policy)
+	repo.OverrideCouponDiscount(ctx, "SAVE20AB", 35)
+
+	// Re-populating with a different policy must keep the override
+	policy, _ = coupon.NewDiscountPolicy(coupon.PolicyConfig{Codes: map[string]float64{"SAVE10AB": 15, "SAVE20AB": 40}})
+	repo.PopulateCoupons(ctx, name, policy)
+
+	if discount, _ := repo.validateCode(ctx, "SAVE10AB"); discount != 15 {
+		t.Errorf("Expected discount 15, got %f", discount)
+	}
+	if discount, _ := repo.validateCode(ctx, "SAVE20AB"); discount != 35 {
+		t.Errorf("Expected overridden discount 35, got %f", discount)
+	}
+}
+
+func TestPlaceOrder_CouponLifecycle(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Test Product', 100.0, 'Test', 1)`)
+	db.Exec(`INSERT INTO coupons (promo_code, discount) VALUES ('SAVE10', 10.0)`)
+
+	orderDetail := model.OrderDetail{
+		CouponCode:     "SAVE10",
+		CallerId:       "customer-1",
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}},
+	}
+	expectCode := func(name string, code int) {
+		t.Helper()
+		_, err := repo.PlaceOrder(ctx, orderDetail)
+		if kErr, ok := err.(myerror.KartError); !ok || kErr.Code != code {
+			t.Errorf("%s: expected error code %d, got %v", name, code, err)
+		}
+	}
+
+	// Test not yet active and expired
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{ValidFrom: &future})
+	expectCode("not yet active", 422)
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{ValidTo: &past})
+	expectCode("expired", 422)
+
+	// Test minimum order value
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{MinOrderValue: 150})
+	expectCode("below minimum", 422)
+
+	// Test per-customer cap
+	one := int64(1)
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{MaxUsesPerCustomer: &one})
+	if _, err := repo.PlaceOrder(ctx, orderDetail); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	expectCode("customer cap", 422)
+
+	// Another customer can still redeem until the global cap is hit
+	two := int64(2)
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{MaxUses: &two, MaxUsesPerCustomer: &one})
+	orderDetail.CallerId = "customer-2"
+	if _, err := repo.PlaceOrder(ctx, orderDetail); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	orderDetail.CallerId = "customer-3"
+	expectCode("global cap", 422)
+
+	// Failed orders must not consume the coupon
+	var used int
+	db.QueryRow(`SELECT times_used FROM coupons WHERE promo_code = 'SAVE10'`).Scan(&used)
+	if used != 2 {
+		t.Errorf("Expected coupon used 2 times, got %d", used)
+	}
+}
+
+func TestGetCouponStatus(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	db.Exec(`INSERT INTO coupons (promo_code, discount, min_order_value) VALUES ('SAVE10', 10.0, 50)`)
+
+	status, err := repo.GetCouponStatus(ctx, "SAVE10")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !status.Valid || status.Discount != 10 || status.Rules.MinOrderValue != 50 {
+		t.Errorf("Unexpected status for valid coupon: %+v", status)
+	}
+
+	// Test expired coupon reports its reason but no discount
+	past := time.Now().Add(-time.Hour)
+	repo.UpdateCouponRules(ctx, "SAVE10", model.CouponRules{ValidTo: &past})
+	status, _ = repo.GetCouponStatus(ctx, "SAVE10")
+	if status.Valid || status.Discount != 0 || status.Reason == "" {
+		t.Errorf("Unexpected status for expired coupon: %+v", status)
+	}
+
+	// Test unknown coupon reveals nothing but the reason
+	status, _ = repo.GetCouponStatus(ctx, "INVALID")
+	if status.Valid || status.Rules != nil {
+		t.Errorf("Unexpected status for unknown coupon: %+v", status)
+	}
+}
+
+func TestCouponAdmin(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Test Product', 100.0, 'Test', 1)`)
+
+	if _, err := repo.CreateCoupon(ctx, model.Coupon{Code: "SAVE10", Discount: 10}, model.CouponRules{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, err := repo.CreateCoupon(ctx, model.Coupon{Code: "SAVE10", Discount: 10}, model.CouponRules{})
+	if kErr, ok := err.(myerror.KartError); !ok || kErr.Code != 409 {
+		t.Errorf("Expected 409 for duplicate coupon, got %v", err)
+	}
+
+	inserted, err := repo.ImportCoupons(ctx, []model.Coupon{{Code: "SAVE10", Discount: 50}, {Code: "SAVE_20", Discount: 20}})
+	if err != nil || inserted != 1 {
+		t.Errorf("Expected 1 inserted coupon, got %d (%v)", inserted, err)
+	}
+
+	repo.PlaceOrder(ctx, model.OrderDetail{
+		CouponCode:     "SAVE10",
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}},
+	})
+
+	// Search treats '_' literally
+	coupons, err := repo.ListCoupons(ctx, model.CouponFilter{Search: "_", Limit: 10})
+	if err != nil || len(coupons) != 1 || coupons[0].Code != "SAVE_20" {
+		t.Errorf("Expected only SAVE_20, got %+v (%v)", coupons, err)
+	}
+
+	coupons, _ = repo.ListCoupons(ctx, model.CouponFilter{Search: "SAVE10", Limit: 10})
+	if len(coupons) != 1 || coupons[0].TimesUsed != 1 || coupons[0].TotalDiscount != 10 {
+		t.Errorf("Expected usage stats for SAVE10, got %+v", coupons)
+	}
+
+	// Disabled coupons can't be used, but orders still reference them
+	if err := repo.SetCouponDisabled(ctx, "SAVE10", true); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, err := repo.validateCode(ctx, "SAVE10"); err == nil {
+		t.Error("Expected error for disabled coupon, got nil")
+	}
+	var orders int
+	db.QueryRow(`SELECT COUNT(*) FROM orders o JOIN coupons c ON o.coupon_id = c.id WHERE c.promo_code = 'SAVE10'`).Scan(&orders)
+	if orders != 1 {
+		t.Errorf("Expected order to keep its coupon, got %d orders", orders)
+	}
+
+	disabled := true
+	coupons, _ = repo.ListCoupons(ctx, model.CouponFilter{Disabled: &disabled, Limit: 10})
+	if len(coupons) != 1 || !coupons[0].Disabled {
+		t.Errorf("Expected only the disabled coupon, got %+v", coupons)
+	}
+}
+
+func TestApiKeys(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	created, err := repo.CreateApiKey(ctx, model.ApiKey{Name: "frontend", Prefix: "kart_1234", Role: "staff", Scopes: []string{"create_order"}}, "hash1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	key, err := repo.GetApiKeyByHash(ctx, "hash1")
+	if err != nil || key.Id != created.Id || key.Role != "staff" || key.Scopes[0] != "create_order" {
+		t.Errorf("Expected stored key, got %+v (%v)", key, err)
+	}
+	if _, err := repo.GetApiKeyByHash(ctx, "unknown"); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+
+	// Rotation expires the old key
+	at := time.Now().Add(time.Hour).UTC()
+	if n, _ := repo.ExpireApiKeys(ctx, "frontend", at); n != 1 {
+		t.Errorf("Expected 1 key expired, got %d", n)
+	}
+	key, _ = repo.GetApiKeyByHash(ctx, "hash1")
+	if key.ExpiresAt == nil || !key.ExpiresAt.Equal(at) {
+		t.Errorf("Expected expiry %v, got %v", at, key.ExpiresAt)
+	}
+
+	if err := repo.RevokeApiKey(ctx, created.Id); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := repo.RevokeApiKey(ctx, created.Id); err == nil {
+		t.Error("Expected error revoking twice, got nil")
+	}
+
+	keys, _ := repo.ListApiKeys(ctx)
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Expected revoked key in list, got %+v", keys)
+	}
+}
+
+func TestCustomerOrders(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Test Product', 100.0, 'Test', 1)`)
+
+	alice, err := repo.CreateCustomer(ctx, "sub-alice", model.CustomerReq{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.CreateCustomer(ctx, "sub-alice", model.CustomerReq{Name: "Alice", Email: "other@example.com"}); !myerror.IsConflict(err) {
+		t.Errorf("Expected conflict for a registered subject, got %v", err)
+	}
+	if _, err := repo.CreateCustomer(ctx, "sub-bob", model.CustomerReq{Name: "Bob", Email: "alice@example.com"}); !myerror.IsConflict(err) {
+		t.Errorf("Expected conflict for a registered email, got %v", err)
+	}
+	if _, err := repo.GetCustomerBySubject(ctx, "sub-bob"); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found for an unregistered subject, got %v", err)
+	}
+
+	updated, err := repo.UpdateCustomer(ctx, "sub-alice", model.CustomerReq{Name: "Alice B", Email: "alice@example.com", Phone: "0400"})
+	if err != nil || updated.Name != "Alice B" || updated.Phone != "0400" || updated.Id != alice.Id {
+		t.Errorf("Expected updated customer, got %v, %v", updated, err)
+	}
+
+	owned, err := repo.PlaceOrder(ctx, model.OrderDetail{
+		CustomerId:     alice.Id,
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.PlaceOrder(ctx, model.OrderDetail{OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	order, err := repo.GetOrder(ctx, owned.Id)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.CustomerId != alice.Id || order.Total != 200 || len(order.OrderedProduct) != 1 || order.CreatedAt == nil {
+		t.Errorf("Unexpected order %+v", order)
+	}
+	if _, err := repo.GetOrder(ctx, "missing"); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	orders, err := repo.ListOrders(ctx, model.OrderFilter{CustomerId: alice.Id, Limit: 10})
+	if err != nil || len(orders) != 1 || orders[0].Id != owned.Id {
+		t.Errorf("Expected alice's order only, got %v, %v", orders, err)
+	}
+	orders, err = repo.ListOrders(ctx, model.OrderFilter{Limit: 10})
+	if err != nil || len(orders) != 2 {
+		t.Errorf("Expected every order, got %v, %v", orders, err)
+	}
+}
+
+// Items and products are loaded for the whole page at once and assigned to
+// their orders
+func TestListOrders_Details(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Waffle', 10.0, 'Test', 1), (2, 'Cake', 5.0, 'Test', 1), (3, 'Pie', 7.0, 'Test', 1)`)
+
+	items := [][]model.OrderedProduct{
+		{{ProductId: "1", Quantity: 1}, {ProductId: "2", Quantity: 2}},
+		{{ProductId: "3", Quantity: 1}},
+		{{ProductId: "2", Quantity: 1}, {ProductId: "1", Quantity: 3}, {ProductId: "2", Quantity: 1}},
+	}
+	for _, ordered := range items {
+		if _, err := repo.PlaceOrder(ctx, model.OrderDetail{OrderedProduct: ordered}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	// the product withdrawn since is still listed with the order
+	db.Exec(`UPDATE products SET is_available = 0 WHERE id = 3`)
+
+	orders, err := repo.ListOrders(ctx, model.OrderFilter{Limit: 10})
+	if err != nil || len(orders) != 3 {
+		t.Fatalf("Expected 3 orders, got %v, %v", orders, err)
+	}
+	// newest first
+	want := []struct {
+		items    int
+		products []string
+	}{
+		{3, []string{"Cake", "Waffle"}},
+		{1, []string{"Pie"}},
+		{2, []string{"Waffle", "Cake"}},
+	}
+	for i, o := range orders {
+		names := []string{}
+		for _, p := range o.Products {
+			names = append(names, p.Name)
+		}
+		if len(o.OrderedProduct) != want[i].items || !slices.Equal(names, want[i].products) {
+			t.Errorf("Order %d: expected %d items of %v, got %+v with %v", i, want[i].items, want[i].products, o.OrderedProduct, names)
+		}
+	}
+}
+
+func TestApiKeys_RoleBackfill(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+
+	// Keys issued before roles existed
+	db.Exec(`INSERT INTO api_keys (name, key_hash, prefix, role, scopes) VALUES ('ops', 'hash-ops', 'kart_1', '', 'read_orders,admin')`)
+	db.Exec(`INSERT INTO api_keys (name, key_hash, prefix, role, scopes) VALUES ('app', 'hash-app', 'kart_2', '', 'create_order')`)
+	repo.CreateTables()
+
+	if key, _ := repo.GetApiKeyByHash(ctx, "hash-ops"); key == nil || key.Role != "admin" {
+		t.Errorf("Expected admin role, got %+v", key)
+	}
+	if key, _ := repo.GetApiKeyByHash(ctx, "hash-app"); key == nil || key.Role != "customer" {
+		t.Errorf("Expected customer role, got %+v", key)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`INSERT INTO coupons (promo_code, discount) VALUES ('SAVE10', 10.0)`)
+
+	reqCtx := audit.WithRequestId(audit.WithActor(ctx, "apikey:2"), "req-1")
+	if err := repo.SetCouponDisabled(reqCtx, "SAVE10", true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.OverrideCouponDiscount(ctx, "SAVE10", 25); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Failed changes leave no trace
+	if err := repo.SetCouponDisabled(reqCtx, "MISSING", true); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	events, err := repo.ListAuditEvents(ctx, model.AuditFilter{Entity: audit.EntityCoupon, EntityId: "SAVE10", Limit: 10})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v, %v", events, err)
+	}
+	disable := events[1]
+	if disable.Action != audit.CouponDisable || disable.Actor != "apikey:2" || disable.RequestId != "req-1" {
+		t.Errorf("Unexpected event %+v", disable)
+	}
+	if !strings.Contains(string(disable.Before), `"disabled":false`) || !strings.Contains(string(disable.After), `"disabled":true`) {
+		t.Errorf("Expected before/after snapshots, got %s -> %s", disable.Before, disable.After)
+	}
+	if events[0].Actor != audit.SystemActor {
+		t.Errorf("Expected system actor, got %s", events[0].Actor)
+	}
+
+	// Filters
+	events, _ = repo.ListAuditEvents(ctx, model.AuditFilter{Actor: "apikey:2", Limit: 10})
+	if len(events) != 1 {
+		t.Errorf("Expected 1 event by apikey:2, got %d", len(events))
+	}
+	future := time.Now().Add(time.Hour)
+	events, _ = repo.ListAuditEvents(ctx, model.AuditFilter{Since: &future, Limit: 10})
+	if len(events) != 0 {
+		t.Errorf("Expected no future events, got %d", len(events))
+	}
+
+	// Export is oldest first
+	var exported []string
+	repo.ExportAuditEvents(ctx, model.AuditFilter{}, func(e model.AuditEvent) error {
+		exported = append(exported, e.Action)
+		return nil
+	})
+	if len(exported) != 2 || exported[0] != audit.CouponDisable {
+		t.Errorf("Unexpected export %v", exported)
+	}
+
+	// The log is append-only
+	if _, err := db.Exec(`UPDATE audit_events SET actor = 'someone'`); err == nil {
+		t.Error("Expected update of audit_events to fail")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_events`); err == nil {
+		t.Error("Expected delete from audit_events to fail")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+	repo := &kartRepository{dbClient: db}
+
+	if v, _ := repo.SchemaVersion(ctx); v != 0 {
+		t.Errorf("Expected version 0 before migrating, got %d", v)
+	}
+	if err := repo.CreateTables(); err != nil {
+		t.Fatalf("Failed creating tables: %v", err)
+	}
+	if v, err := repo.SchemaVersion(ctx); err != nil || v != CurrentSchemaVersion {
+		t.Errorf("Expected version %d, got %d (%v)", CurrentSchemaVersion, v, err)
+	}
+}
+
+func TestPlaceOrder_Metrics(t *testing.T) {
+	db := setupTestDB()
+	defer db.Close()
+
+	repo := &kartRepository{dbClient: db}
+	repo.CreateTables()
+	db.Exec(`DELETE FROM products`)
+	db.Exec(`INSERT INTO products (id, name, price, category, is_available) VALUES (1, 'Test Product', 100.0, 'Test', 1)`)
+
+	rollbacks := metrics.DbRollbacks.WithLabelValues("place_order")
+	misses := metrics.CouponLookups.WithLabelValues("order", metrics.CouponMiss)
+	beforeRollbacks, beforeMisses := testutil.ToFloat64(rollbacks), testutil.ToFloat64(misses)
+
+	_, err := repo.PlaceOrder(ctx, model.OrderDetail{
+		CouponCode:     "NOSUCHCODE",
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}},
+	})
+	if !myerror.IsInvalidCoupon(err) {
+		t.Fatalf("Expected invalid coupon, got %v", err)
+	}
+	if got := testutil.ToFloat64(rollbacks) - beforeRollbacks; got != 1 {
+		t.Errorf("Expected 1 rollback, got %v", got)
+	}
+	if got := testutil.ToFloat64(misses) - beforeMisses; got != 1 {
+		t.Errorf("Expected 1 coupon miss, got %v", got)
+	}
+
+	// committed transactions aren't rollbacks
+	beforeRollbacks = testutil.ToFloat64(rollbacks)
+	if _, err := repo.PlaceOrder(ctx, model.OrderDetail{OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := testutil.ToFloat64(rollbacks) - beforeRollbacks; got != 0 {
+		t.Errorf("Expected no rollback after commit, got %v", got)
+	}
+}
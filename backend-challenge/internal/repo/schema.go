@@ -2,12 +2,16 @@ package repo
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/audit"
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
 )
 
 func (k *kartRepository) CreateTables() error {
@@ -34,6 +38,18 @@ func (k *kartRepository) CreateTables() error {
 		return err
 	}
 
+	// updated_at drives the catalog's Last-Modified, so keep it current
+	// whoever edits a product
+	_, err = k.dbClient.Exec(`CREATE TRIGGER IF NOT EXISTS products_updated_at AFTER UPDATE ON products
+	WHEN NEW.updated_at = OLD.updated_at
+	BEGIN
+		UPDATE products SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	END;`)
+	if err != nil {
+		fmt.Println("Failed creating trigger on products. Error: ", err)
+		return err
+	}
+
 	// Create Order table
 	orderCmd := `
 	CREATE TABLE IF NOT EXISTS orders 
@@ -80,6 +96,7 @@ func (k *kartRepository) CreateTables() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		promo_code TEXT UNIQUE NOT NULL,
 		discount REAL NOT NULL,
+		discount_locked INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -90,6 +107,152 @@ func (k *kartRepository) CreateTables() error {
 		return err
 	}
 
+	// Columns added after the first release, for databases created before them
+	couponColumns := [][2]string{
+		{"discount_locked", "INTEGER DEFAULT 0"},
+		{"valid_from", "DATETIME"},
+		{"valid_to", "DATETIME"},
+		{"max_uses", "INTEGER"},
+		{"max_uses_per_customer", "INTEGER"},
+		{"min_order_value", "REAL DEFAULT 0.0"},
+		{"times_used", "INTEGER DEFAULT 0"},
+		{"disabled", "INTEGER DEFAULT 0"},
+	}
+	for _, col := range couponColumns {
+		if err = k.addColumnIfNotExists("coupons", col[0], col[1]); err != nil {
+			return err
+		}
+	}
+
+	// Table to track coupon usage per customer
+	redemptionCmd := `
+	CREATE TABLE IF NOT EXISTS coupon_redemptions
+	(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		coupon_id INTEGER NOT NULL,
+		customer_id TEXT NOT NULL,
+		order_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (coupon_id) REFERENCES coupons(id),
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_customer ON coupon_redemptions (coupon_id, customer_id);
+	`
+	_, err = k.dbClient.Exec(redemptionCmd)
+	if err != nil {
+		fmt.Println("Failed creating table coupon_redemptions. Error: ", err)
+		return err
+	}
+
+	// Table of registered customers, identified by their identity provider subject
+	customerCmd := `
+	CREATE TABLE IF NOT EXISTS customers
+	(
+		id TEXT PRIMARY KEY,
+		external_id TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL,
+		email TEXT UNIQUE NOT NULL,
+		phone TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err = k.dbClient.Exec(customerCmd)
+	if err != nil {
+		fmt.Println("Failed creating table customers. Error: ", err)
+		return err
+	}
+
+	// Orders placed before customer accounts existed stay anonymous
+	if err = k.addColumnIfNotExists("orders", "customer_id", "TEXT REFERENCES customers(id)"); err != nil {
+		return err
+	}
+	_, err = k.dbClient.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id, created_at)`)
+	if err != nil {
+		fmt.Println("Failed creating index on orders. Error: ", err)
+		return err
+	}
+
+	// Orders placed before the lifecycle existed are still placed
+	for _, col := range [][2]string{
+		{"status", "TEXT NOT NULL DEFAULT 'placed'"},
+		{"eta", "DATETIME"},
+	} {
+		if err = k.addColumnIfNotExists("orders", col[0], col[1]); err != nil {
+			return err
+		}
+	}
+
+	// Table of API keys, stored by hash
+	apiKeyCmd := `
+	CREATE TABLE IF NOT EXISTS api_keys
+	(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT UNIQUE NOT NULL,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err = k.dbClient.Exec(apiKeyCmd)
+	if err != nil {
+		fmt.Println("Failed creating table api_keys. Error: ", err)
+		return err
+	}
+
+	// Keys issued before roles existed are admins when they hold the admin
+	// scope and customers otherwise
+	if err = k.addColumnIfNotExists("api_keys", "role", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err = k.dbClient.Exec(`UPDATE api_keys SET role = CASE WHEN ',' || scopes || ',' LIKE '%,admin,%' THEN 'admin' ELSE 'customer' END
+		WHERE role = ''`)
+	if err != nil {
+		fmt.Println("Failed assigning roles to API keys. Error: ", err)
+		return err
+	}
+
+	// Append-only log of changes, the triggers reject edits and deletes
+	auditCmd := `
+	CREATE TABLE IF NOT EXISTS audit_events
+	(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		occurred_at DATETIME NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		before TEXT,
+		after TEXT,
+		request_id TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events (entity, entity_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_occurred ON audit_events (occurred_at);
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;
+	`
+	_, err = k.dbClient.Exec(auditCmd)
+	if err != nil {
+		fmt.Println("Failed creating table audit_events. Error: ", err)
+		return err
+	}
+
+	if _, err = k.dbClient.Exec(fmt.Sprintf("PRAGMA user_version = %d", CurrentSchemaVersion)); err != nil {
+		fmt.Println("Failed recording schema version. Error: ", err)
+		return err
+	}
+
 	fmt.Println("All the tables are successully created")
 
 	// Populate products table if no data found in it
@@ -101,6 +264,40 @@ func (k *kartRepository) CreateTables() error {
 	return nil
 }
 
+// SQLite has no "ADD COLUMN IF NOT EXISTS", so look the column up first
+func (k *kartRepository) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := k.dbClient.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		fmt.Printf("Failed reading schema of table %s. Error: %v\n", table, err)
+		return err
+	}
+
+	found := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt any
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			found = true
+		}
+	}
+	rows.Close()
+	if found {
+		return nil
+	}
+
+	_, err = k.dbClient.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		fmt.Printf("Failed adding column %s to table %s. Error: %v\n", column, table, err)
+		return err
+	}
+	return nil
+}
+
 func (k *kartRepository) PopulateTables() {
 	var products = map[string][]string{
 		"Waffle":    {"Chicken Waffle", "Banana Waffle", "Belgian Waffle", "Chocolate Waffle", "Red Velvet Waffle"},
@@ -145,17 +342,36 @@ func (k *kartRepository) PopulateTables() {
 
 }
 
-func (k *kartRepository) PopulateCoupons(filePath string) {
+// Load valid codes with the discount given by the policy. Existing codes are
+// updated to the policy's discount unless an admin has overridden it.
+func (k *kartRepository) PopulateCoupons(ctx context.Context, filePath string, policy coupon.DiscountPolicy) error {
+	defer observe("populate_coupons")()
 	fmt.Println("Populating coupons in DB")
-	rand.Seed(time.Now().UnixNano())
 
-	filePath = "../token/" + filePath
 	file, err := os.Open(filePath)
 	if err != nil {
 		log.Println("Error opening valid token file:", err)
-		return
+		return err
+	}
+	defer file.Close()
+
+	tx, err := k.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Failed to begin transaction:", err)
+		return err
+	}
+	defer rollback(tx, "populate_coupons")
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO coupons (promo_code, discount) VALUES (?, ?)
+		ON CONFLICT(promo_code) DO UPDATE SET discount = excluded.discount, updated_at = CURRENT_TIMESTAMP
+		WHERE discount_locked = 0 AND discount != excluded.discount`)
+	if err != nil {
+		log.Println("Failed to prepare statement:", err)
+		return err
 	}
+	defer stmt.Close()
 
+	var upserted int64
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		code := scanner.Text()
@@ -163,13 +379,35 @@ func (k *kartRepository) PopulateCoupons(filePath string) {
 			continue
 		}
 
-		discount := 10 + rand.Float64()*(50-10)
-		discount = float64(int(discount*100)) / 100 // truncate to 2 decimals
-
-		_, err := k.dbClient.Exec("INSERT OR IGNORE INTO coupons (promo_code, discount) VALUES (?, ?)", code, discount)
+		res, err := stmt.ExecContext(ctx, code, policy.Discount(code))
 		if err != nil {
+			// shutting down, the transaction is rolled back
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			log.Println("Insert error:", err)
+			continue
+		}
+		n, _ := res.RowsAffected()
+		upserted += n
+	}
+	if err = scanner.Err(); err != nil {
+		log.Println("Failed reading valid token file:", err)
+		return err
+	}
+
+	// a single event per run, codes are re-derived from the file at every start
+	if upserted > 0 {
+		after := map[string]any{"file": filePath, "upserted": upserted}
+		if err = recordEvent(ctx, tx, audit.CouponPopulate, audit.EntityCoupon, "*", nil, after); err != nil {
+			return err
 		}
 	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Failed to commit coupons:", err)
+		return err
+	}
 	fmt.Println("Done populating coupons in DB")
+	return nil
 }
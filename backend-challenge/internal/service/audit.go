@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
+)
+
+type AuditService interface {
+	ListEvents(context.Context, model.AuditFilter) ([]model.AuditEvent, error)
+	ExportEvents(context.Context, model.AuditFilter, io.Writer) error
+}
+
+type auditService struct {
+	db repo.KartRepository
+}
+
+func NewAuditService(db repo.KartRepository) AuditService {
+	return &auditService{db}
+}
+
+func (a *auditService) ListEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.ListEvents")
+	defer span.End()
+
+	events, err := a.db.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Write the matching events to w as JSON lines, one event per line
+func (a *auditService) ExportEvents(ctx context.Context, filter model.AuditFilter, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "AuditService.ExportEvents")
+	defer span.End()
+
+	enc := json.NewEncoder(w)
+	return a.db.ExportAuditEvents(ctx, filter, func(e model.AuditEvent) error {
+		return enc.Encode(e)
+	})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
+)
+
+// Imported coupons are written this many at a time, so an import never holds
+// more than one batch in memory
+const importBatchSize = 500
+
+// Line errors reported back from an import are capped at this many
+const maxImportErrors = 20
+
+type CouponService interface {
+	OverrideDiscount(context.Context, string, float64) (*model.Coupon, error)
+	UpdateRules(context.Context, string, model.CouponRules) (*model.CouponRules, error)
+	GetStatus(context.Context, string) (*model.CouponStatus, error)
+	CreateCoupon(context.Context, model.CouponCreateReq) (*model.CouponInfo, error)
+	ImportCoupons(context.Context, io.Reader) (*model.CouponImportResult, error)
+	ListCoupons(context.Context, model.CouponFilter) ([]model.CouponInfo, error)
+	SetDisabled(context.Context, string, bool) error
+}
+
+type couponService struct {
+	db     repo.KartRepository
+	policy coupon.DiscountPolicy
+}
+
+func NewCouponService(db repo.KartRepository, policy coupon.DiscountPolicy) CouponService {
+	return &couponService{db, policy}
+}
+
+// stored discounts are truncated to 2 decimals
+func truncateDiscount(discount float64) float64 {
+	return float64(int(discount*100)) / 100
+}
+
+func (c *couponService) OverrideDiscount(ctx context.Context, code string, discount float64) (*model.Coupon, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.OverrideDiscount")
+	defer span.End()
+
+	coupon, err := c.db.OverrideCouponDiscount(ctx, code, truncateDiscount(discount))
+	if err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+func (c *couponService) UpdateRules(ctx context.Context, code string, rules model.CouponRules) (*model.CouponRules, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.UpdateRules")
+	defer span.End()
+
+	updated, err := c.db.UpdateCouponRules(ctx, code, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (c *couponService) GetStatus(ctx context.Context, code string) (*model.CouponStatus, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.GetStatus")
+	defer span.End()
+
+	status, err := c.db.GetCouponStatus(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func (c *couponService) CreateCoupon(ctx context.Context, req model.CouponCreateReq) (*model.CouponInfo, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.CreateCoupon")
+	defer span.End()
+
+	cpn := model.Coupon{Code: req.Code, Discount: c.policy.Discount(req.Code)}
+	if req.Discount != nil {
+		cpn.Discount = truncateDiscount(*req.Discount)
+		cpn.Locked = true
+	}
+
+	var rules model.CouponRules
+	if req.Rules != nil {
+		rules = *req.Rules
+	}
+
+	info, err := c.db.CreateCoupon(ctx, cpn, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Read "CODE" or "CODE,discount" lines and add the codes which don't exist
+// yet. Invalid lines are counted and reported but don't stop the import.
+// Batches are committed as they are read, so an import failing partway keeps
+// what it committed; its error tells how many coupons that was. Importing the
+// file again skips them.
+func (c *couponService) ImportCoupons(ctx context.Context, r io.Reader) (*model.CouponImportResult, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.ImportCoupons")
+	defer span.End()
+
+	result := &model.CouponImportResult{}
+	batch := make([]model.Coupon, 0, importBatchSize)
+	line := 0
+
+	// the error of an import stopped at line, with the coupons committed
+	// before
+	stopped := func(err error, line int) error {
+		if result.Imported+result.Skipped == 0 {
+			return err
+		}
+		var kErr myerror.KartError
+		if !errors.As(err, &kErr) {
+			kErr = myerror.KartError{Code: 500, Msg: "Failed importing coupons"}
+		}
+		kErr.Msg = fmt.Sprintf("%s at line %d, %d coupons were imported before", kErr.Msg, line, result.Imported)
+		return kErr
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inserted, err := c.db.ImportCoupons(ctx, batch)
+		if err != nil {
+			return stopped(err, line)
+		}
+		result.Imported += inserted
+		result.Skipped += len(batch) - inserted
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if line == 1 && strings.HasPrefix(strings.ToLower(text), "code") {
+			// CSV header
+			continue
+		}
+
+		cpn, err := c.parseImportLine(text)
+		if err != nil {
+			result.Invalid++
+			if len(result.Errors) < maxImportErrors {
+				result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			}
+			continue
+		}
+
+		batch = append(batch, cpn)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Println("Failed reading coupon import. Error:", err)
+		return nil, stopped(myerror.KartError{Code: 400, Msg: "Failed reading import file"}, line+1)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *couponService) parseImportLine(text string) (model.Coupon, error) {
+	code, discountStr, hasDiscount := strings.Cut(text, ",")
+	code = strings.TrimSpace(code)
+	if !coupon.IsValidCode(code) {
+		return model.Coupon{}, fmt.Errorf("invalid code %q", code)
+	}
+
+	if !hasDiscount {
+		return model.Coupon{Code: code, Discount: c.policy.Discount(code)}, nil
+	}
+
+	discount, err := strconv.ParseFloat(strings.TrimSpace(discountStr), 64)
+	if err != nil || !coupon.IsValidDiscount(discount) {
+		return model.Coupon{}, fmt.Errorf("invalid discount %q", discountStr)
+	}
+	return model.Coupon{Code: code, Discount: truncateDiscount(discount), Locked: true}, nil
+}
+
+func (c *couponService) ListCoupons(ctx context.Context, filter model.CouponFilter) ([]model.CouponInfo, error) {
+	ctx, span := tracing.Start(ctx, "CouponService.ListCoupons")
+	defer span.End()
+
+	coupons, err := c.db.ListCoupons(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return coupons, nil
+}
+
+func (c *couponService) SetDisabled(ctx context.Context, code string, disabled bool) error {
+	ctx, span := tracing.Start(ctx, "CouponService.SetDisabled")
+	defer span.End()
+
+	return c.db.SetCouponDisabled(ctx, code, disabled)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
+)
+
+type CustomerService interface {
+	Register(context.Context, string, model.CustomerReq) (*model.Customer, error)
+	Get(context.Context, string) (*model.Customer, error)
+	Update(context.Context, string, model.CustomerReq) (*model.Customer, error)
+}
+
+type customerService struct {
+	db repo.KartRepository
+}
+
+func NewCustomerService(db repo.KartRepository) CustomerService {
+	return &customerService{db}
+}
+
+func (c *customerService) Register(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.Register")
+	defer span.End()
+
+	customer, err := c.db.CreateCustomer(ctx, subject, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+func (c *customerService) Get(ctx context.Context, subject string) (*model.Customer, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.Get")
+	defer span.End()
+
+	customer, err := c.db.GetCustomerBySubject(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+func (c *customerService) Update(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.Update")
+	defer span.End()
+
+	customer, err := c.db.UpdateCustomer(ctx, subject, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+)
+
+// Component states reported by the health endpoints
+const (
+	StateOk      = "ok"
+	StateLoading = "loading"
+	StateFailed  = "failed"
+)
+
+// Health checks give up on the database after this long
+const healthCheckTimeout = 2 * time.Second
+
+type HealthService interface {
+	Live() model.Health
+	Ready(context.Context) (model.Health, bool)
+	Status(context.Context) model.Status
+	// Startup reports the coupon store's progress, it is not ready until
+	// CouponsLoaded is called without an error
+	CouponsLoading()
+	CouponsLoaded(error)
+	// Whether coupons are being loaded, lookups of valid codes fail meanwhile
+	LoadingCoupons() bool
+}
+
+type healthService struct {
+	db        repo.HealthRepository
+	version   string
+	startedAt time.Time
+	now       func() time.Time
+
+	mu         sync.Mutex
+	coupons    string
+	couponsErr error
+}
+
+func NewHealthService(db repo.HealthRepository, version string) HealthService {
+	return &healthService{
+		db:        db,
+		version:   version,
+		startedAt: time.Now(),
+		now:       time.Now,
+		coupons:   StateLoading,
+	}
+}
+
+func (h *healthService) CouponsLoading() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.coupons, h.couponsErr = StateLoading, nil
+}
+
+func (h *healthService) CouponsLoaded(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.coupons, h.couponsErr = StateFailed, err
+		return
+	}
+	h.coupons, h.couponsErr = StateOk, nil
+}
+
+func (h *healthService) LoadingCoupons() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.coupons == StateLoading
+}
+
+func (h *healthService) Live() model.Health {
+	return model.Health{Status: StateOk}
+}
+
+// Ready when the database answers, is migrated and the coupon store loaded
+func (h *healthService) Ready(ctx context.Context) (model.Health, bool) {
+	return readiness(h.components(ctx))
+}
+
+func readiness(components map[string]model.ComponentStatus) (model.Health, bool) {
+	health := model.Health{Status: "ready", Checks: map[string]string{}}
+	ready := true
+	for name, c := range components {
+		health.Checks[name] = c.Status
+		if c.Status != StateOk {
+			ready = false
+		}
+	}
+	if !ready {
+		health.Status = "not ready"
+	}
+	return health, ready
+}
+
+func (h *healthService) Status(ctx context.Context) model.Status {
+	components := h.components(ctx)
+	health, _ := readiness(components)
+	status := model.Status{
+		Status:     health.Status,
+		Version:    h.version,
+		GoVersion:  runtime.Version(),
+		StartedAt:  h.startedAt.UTC(),
+		Uptime:     h.now().Sub(h.startedAt).Round(time.Second).String(),
+		Components: components,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				status.Revision = s.Value
+			}
+		}
+	}
+	return status
+}
+
+// Components with their state. /status is public, so failures carry a fixed
+// message and the error itself is only logged: driver errors can name files
+// and SQL.
+func (h *healthService) components(ctx context.Context) map[string]model.ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	components := map[string]model.ComponentStatus{}
+
+	database := model.ComponentStatus{Status: StateOk}
+	if err := h.db.Ping(ctx); err != nil {
+		fmt.Println("Health check failed to reach the database. Error:", err)
+		database = model.ComponentStatus{Status: StateFailed, Message: "unreachable"}
+	} else if v, err := h.db.SqliteVersion(ctx); err == nil {
+		database.Version = "sqlite " + v
+	}
+	components["database"] = database
+
+	migrations := model.ComponentStatus{Status: StateOk}
+	if v, err := h.db.SchemaVersion(ctx); err != nil {
+		fmt.Println("Health check failed to read the schema version. Error:", err)
+		migrations = model.ComponentStatus{Status: StateFailed, Message: "unreadable"}
+	} else {
+		migrations.Version = fmt.Sprint(v)
+		if v < repo.CurrentSchemaVersion {
+			migrations.Status = StateFailed
+			migrations.Message = fmt.Sprintf("schema version %d, expected %d", v, repo.CurrentSchemaVersion)
+		}
+	}
+	components["migrations"] = migrations
+
+	h.mu.Lock()
+	coupons := model.ComponentStatus{Status: h.coupons}
+	if h.couponsErr != nil {
+		// logged by the loading
+		coupons.Message = "loading failed"
+	}
+	h.mu.Unlock()
+	components["coupons"] = coupons
+
+	return components
+}
@@ -1,23 +1,68 @@
 package service
 
 import (
+	"context"
+	"slices"
+	"sync"
+
+	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
+	"github.com/priykumar/oolio-kart-challenge/internal/metrics"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
 	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type OrderService interface {
-	PlaceOrder(model.OrderDetail) (*model.OrderResp, error)
+	PlaceOrder(context.Context, model.OrderDetail) (*model.OrderResp, error)
+	GetOrder(context.Context, string, model.OrderAccess) (*model.OrderResp, error)
+	ListOrders(context.Context, model.OrderAccess, model.OrderFilter) ([]model.OrderResp, error)
+	UpdateOrderStatus(context.Context, string, model.OrderStatusUpdate) (*model.OrderResp, error)
+	WatchOrder(context.Context, string, model.OrderAccess, string) ([]events.Event, *events.Subscription, error)
 }
 
 type orderService struct {
-	db repo.KartRepository
+	db  repo.KartRepository
+	hub *events.Hub
+	// Serialises status updates, so their events are published in the
+	// order they were committed
+	updateMu sync.Mutex
 }
 
-func NewOrderService(db repo.KartRepository) OrderService {
-	return &orderService{db}
+// Changes to orders are published to hub
+func NewOrderService(db repo.KartRepository, hub *events.Hub) OrderService {
+	return &orderService{db: db, hub: hub}
 }
 
-func (o *orderService) PlaceOrder(oDetail model.OrderDetail) (*model.OrderResp, error) {
+// Resolve the customer a bearer token subject belongs to. Tokens of
+// customers who haven't registered yet can't place or read orders.
+func (o *orderService) customerId(ctx context.Context, subject string) (string, error) {
+	customer, err := o.db.GetCustomerBySubject(ctx, subject)
+	if err != nil {
+		if myerror.IsNotFound(err) {
+			return "", myerror.KartError{Code: 403, Msg: "Customer is not registered"}
+		}
+		return "", err
+	}
+
+	return customer.Id, nil
+}
+
+func (o *orderService) PlaceOrder(ctx context.Context, oDetail model.OrderDetail) (*model.OrderResp, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.PlaceOrder")
+	defer span.End()
+
+	if oDetail.Subject != "" {
+		id, err := o.customerId(ctx, oDetail.Subject)
+		if err != nil {
+			return nil, err
+		}
+		// coupon caps per customer follow the account rather than the token
+		oDetail.CustomerId = id
+		oDetail.CallerId = "customer:" + id
+	}
+
 	// check for duplicate productIds
 	pId_Count := map[string]int{}
 	for _, items := range oDetail.OrderedProduct {
@@ -29,11 +74,130 @@ func (o *orderService) PlaceOrder(oDetail model.OrderDetail) (*model.OrderResp,
 		items = append(items, model.OrderedProduct{ProductId: k, Quantity: v})
 	}
 	oDetail.OrderedProduct = items
+	span.SetAttributes(
+		attribute.Int("order.items", len(items)),
+		attribute.Bool("order.coupon", oDetail.CouponCode != ""),
+	)
 
-	order, err := o.db.PlaceOrder(oDetail)
+	order, err := o.db.PlaceOrder(ctx, oDetail)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("order.id", order.Id))
 
+	metrics.OrdersPlaced.Inc()
+	metrics.OrderRevenue.Add(order.Total)
+	metrics.OrderDiscount.Add(order.Discount)
 	return order, err
 }
+
+func (o *orderService) GetOrder(ctx context.Context, orderId string, access model.OrderAccess) (*model.OrderResp, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.GetOrder")
+	defer span.End()
+
+	var owner string
+	if !access.All {
+		if access.Subject == "" {
+			return nil, myerror.KartError{Code: 403, Msg: "Orders can only be read by the customer who placed them"}
+		}
+		id, err := o.customerId(ctx, access.Subject)
+		if err != nil {
+			return nil, err
+		}
+		owner = id
+	}
+
+	order, err := o.db.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	// someone else's order is reported as missing, so order ids can't be probed
+	if !access.All && order.CustomerId != owner {
+		return nil, myerror.KartError{Code: 404, Msg: "Order not found"}
+	}
+
+	return order, nil
+}
+
+func (o *orderService) ListOrders(ctx context.Context, access model.OrderAccess, filter model.OrderFilter) ([]model.OrderResp, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.ListOrders")
+	defer span.End()
+
+	if !access.All {
+		if access.Subject == "" {
+			return nil, myerror.KartError{Code: 403, Msg: "Orders can only be read by the customer who placed them"}
+		}
+		id, err := o.customerId(ctx, access.Subject)
+		if err != nil {
+			return nil, err
+		}
+		filter.CustomerId = id
+	}
+
+	orders, err := o.db.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Move an order along its lifecycle or change its ETA, and tell the clients
+// watching it once that is committed
+func (o *orderService) UpdateOrderStatus(ctx context.Context, orderId string, update model.OrderStatusUpdate) (*model.OrderResp, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.UpdateOrderStatus")
+	defer span.End()
+	span.SetAttributes(attribute.String("order.id", orderId), attribute.String("order.status", update.Status))
+
+	o.updateMu.Lock()
+	defer o.updateMu.Unlock()
+
+	order, err := o.db.UpdateOrderStatus(ctx, orderId, update)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	eventType := events.Status
+	if update.Status == "" {
+		eventType = events.Eta
+	}
+	o.hub.Publish(eventType, orderEvent(*order))
+	return order, nil
+}
+
+// Subscribe to the changes of an order the caller may read. The events to
+// send first are those missed since lastEventId, or the current state of the
+// order when they aren't known. The subscription is nil when the order won't
+// change any more, otherwise it must be closed.
+func (o *orderService) WatchOrder(ctx context.Context, orderId string, access model.OrderAccess, lastEventId string) ([]events.Event, *events.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.WatchOrder")
+	defer span.End()
+
+	// subscribed before the order is read, so no change falls in between
+	sub, missed, complete := o.hub.Subscribe(orderId, lastEventId)
+	order, err := o.GetOrder(ctx, orderId, access)
+	if err != nil {
+		sub.Close()
+		return nil, nil, err
+	}
+
+	if !complete {
+		missed = []events.Event{sub.Snapshot(orderEvent(*order))}
+	}
+	if slices.Contains(model.FinalOrderStatuses, order.Status) {
+		sub.Close()
+		return missed, nil, nil
+	}
+	return missed, sub, nil
+}
+
+func orderEvent(order model.OrderResp) model.OrderEvent {
+	e := model.OrderEvent{OrderId: order.Id, Status: order.Status, Eta: order.Eta}
+	if order.UpdatedAt != nil {
+		e.UpdatedAt = *order.UpdatedAt
+	}
+	return e
+}
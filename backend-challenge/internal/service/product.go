@@ -1,25 +1,44 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
 	"github.com/priykumar/oolio-kart-challenge/internal/repo"
+	"github.com/priykumar/oolio-kart-challenge/internal/tracing"
 )
 
 type ProductService interface {
-	GetAllAvailableProducts() ([]model.Product, error)
-	GetProductById(int64) (*model.Product, error)
+	GetProductById(context.Context, int64) (*model.Product, error)
+	GetCatalog(context.Context) (*model.Catalog, error)
 }
 
 type productService struct {
 	db repo.KartRepository
+	// How long a catalog is served from memory, for products changed outside
+	// the server, which only writes them when seeding the database. 0 keeps
+	// it for as long as the server runs.
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	catalog  *model.Catalog
+	loadedAt time.Time
 }
 
-func NewProductService(db repo.KartRepository) ProductService {
-	return &productService{db}
+func NewProductService(db repo.KartRepository, cacheTTL time.Duration) ProductService {
+	return &productService{db: db, cacheTTL: cacheTTL}
 }
 
-func (p *productService) GetAllAvailableProducts() ([]model.Product, error) {
-	products, err := p.db.ListAvailableProducts()
+func (p *productService) GetProductById(ctx context.Context, productId int64) (*model.Product, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetProductById")
+	defer span.End()
+
+	products, err := p.db.GetProductById(ctx, productId)
 	if err != nil {
 		return nil, err
 	}
@@ -27,11 +46,47 @@ func (p *productService) GetAllAvailableProducts() ([]model.Product, error) {
 	return products, nil
 }
 
-func (p *productService) GetProductById(productId int64) (*model.Product, error) {
-	products, err := p.db.GetProductById(productId)
+// Available products, read from SQLite again once the cache expired. The catalog returned is shared and must not be modified.
+func (p *productService) GetCatalog(ctx context.Context) (*model.Catalog, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetCatalog")
+	defer span.End()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.catalog != nil && (p.cacheTTL <= 0 || time.Since(p.loadedAt) < p.cacheTTL) {
+		return p.catalog, nil
+	}
+
+	products, err := p.db.ListAvailableProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lastModified, err := p.db.ProductsLastModified(ctx)
+	if err != nil {
+		return nil, err
+	}
+	etag, err := catalogETag(products)
 	if err != nil {
 		return nil, err
 	}
 
-	return products, nil
+	p.catalog = &model.Catalog{Products: products, ETag: etag, LastModified: lastModified}
+	p.loadedAt = time.Now()
+	return p.catalog, nil
+}
+
+// Hash of everything about the products, including when they were changed
+func catalogETag(products []model.Product) (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, product := range products {
+		if err := enc.Encode(product); err != nil {
+			return "", err
+		}
+		if err := enc.Encode(product.UpdatedAt); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
 }
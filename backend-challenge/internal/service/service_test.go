@@ -1,23 +1,44 @@
 package service
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/priykumar/oolio-kart-challenge/internal/coupon"
 	myerror "github.com/priykumar/oolio-kart-challenge/internal/error"
+	"github.com/priykumar/oolio-kart-challenge/internal/events"
 	"github.com/priykumar/oolio-kart-challenge/internal/model"
+	"github.com/priykumar/oolio-kart-challenge/internal/repo"
 )
 
+var ctx = context.Background()
+
 // Mock Repository
 type mockKartRepository struct {
-	products map[int64]*model.Product
-	order    *model.OrderResp
-	err      error
+	products  map[int64]*model.Product
+	order     *model.OrderResp
+	coupons   map[string]model.Coupon
+	customers map[string]*model.Customer // by subject
+	orders    map[string]*model.OrderResp
+	placed    model.OrderDetail
+	listed    model.OrderFilter
+	events    []model.AuditEvent
+	batches   int
+	err       error
+	// product listings read
+	listings int
 }
 
-func (m *mockKartRepository) ListAvailableProducts() ([]model.Product, error) {
+func (m *mockKartRepository) ListAvailableProducts(ctx context.Context) ([]model.Product, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	m.listings++
 	var products []model.Product
 	for _, p := range m.products {
 		products = append(products, *p)
@@ -25,7 +46,17 @@ func (m *mockKartRepository) ListAvailableProducts() ([]model.Product, error) {
 	return products, nil
 }
 
-func (m *mockKartRepository) GetProductById(id int64) (*model.Product, error) {
+func (m *mockKartRepository) ProductsLastModified(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	for _, p := range m.products {
+		if p.UpdatedAt.After(last) {
+			last = p.UpdatedAt
+		}
+	}
+	return last, m.err
+}
+
+func (m *mockKartRepository) GetProductById(ctx context.Context, id int64) (*model.Product, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -35,47 +66,223 @@ func (m *mockKartRepository) GetProductById(id int64) (*model.Product, error) {
 	return nil, myerror.KartError{Code: 404, Msg: "Product not found"}
 }
 
-func (m *mockKartRepository) PlaceOrder(oDetail model.OrderDetail) (*model.OrderResp, error) {
+func (m *mockKartRepository) PlaceOrder(ctx context.Context, oDetail model.OrderDetail) (*model.OrderResp, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	m.placed = oDetail
 	return m.order, nil
 }
 
-func (m *mockKartRepository) PopulateCoupons(string) {}
+func (m *mockKartRepository) CreateCustomer(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	c := &model.Customer{Id: "cust-" + subject, Name: req.Name, Email: req.Email}
+	m.customers[subject] = c
+	return c, nil
+}
+
+func (m *mockKartRepository) GetCustomerBySubject(ctx context.Context, subject string) (*model.Customer, error) {
+	if c, exists := m.customers[subject]; exists {
+		return c, nil
+	}
+	return nil, myerror.KartError{Code: 404, Msg: "Customer not registered"}
+}
+
+func (m *mockKartRepository) UpdateCustomer(ctx context.Context, subject string, req model.CustomerReq) (*model.Customer, error) {
+	return m.CreateCustomer(ctx, subject, req)
+}
+
+func (m *mockKartRepository) GetOrder(ctx context.Context, orderId string) (*model.OrderResp, error) {
+	if o, exists := m.orders[orderId]; exists {
+		return o, nil
+	}
+	return nil, myerror.KartError{Code: 404, Msg: "Order not found"}
+}
+
+// Applies the update as is, the lifecycle is checked by the repository
+func (m *mockKartRepository) UpdateOrderStatus(ctx context.Context, orderId string, update model.OrderStatusUpdate) (*model.OrderResp, error) {
+	o, exists := m.orders[orderId]
+	if !exists {
+		return nil, myerror.KartError{Code: 404, Msg: "Order not found"}
+	}
+	if update.Status != "" {
+		o.Status = update.Status
+	}
+	if update.Eta != nil {
+		o.Eta = update.Eta
+	}
+	now := time.Now()
+	o.UpdatedAt = &now
+	return o, nil
+}
+
+func (m *mockKartRepository) ListAuditEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.events, nil
+}
+
+func (m *mockKartRepository) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, emit func(model.AuditEvent) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, e := range m.events {
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockKartRepository) ListOrders(ctx context.Context, filter model.OrderFilter) ([]model.OrderResp, error) {
+	m.listed = filter
+	orders := []model.OrderResp{}
+	for _, o := range m.orders {
+		if filter.CustomerId == "" || o.CustomerId == filter.CustomerId {
+			orders = append(orders, *o)
+		}
+	}
+	return orders, nil
+}
+
+func (m *mockKartRepository) PopulateCoupons(context.Context, string, coupon.DiscountPolicy) error {
+	return nil
+}
+
+func (m *mockKartRepository) CreateCoupon(ctx context.Context, cpn model.Coupon, rules model.CouponRules) (*model.CouponInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.CouponInfo{Code: cpn.Code, Discount: cpn.Discount, Rules: rules}, nil
+}
 
-// GetAllAvailableProducts Success Tests
-func TestGetAllAvailableProducts_Success(t *testing.T) {
+func (m *mockKartRepository) ImportCoupons(ctx context.Context, coupons []model.Coupon) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.batches++
+	inserted := 0
+	for _, c := range coupons {
+		if _, exists := m.coupons[c.Code]; !exists {
+			m.coupons[c.Code] = c
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+func (m *mockKartRepository) ListCoupons(context.Context, model.CouponFilter) ([]model.CouponInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []model.CouponInfo{}, nil
+}
+
+func (m *mockKartRepository) SetCouponDisabled(context.Context, string, bool) error {
+	return m.err
+}
+
+func (m *mockKartRepository) GetCouponStatus(ctx context.Context, code string) (*model.CouponStatus, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.CouponStatus{Code: code, Valid: true, DiscountType: "percentage", Discount: 18}, nil
+}
+
+func (m *mockKartRepository) UpdateCouponRules(ctx context.Context, code string, rules model.CouponRules) (*model.CouponRules, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &rules, nil
+}
+
+func (m *mockKartRepository) OverrideCouponDiscount(ctx context.Context, code string, discount float64) (*model.Coupon, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &model.Coupon{Code: code, Discount: discount}, nil
+}
+
+func TestGetCatalog_Success(t *testing.T) {
 	mockRepo := &mockKartRepository{
 		products: map[int64]*model.Product{
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	svc := NewProductService(mockRepo)
+	svc := NewProductService(mockRepo, 0)
 
-	products, err := svc.GetAllAvailableProducts()
+	catalog, err := svc.GetCatalog(ctx)
 	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
+		t.Fatalf("Expected no error, got %v", err)
 	}
-	if len(products) != 1 {
-		t.Errorf("Expected 1 product, got %d", len(products))
+	if len(catalog.Products) != 1 {
+		t.Errorf("Expected 1 product, got %d", len(catalog.Products))
 	}
 }
 
-// GetAllAvailableProducts Failure Test
-func TestGetAllAvailableProducts_Failure(t *testing.T) {
+func TestGetCatalog_Failure(t *testing.T) {
 	// Test success
 	mockRepo := &mockKartRepository{
 		err: myerror.KartError{Code: 500, Msg: "DB error"},
 	}
-	svc := NewProductService(mockRepo)
+	svc := NewProductService(mockRepo, 0)
 
-	_, err := svc.GetAllAvailableProducts()
+	_, err := svc.GetCatalog(ctx)
 	if err == nil {
 		t.Error("Expected error from repository, got nil")
 	}
 }
 
+// The listing is read once, and kept without a TTL
+func TestGetCatalog_Cache(t *testing.T) {
+	mockRepo := &mockKartRepository{
+		products: map[int64]*model.Product{
+			1: {Id: "1", Name: "Test Product", Price: 100.0, UpdatedAt: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+		},
+	}
+	svc := NewProductService(mockRepo, 0)
+
+	first, err := svc.GetCatalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ETag == "" || !first.LastModified.Equal(mockRepo.products[1].UpdatedAt) {
+		t.Errorf("Unexpected validators %q %v", first.ETag, first.LastModified)
+	}
+	if _, err := svc.GetCatalog(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mockRepo.listings != 1 {
+		t.Errorf("Expected the listing read once, read %d times", mockRepo.listings)
+	}
+}
+
+// Products changed outside the server show up once the cache expires
+func TestGetCatalog_TTL(t *testing.T) {
+	mockRepo := &mockKartRepository{products: map[int64]*model.Product{1: {Id: "1", Name: "Test Product", Price: 100.0}}}
+	svc := NewProductService(mockRepo, time.Millisecond)
+
+	first, err := svc.GetCatalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockRepo.products[1] = &model.Product{Id: "1", Name: "Test Product", Price: 120.0, UpdatedAt: time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC)}
+	time.Sleep(5 * time.Millisecond)
+	second, err := svc.GetCatalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockRepo.listings != 2 {
+		t.Errorf("Expected the expired listing read again, read %d times", mockRepo.listings)
+	}
+	if second.ETag == first.ETag || second.Products[0].Price != 120.0 {
+		t.Errorf("Expected a new catalog, got %+v", second)
+	}
+}
+
 func TestGetProductById_Success(t *testing.T) {
 	// Test success
 	mockRepo := &mockKartRepository{
@@ -83,9 +290,9 @@ func TestGetProductById_Success(t *testing.T) {
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	svc := NewProductService(mockRepo)
+	svc := NewProductService(mockRepo, 0)
 
-	product, err := svc.GetProductById(1)
+	product, err := svc.GetProductById(ctx, 1)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -94,7 +301,7 @@ func TestGetProductById_Success(t *testing.T) {
 	}
 
 	// Test product not found
-	_, err = svc.GetProductById(999)
+	_, err = svc.GetProductById(ctx, 999)
 	if err == nil {
 		t.Error("Expected error for non-existent product, got nil")
 	}
@@ -106,10 +313,10 @@ func TestGetProductById_Failure(t *testing.T) {
 			1: {Id: "1", Name: "Test Product", Price: 100.0},
 		},
 	}
-	svc := NewProductService(mockRepo)
+	svc := NewProductService(mockRepo, 0)
 
 	// Test product not found
-	_, err := svc.GetProductById(999)
+	_, err := svc.GetProductById(ctx, 999)
 	if err == nil {
 		t.Error("Expected error for non-existent product, got nil")
 	}
@@ -121,7 +328,7 @@ func TestPlaceOrder_Success(t *testing.T) {
 	mockRepo := &mockKartRepository{
 		order: &model.OrderResp{Id: "order-123", Total: 300.0},
 	}
-	svc := NewOrderService(mockRepo)
+	svc := NewOrderService(mockRepo, events.NewHub(0))
 
 	orderDetail := model.OrderDetail{
 		OrderedProduct: []model.OrderedProduct{
@@ -129,7 +336,7 @@ func TestPlaceOrder_Success(t *testing.T) {
 		},
 	}
 
-	order, err := svc.PlaceOrder(orderDetail)
+	order, err := svc.PlaceOrder(ctx, orderDetail)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -143,15 +350,368 @@ func TestPlaceOrder_Failure(t *testing.T) {
 	mockRepo := &mockKartRepository{
 		err: myerror.KartError{Code: 400, Msg: "Invalid product"},
 	}
-	svc := NewOrderService(mockRepo)
+	svc := NewOrderService(mockRepo, events.NewHub(0))
 
 	orderDetail := model.OrderDetail{
 		OrderedProduct: []model.OrderedProduct{
 			{ProductId: "1", Quantity: 2},
 		},
 	}
-	_, err := svc.PlaceOrder(orderDetail)
+	_, err := svc.PlaceOrder(ctx, orderDetail)
 	if err == nil {
 		t.Error("Expected error from repository, got nil")
 	}
 }
+
+// CouponService Tests
+func testPolicy() coupon.DiscountPolicy {
+	policy, _ := coupon.NewDiscountPolicy(coupon.PolicyConfig{Tiers: []float64{20}})
+	return policy
+}
+
+func TestOverrideDiscount_Success(t *testing.T) {
+	svc := NewCouponService(&mockKartRepository{}, testPolicy())
+
+	cpn, err := svc.OverrideDiscount(ctx, "HAPPYHRS", 18.456)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if cpn.Discount != 18.45 {
+		t.Errorf("Expected discount 18.45, got %f", cpn.Discount)
+	}
+}
+
+func TestOverrideDiscount_Failure(t *testing.T) {
+	mockRepo := &mockKartRepository{
+		err: myerror.KartError{Code: 404, Msg: "Coupon not found"},
+	}
+	svc := NewCouponService(mockRepo, testPolicy())
+
+	_, err := svc.OverrideDiscount(ctx, "UNKNOWN1", 20)
+	if err == nil {
+		t.Error("Expected error from repository, got nil")
+	}
+}
+
+func TestCreateCoupon(t *testing.T) {
+	svc := NewCouponService(&mockKartRepository{}, testPolicy())
+
+	// Test discount from policy
+	info, err := svc.CreateCoupon(ctx, model.CouponCreateReq{Code: "NEWCODE1"})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if info.Discount != 20 {
+		t.Errorf("Expected policy discount 20, got %f", info.Discount)
+	}
+
+	// Test explicit discount
+	discount := 35.0
+	info, _ = svc.CreateCoupon(ctx, model.CouponCreateReq{Code: "NEWCODE2", Discount: &discount})
+	if info.Discount != 35 {
+		t.Errorf("Expected discount 35, got %f", info.Discount)
+	}
+}
+
+func TestImportCoupons(t *testing.T) {
+	mockRepo := &mockKartRepository{coupons: map[string]model.Coupon{"EXISTING": {Code: "EXISTING"}}}
+	svc := NewCouponService(mockRepo, testPolicy())
+
+	var input strings.Builder
+	input.WriteString("code,discount\nEXISTING\nBAD CODE\nCODE0001,150\nCODE0003,0.001\nCODE0002,30\n")
+	for i := 0; i < importBatchSize; i++ {
+		input.WriteString("BULK" + strings.Repeat("X", i%10) + string(rune('A'+i%26)) + "\n")
+	}
+
+	result, err := svc.ImportCoupons(ctx, strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Invalid != 3 || len(result.Errors) != 3 {
+		t.Errorf("Expected 3 invalid lines, got %+v", result)
+	}
+	if result.Imported+result.Skipped != importBatchSize+2 {
+		t.Errorf("Expected %d valid lines, got %+v", importBatchSize+2, result)
+	}
+	if mockRepo.batches != 2 {
+		t.Errorf("Expected import in 2 batches, got %d", mockRepo.batches)
+	}
+	if c := mockRepo.coupons["CODE0002"]; c.Discount != 30 || !c.Locked {
+		t.Errorf("Expected explicit discount to be locked, got %+v", c)
+	}
+	if c := mockRepo.coupons["EXISTING"]; c.Discount != 0 {
+		t.Errorf("Expected existing coupon untouched, got %+v", c)
+	}
+}
+
+// Batches committed before the file failed to read are reported
+func TestImportCoupons_ReadError(t *testing.T) {
+	mockRepo := &mockKartRepository{coupons: map[string]model.Coupon{}}
+	svc := NewCouponService(mockRepo, testPolicy())
+
+	var input strings.Builder
+	for i := 0; i < importBatchSize; i++ {
+		fmt.Fprintf(&input, "BULK%04d\n", i)
+	}
+	input.WriteString(strings.Repeat("X", bufio.MaxScanTokenSize) + "\n")
+
+	_, err := svc.ImportCoupons(ctx, strings.NewReader(input.String()))
+	kErr, ok := err.(myerror.KartError)
+	if !ok || kErr.Code != 400 {
+		t.Fatalf("Expected 400, got %v", err)
+	}
+	want := fmt.Sprintf("Failed reading import file at line %d, %d coupons were imported before", importBatchSize+1, importBatchSize)
+	if kErr.Msg != want {
+		t.Errorf("Expected %q, got %q", want, kErr.Msg)
+	}
+	if len(mockRepo.coupons) != importBatchSize {
+		t.Errorf("Expected the first batch kept, got %d coupons", len(mockRepo.coupons))
+	}
+}
+
+func customerRepo() *mockKartRepository {
+	return &mockKartRepository{
+		order: &model.OrderResp{Id: "order-1"},
+		customers: map[string]*model.Customer{
+			"sub-alice": {Id: "alice"},
+			"sub-bob":   {Id: "bob"},
+		},
+		orders: map[string]*model.OrderResp{
+			"order-a": {Id: "order-a", CustomerId: "alice"},
+			"order-b": {Id: "order-b", CustomerId: "bob"},
+			"order-x": {Id: "order-x"},
+		},
+	}
+}
+
+func TestPlaceOrder_AssignsRegisteredCustomer(t *testing.T) {
+	mockRepo := customerRepo()
+	service := NewOrderService(mockRepo, events.NewHub(0))
+
+	_, err := service.PlaceOrder(ctx, model.OrderDetail{
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}},
+		CallerId:       "customer:sub-alice",
+		Subject:        "sub-alice",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if mockRepo.placed.CustomerId != "alice" || mockRepo.placed.CallerId != "customer:alice" {
+		t.Errorf("Expected order owned by alice, got customer %q caller %q", mockRepo.placed.CustomerId, mockRepo.placed.CallerId)
+	}
+}
+
+func TestPlaceOrder_UnregisteredCustomer(t *testing.T) {
+	service := NewOrderService(customerRepo(), events.NewHub(0))
+
+	_, err := service.PlaceOrder(ctx, model.OrderDetail{
+		OrderedProduct: []model.OrderedProduct{{ProductId: "1", Quantity: 1}},
+		Subject:        "sub-carol",
+	})
+	if !myerror.IsForbidden(err) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+}
+
+func TestGetOrder_Ownership(t *testing.T) {
+	service := NewOrderService(customerRepo(), events.NewHub(0))
+
+	if _, err := service.GetOrder(ctx, "order-a", model.OrderAccess{Subject: "sub-alice"}); err != nil {
+		t.Errorf("Expected owner to read order, got: %v", err)
+	}
+	if _, err := service.GetOrder(ctx, "order-b", model.OrderAccess{Subject: "sub-alice"}); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found for another customer's order, got: %v", err)
+	}
+	if _, err := service.GetOrder(ctx, "order-x", model.OrderAccess{Subject: "sub-alice"}); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found for an anonymous order, got: %v", err)
+	}
+	if _, err := service.GetOrder(ctx, "order-b", model.OrderAccess{All: true}); err != nil {
+		t.Errorf("Expected admin to read any order, got: %v", err)
+	}
+	if _, err := service.GetOrder(ctx, "order-a", model.OrderAccess{}); !myerror.IsForbidden(err) {
+		t.Errorf("Expected forbidden without a customer, got: %v", err)
+	}
+}
+
+func TestUpdateOrderStatus_Publishes(t *testing.T) {
+	hub := events.NewHub(0)
+	service := NewOrderService(customerRepo(), hub)
+	sub, _, _ := hub.Subscribe("order-a", "")
+	defer sub.Close()
+
+	eta := time.Now().Add(10 * time.Minute)
+	if _, err := service.UpdateOrderStatus(ctx, "order-a", model.OrderStatusUpdate{Status: model.OrderPreparing, Eta: &eta}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := service.UpdateOrderStatus(ctx, "order-a", model.OrderStatusUpdate{Eta: &eta}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := service.UpdateOrderStatus(ctx, "unknown", model.OrderStatusUpdate{Status: model.OrderReady}); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found, got: %v", err)
+	}
+
+	for _, want := range []string{events.Status, events.Eta} {
+		e := <-sub.Events()
+		if e.Type != want || e.Order.OrderId != "order-a" || e.Order.Status != model.OrderPreparing || !e.Order.Eta.Equal(eta) {
+			t.Errorf("Expected a %s event of the preparing order, got %+v", want, e)
+		}
+	}
+	if len(sub.Events()) != 0 {
+		t.Errorf("Expected no event for a failed update, got %d", len(sub.Events()))
+	}
+}
+
+func TestWatchOrder(t *testing.T) {
+	hub := events.NewHub(0)
+	mockRepo := customerRepo()
+	mockRepo.orders["order-a"].Status = model.OrderPlaced
+	service := NewOrderService(mockRepo, hub)
+	alice := model.OrderAccess{Subject: "sub-alice"}
+
+	if _, _, err := service.WatchOrder(ctx, "order-b", alice, ""); !myerror.IsNotFound(err) {
+		t.Errorf("Expected not found for another customer's order, got: %v", err)
+	}
+
+	// a new client starts from the current state
+	missed, sub, err := service.WatchOrder(ctx, "order-a", alice, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(missed) != 1 || missed[0].Order.Status != model.OrderPlaced || sub == nil {
+		t.Fatalf("Expected the placed order, got %+v", missed)
+	}
+	sub.Close()
+
+	// a client resuming gets what it missed, in order
+	service.UpdateOrderStatus(ctx, "order-a", model.OrderStatusUpdate{Status: model.OrderPreparing})
+	service.UpdateOrderStatus(ctx, "order-b", model.OrderStatusUpdate{Status: model.OrderPreparing})
+	service.UpdateOrderStatus(ctx, "order-a", model.OrderStatusUpdate{Status: model.OrderReady})
+	missed, sub, err = service.WatchOrder(ctx, "order-a", alice, missed[0].Id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(missed) != 2 || missed[0].Order.Status != model.OrderPreparing || missed[1].Order.Status != model.OrderReady {
+		t.Errorf("Expected preparing and ready, got %+v", missed)
+	}
+	sub.Close()
+
+	// once the order ended there is nothing to subscribe to
+	done, _ := service.UpdateOrderStatus(ctx, "order-a", model.OrderStatusUpdate{Status: model.OrderCompleted})
+	missed, sub, err = service.WatchOrder(ctx, "order-a", alice, "")
+	if err != nil || sub != nil || len(missed) != 1 || missed[0].Order.Status != done.Status {
+		t.Errorf("Expected only the completed order, got %+v %v %v", missed, sub, err)
+	}
+	missed, sub, err = service.WatchOrder(ctx, "order-a", alice, missed[0].Id)
+	if err != nil || sub != nil || len(missed) != 0 {
+		t.Errorf("Expected nothing after the last event, got %+v %v %v", missed, sub, err)
+	}
+}
+
+func TestListOrders_ScopedToCustomer(t *testing.T) {
+	mockRepo := customerRepo()
+	service := NewOrderService(mockRepo, events.NewHub(0))
+
+	orders, err := service.ListOrders(ctx, model.OrderAccess{Subject: "sub-bob"}, model.OrderFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(orders) != 1 || orders[0].Id != "order-b" {
+		t.Errorf("Expected only bob's order, got: %v", orders)
+	}
+
+	orders, err = service.ListOrders(ctx, model.OrderAccess{All: true, Subject: "sub-bob"}, model.OrderFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(orders) != 3 || mockRepo.listed.CustomerId != "" {
+		t.Errorf("Expected every order for an admin, got: %v", orders)
+	}
+}
+
+func TestExportEvents_JsonLines(t *testing.T) {
+	mockRepo := &mockKartRepository{events: []model.AuditEvent{
+		{Id: 1, Action: "coupon.disable", Entity: "coupon", EntityId: "SAVE10", Before: []byte(`{"disabled":false}`)},
+		{Id: 2, Action: "order.create", Entity: "order", EntityId: "order-1"},
+	}}
+	service := NewAuditService(mockRepo)
+
+	var out strings.Builder
+	if err := service.ExportEvents(ctx, model.AuditFilter{}, &out); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"before":{"disabled":false}`) || !strings.Contains(lines[1], `"entityId":"order-1"`) {
+		t.Errorf("Unexpected export:\n%s", out.String())
+	}
+}
+
+type mockHealthRepository struct {
+	pingErr error
+	version int
+}
+
+func (m *mockHealthRepository) Ping(context.Context) error { return m.pingErr }
+
+func (m *mockHealthRepository) SchemaVersion(context.Context) (int, error) { return m.version, nil }
+
+func (m *mockHealthRepository) SqliteVersion(context.Context) (string, error) { return "3.50.4", nil }
+
+func TestHealth_ReadyAfterCouponsLoad(t *testing.T) {
+	db := &mockHealthRepository{version: repo.CurrentSchemaVersion}
+	service := NewHealthService(db, "test")
+
+	health, ready := service.Ready(ctx)
+	if ready || health.Checks["coupons"] != StateLoading {
+		t.Errorf("Expected not ready while coupons load, got: %v", health)
+	}
+
+	service.CouponsLoaded(nil)
+	health, ready = service.Ready(ctx)
+	if !ready || health.Status != "ready" {
+		t.Errorf("Expected ready, got: %v", health)
+	}
+
+	status := service.Status(ctx)
+	if status.Version != "test" || status.Components["database"].Version != "sqlite 3.50.4" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestHealth_LoadingCoupons(t *testing.T) {
+	service := NewHealthService(&mockHealthRepository{version: repo.CurrentSchemaVersion}, "test")
+	if !service.LoadingCoupons() {
+		t.Error("Expected coupons loading at startup")
+	}
+	service.CouponsLoaded(errors.New("no such file"))
+	if service.LoadingCoupons() {
+		t.Error("Expected loading done once it failed")
+	}
+}
+
+func TestHealth_NotReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		db        *mockHealthRepository
+		couponErr error
+		check     string
+	}{
+		{"database down", &mockHealthRepository{pingErr: errors.New("unable to open /srv/kart/mydb.db"), version: repo.CurrentSchemaVersion}, nil, "database"},
+		{"not migrated", &mockHealthRepository{version: 0}, nil, "migrations"},
+		{"coupons failed", &mockHealthRepository{version: repo.CurrentSchemaVersion}, errors.New("open ../token/valid_codes.txt: no such file"), "coupons"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewHealthService(tt.db, "test")
+			service.CouponsLoaded(tt.couponErr)
+
+			health, ready := service.Ready(ctx)
+			if ready || health.Checks[tt.check] != StateFailed {
+				t.Errorf("Expected %s to fail readiness, got: %v", tt.check, health)
+			}
+			// /status is public, errors are only logged
+			if msg := service.Status(ctx).Components[tt.check].Message; strings.Contains(msg, "/") {
+				t.Errorf("Expected no error details in the status, got %q", msg)
+			}
+		})
+	}
+}
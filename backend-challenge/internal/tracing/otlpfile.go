@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// otlptrace client writing each batch as one line of OTLP JSON, the format
+// the collector's file exporter writes and otlpjsonfile receiver reads
+type fileClient struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newFileClient(w io.Writer) *fileClient {
+	return &fileClient{w: w}
+}
+
+func (c *fileClient) Start(context.Context) error { return nil }
+
+func (c *fileClient) Stop(context.Context) error { return nil }
+
+func (c *fileClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	line, err := marshalOtlpJson(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.w.Write(append(line, '\n'))
+	return err
+}
+
+// OTLP JSON differs from protobuf's JSON mapping in that enums are numbers
+// and trace and span ids hex rather than base64
+func marshalOtlpJson(req *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for _, rs := range objects(doc["resourceSpans"]) {
+		for _, ss := range objects(rs["scopeSpans"]) {
+			for _, span := range objects(ss["spans"]) {
+				hexIds(span)
+				for _, link := range objects(span["links"]) {
+					hexIds(link)
+				}
+			}
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func objects(v any) []map[string]any {
+	list, _ := v.([]any)
+	objs := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if obj, ok := item.(map[string]any); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+func hexIds(obj map[string]any) {
+	for _, key := range []string{"traceId", "spanId", "parentSpanId"} {
+		s, ok := obj[key].(string)
+		if !ok {
+			continue
+		}
+		if raw, err := base64.StdEncoding.DecodeString(s); err == nil {
+			obj[key] = hex.EncodeToString(raw)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestFileClient_OtlpJson(t *testing.T) {
+	var out strings.Builder
+	c := newFileClient(&out)
+
+	spans := []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{
+			Spans: []*tracepb.Span{{
+				TraceId:      []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+				SpanId:       []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+				ParentSpanId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:         "POST /order",
+				Kind:         tracepb.Span_SPAN_KIND_SERVER,
+			}},
+		}},
+	}}
+	if err := c.UploadTraces(context.Background(), spans); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	line := out.String()
+	for _, want := range []string{
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"spanId":"00f067aa0ba902b7"`,
+		`"parentSpanId":"0102030405060708"`,
+		`"kind":2`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %s in %s", want, line)
+		}
+	}
+	if !strings.HasSuffix(line, "}\n") || strings.Count(line, "\n") != 1 {
+		t.Errorf("Expected a single JSON line, got %q", line)
+	}
+}
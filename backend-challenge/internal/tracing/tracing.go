@@ -0,0 +1,114 @@
+// OpenTelemetry tracing of the server. Spans are exported to stdout or to a
+// file, so no collector has to be running.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/priykumar/oolio-kart-challenge"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "otlp_file"
+)
+
+type Config struct {
+	// none (default), stdout or otlp_file
+	Exporter string `json:"exporter"`
+	// OTLP JSON lines are appended here with the otlp_file exporter
+	File        string `json:"file"`
+	ServiceName string `json:"service_name"`
+	// Fraction of new traces sampled, 1 when unset. Requests carrying a
+	// traceparent follow their caller's decision.
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// Install the global tracer provider and W3C trace context propagation. The
+// returned func flushes pending spans and closes the exporter.
+func Setup(cfg Config, version string) (func(context.Context) error, error) {
+	// propagate even without exporting, so callers' traces stay connected
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("file is required for the %s exporter", ExporterFile)
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := otlptrace.New(context.Background(), newFileClient(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		exporter, closer = exp, f
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = "oolio-kart"
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", name),
+			attribute.String("service.version", version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer of this module's own spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start a span named name as a child of the one in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}